@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
 
 	"github.com/spicery/nutmeg-tokenizer/pkg/tokenizer"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -22,10 +30,115 @@ Options:
   -h, --help            Show this help message
   -v, --version         Show version information
   --input <file>        Input file (defaults to stdin)
+  --input-manifest <file>  Tokenize every file listed in a JSON manifest, e.g.
+                        {"files": ["a.nutmeg", "lib/b.nutmeg"]}, into one stream
+                        of tokens tagged with their file
   --output <file>       Output file (defaults to stdout)
-  --rules <file>        YAML rules file for custom tokenisation rules (optional)
+  --max-tokens-per-file <n>  Split the tokens into files of at most n tokens each,
+                        named by --output-pattern
+  --output-pattern <pattern>  Names of the files for --max-tokens-per-file, with %d
+                        for the number of the file from 1, e.g. tokens-%d.jsonl
+  --rules <file>        YAML rules file for custom tokenisation rules (optional);
+                        use - to read the rules from stdin (requires --input)
+  --rules-inline <yaml> YAML rules given directly on the command line
   --make-rules          Generate default rules YAML to stdout
+  --print-rules-hash    Print a content hash of the effective rules and exit
+  --print-checksums     Print the SHA-256 of the input and of the effective rules
+                        and exit
+  --explain-operator <op>  Print the effective precedence of an operator, and
+                        whether it is a default or from the rules file, and exit
+  --export-grammar <format>  Print an approximate highlighting grammar for the
+                        effective rules and exit; textmate or tree-sitter-lexer
+  --export-completions  Print the keyword completion data of the effective rules
+                        as JSON and exit
+  --diff-rules <old> <new>  Report the tokens added, removed or changed between
+                        two rules files, one JSON object per line
+  --compare <a> <b>     Tokenize two files with the effective rules and report the
+                        tokens added, removed or changed, ignoring layout, one
+                        JSON object per line; exits with code 1 if they differ
+  --hash                Print a hash of the tokens, ignoring layout and comments,
+                        rather than the tokens themselves
+  --stream              Write each token as soon as it is found rather than after
+                        the whole input is tokenized
+  --flush-every <n>     With --stream, flush the output after every n tokens
+                        rather than after each one, to save system calls
+  --envelope            Write a header record before the tokens, naming the input
+                        file, and a summary record after them, with the number of
+                        tokens and errors and the time taken
+  --no-partial-output   Write no tokens at all if tokenization fails
   --exit0               Exit with code 0 even on tokenisation errors (suppress stderr)
+  --diagnostics-format <format>  How to report the result: text (the default), an
+                        error message on stderr, or junit, a JUnit XML report
+                        with a test case for each input file, all of which are
+                        tokenized even after one fails
+  --diagnostics-output <file>  File for the diagnostics report (defaults to stderr)
+  --context             Annotate each token with its enclosing start tokens
+  --rule-source         Annotate each token classified by a rule with the kind of
+                        rule and whether it is a default or from the rules file
+  --pairs               Give each bracket, start and end token the index of its partner
+  --doc-marker <text>   Comment prefix that marks doc comments, which must begin
+                        with ### and be longer than it (default ####)
+  --lossless            Also output whitespace (w) and comment (c) tokens, so that
+                        the token texts add up to the input
+  --multiline-values    Give multi-line strings the joined, dedented value of their
+                        lines rather than an empty value
+  --line-continuation   Let a backslash at the end of a line continue a single-line
+                        string on the next line, after its indentation
+  --qualified-separator <sep>  Join names separated by sep, such as foo::bar for
+                        ::, into one variable token with a parts list
+  --keyword-args        Read name: directly within parentheses, as in f(x: 1), as
+                        a keyword argument (K) token
+  --format <name>       Output format: jsonl (tokens, the default), folding
+                        (one JSON span per line for each foldable region),
+                        outline (one JSON tree per line for each top-level block)
+                        or lsp-semantic-tokens (an LSP semantic tokens array)
+  --semantic-legend <file>  JSON legend for --format lsp-semantic-tokens
+  --only-types <list>   Only output tokens of these types (e.g. S,E,O)
+  --exclude-types <list>  Do not output tokens of these types (e.g. U)
+  --max-input-bytes <n>   Fail if the input is longer than n bytes
+  --max-tokens <n>        Fail if the input has more than n tokens
+  --max-interpolation-depth <n>  Fail if string interpolations nest deeper than n
+  --token-format-version <n>     Write a header record, then tokens in format version n
+  --verbose-types       Write token types by name, such as "start" rather than "S",
+                        and fields with their full names, for reading by people
+  --source-map <file>   Write line start and token byte offsets to a JSON file
+  --line-remap <file>   Write the lines renumbered by ###line directives to a JSON
+                        file, to map token lines back to the lines of the input
+  --trace               Log the matchers tried and the rule matched at each token to stderr
+  --warnings            Log warnings about dubious input, such as unknown operators, to stderr
+  --progress            Draw a progress bar on stderr while tokenizing
+  --self-check          Check that the span of every token covers its text, in
+                        order and without overlaps, and fail if any does not;
+                        files with ###line directives are not checked
+  --eof-token           Add a $ token at the end of the input, listing the start
+                        tokens and brackets still open
+  --keep-going          Go on after an exception token, such as a string with no
+                        closing quote, which covers the rest of its line, and
+                        report the first error at the end
+  --text-ids            Give each token the text_id of its text, numbered in
+                        the order the texts are first seen across all inputs
+  --strict              Stop with an error at the first condition that --warnings
+                        would report, such as an unknown escape sequence
+  --bridge-check <mode> Check that bridge tokens such as catch are within a start token
+                        of their in list: off (the default), warn (implies --warnings)
+                        or error
+  --numeric-sign <mode> What to do with a + or - in front of a number in prefix
+                        position: separate (the default), fold (into the number)
+                        or flag (keep the operator and mark the number)
+  --column-policy <policy>  What the columns of spans count: runes (characters, the
+                        default), bytes or utf16 (UTF-16 code units, as in LSP)
+  --warn-ambiguous-wildcards  Warn when a wildcard could stand for several expected
+                        labels (implies --warnings)
+  --max-line-length <n> Warn about lines longer than n characters (implies --warnings)
+  --max-integer-bits <n>  Warn about integer literals whose magnitude needs more than
+                        n bits, such as 64 (implies --warnings)
+  --max-exponent <n>    Warn about exponents larger than n in size, such as 308
+                        (implies --warnings)
+  --warn-trailing-whitespace  Warn about spaces and tabs at the end of a line
+                        (implies --warnings)
+  --transform <name>    Apply a built-in transform to the tokens; may be repeated.
+                        One of merge-strings, strip-unclassified-whitespace,
+                        canonicalize-aliases
 
 Examples:
   nutmeg-tokenizer                                   # Read from stdin, write to stdout
@@ -34,6 +147,9 @@ Examples:
   nutmeg-tokenizer --input source.nutmeg --output tokens.json  # Read from file, write to file
   nutmeg-tokenizer --rules custom.yaml --input source.nutmeg   # Use custom rules
   nutmeg-tokenizer --make-rules                      # Generate default rules configuration
+  gen-rules | nutmeg-tokenizer --rules - --input source.nutmeg  # Read rules from stdin
+  nutmeg-tokenizer --rules-inline 'mark: [{text: ";"}]'        # One-off rules
+  nutmeg-tokenizer --only-types 'S,E,[,]'            # Output only structural tokens
   echo "def foo end" | nutmeg-tokenizer              # Read from stdin, write to stdout
 
 The tokenizer outputs one JSON token object per line.
@@ -42,21 +158,81 @@ See docs/rules_file.md for information about custom rules files.
 )
 
 func main() {
-	var showHelp, showVersion, exit0, makeRules bool
-	var inputFile, outputFile, rulesFile string
+	var showHelp, showVersion, exit0, makeRules, annotateContext, printRulesHash, printChecksums, exportCompletions, trace, warnings, warnAmbiguous, lossless, multilineValues, lineContinuation, keywordArgs, strict, progress, stream, noPartialOutput, pairs, hash, warnTrailing, ruleSource, envelope, verboseTypes, selfCheck, keepGoing, eofToken, textIDs bool
+	var inputFile, outputFile, outputPattern, rulesFile, rulesInline, onlyTypes, excludeTypes, sourceMapFile, diffRules, compareFile, docMarker, manifestFile, format, legendFile, exportGrammar, bridgeCheckName, numericSignName, columnPolicyName, qualifiedSep, explainOperator, diagnosticsFormat, diagnosticsOutput, lineRemapFile, rulesDir string
+	var limits tokenizer.Limits
+	var formatVersion, maxLineLength, maxIntegerBits, maxExponent, maxTokensPerFile, flushEvery int
+	var transformNames stringList
 
 	flag.BoolVar(&showHelp, "h", false, "Show help")
 	flag.BoolVar(&showHelp, "help", false, "Show help")
 	flag.BoolVar(&showVersion, "v", false, "Show version")
 	flag.BoolVar(&showVersion, "version", false, "Show version")
 	flag.BoolVar(&exit0, "exit0", false, "Exit with code 0 even on errors")
+	flag.BoolVar(&hash, "hash", false, "Print a hash of the tokens rather than the tokens")
+	flag.BoolVar(&stream, "stream", false, "Write each token as soon as it is found")
+	flag.IntVar(&flushEvery, "flush-every", 0, "With --stream, flush the output after this many tokens")
+	flag.BoolVar(&envelope, "envelope", false, "Write header and summary records around the tokens")
+	flag.BoolVar(&noPartialOutput, "no-partial-output", false, "Write no tokens if tokenization fails")
 	flag.BoolVar(&makeRules, "make-rules", false, "Generate default rules YAML")
+	flag.BoolVar(&printRulesHash, "print-rules-hash", false, "Print the fingerprint of the effective rules")
+	flag.BoolVar(&printChecksums, "print-checksums", false, "Print the fingerprints of the input and the effective rules")
+	flag.StringVar(&explainOperator, "explain-operator", "", "Print the effective precedence of this operator")
+	flag.BoolVar(&exportCompletions, "export-completions", false, "Print the keyword completion data of the effective rules")
+	flag.StringVar(&exportGrammar, "export-grammar", "", "Print a highlighting grammar for the effective rules")
+	flag.StringVar(&diffRules, "diff-rules", "", "Compare this rules file with the one given as an argument")
+	flag.StringVar(&compareFile, "compare", "", "Compare the tokens of this file with those of the one given as an argument")
+	flag.BoolVar(&annotateContext, "context", false, "Annotate tokens with their enclosing start tokens")
+	flag.BoolVar(&ruleSource, "rule-source", false, "Annotate tokens with the rule that classified them")
+	flag.BoolVar(&pairs, "pairs", false, "Give matching brackets the index of their partner")
+	flag.StringVar(&docMarker, "doc-marker", "", "Comment prefix that marks doc comments")
+	flag.BoolVar(&lossless, "lossless", false, "Output whitespace and comments as tokens")
+	flag.BoolVar(&multilineValues, "multiline-values", false, "Give multi-line strings the joined value of their lines")
+	flag.BoolVar(&lineContinuation, "line-continuation", false, "Let a backslash at the end of a line continue a string")
+	flag.StringVar(&qualifiedSep, "qualified-separator", "", "Join names separated by this into one token")
+	flag.BoolVar(&keywordArgs, "keyword-args", false, "Read name: within parentheses as a keyword argument")
 	flag.StringVar(&inputFile, "input", "", "Input file (defaults to stdin)")
+	flag.StringVar(&manifestFile, "input-manifest", "", "JSON manifest listing the input files")
 	flag.StringVar(&outputFile, "output", "", "Output file (defaults to stdout)")
+	flag.IntVar(&maxTokensPerFile, "max-tokens-per-file", 0, "Split the tokens into files of at most this many tokens")
+	flag.StringVar(&outputPattern, "output-pattern", "", "Names of the files for --max-tokens-per-file, with %d for the file number")
 	flag.StringVar(&rulesFile, "rules", "", "YAML rules file (optional)")
+	flag.StringVar(&rulesInline, "rules-inline", "", "YAML rules given inline (optional)")
+	flag.StringVar(&rulesDir, "rules-dir", "", "Directory that --rules names a file within, read as an fs.FS")
+	flag.StringVar(&format, "format", "jsonl", "Output format: jsonl, folding, outline or lsp-semantic-tokens")
+	flag.StringVar(&diagnosticsFormat, "diagnostics-format", "text", "How to report the result: text or junit")
+	flag.StringVar(&diagnosticsOutput, "diagnostics-output", "", "File for the diagnostics report (defaults to stderr)")
+	flag.StringVar(&legendFile, "semantic-legend", "", "JSON legend for --format lsp-semantic-tokens")
+	flag.StringVar(&onlyTypes, "only-types", "", "Only output tokens of these types")
+	flag.StringVar(&excludeTypes, "exclude-types", "", "Do not output tokens of these types")
+	flag.StringVar(&sourceMapFile, "source-map", "", "Write a source map to this file")
+	flag.StringVar(&lineRemapFile, "line-remap", "", "Write the line remapping table to this file")
+	flag.BoolVar(&trace, "trace", false, "Trace the tokenizer's decisions to stderr")
+	flag.BoolVar(&warnings, "warnings", false, "Log warnings about dubious input to stderr")
+	flag.BoolVar(&strict, "strict", false, "Treat warnings as errors")
+	flag.BoolVar(&eofToken, "eof-token", false, "Add an end of file token listing what is still open")
+	flag.BoolVar(&keepGoing, "keep-going", false, "Go on tokenizing after an exception token")
+	flag.BoolVar(&textIDs, "text-ids", false, "Give each token the ID of its interned text")
+	flag.BoolVar(&selfCheck, "self-check", false, "Check the spans of the tokens against the input")
+	flag.BoolVar(&progress, "progress", false, "Draw a progress bar on stderr")
+	flag.BoolVar(&warnAmbiguous, "warn-ambiguous-wildcards", false, "Warn when a wildcard could stand for several expected labels")
+	flag.StringVar(&numericSignName, "numeric-sign", "separate", "What to do with a sign in front of a number: separate, fold or flag")
+	flag.StringVar(&columnPolicyName, "column-policy", "runes", "What columns count: runes, bytes or utf16")
+	flag.IntVar(&maxLineLength, "max-line-length", 0, "Warn about lines longer than this (0 for no limit)")
+	flag.IntVar(&maxIntegerBits, "max-integer-bits", 0, "Warn about integer literals needing more bits than this (0 for no limit)")
+	flag.IntVar(&maxExponent, "max-exponent", 0, "Warn about exponents larger than this (0 for no limit)")
+	flag.BoolVar(&warnTrailing, "warn-trailing-whitespace", false, "Warn about whitespace at the end of a line")
+	flag.StringVar(&bridgeCheckName, "bridge-check", "off", "Check bridge tokens against their in lists: off, warn or error")
+	flag.Var(&transformNames, "transform", "Apply a built-in transform to the tokens (repeatable)")
+	flag.IntVar(&limits.MaxInputBytes, "max-input-bytes", 0, "Maximum input size in bytes (0 for no limit)")
+	flag.IntVar(&limits.MaxTokens, "max-tokens", 0, "Maximum number of tokens (0 for no limit)")
+	flag.IntVar(&limits.MaxInterpolationDepth, "max-interpolation-depth", 0, "Maximum interpolation nesting (0 for no limit)")
+	flag.IntVar(&formatVersion, "token-format-version", 0, "Token format version to write, with a header record")
+	flag.BoolVar(&verboseTypes, "verbose-types", false, "Write token types and fields with their full names")
 
 	flag.Usage = func() {
-		fmt.Fprint(os.Stderr, usage)
+		// The usage is written as it is, as it shows the %d of --output-pattern.
+		io.WriteString(os.Stderr, usage)
 	}
 
 	flag.Parse()
@@ -80,39 +256,171 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Reject any positional arguments
-	if len(flag.Args()) > 0 {
+	// --diff-rules is the only option that takes a positional argument, the
+	// new rules file.
+	if diffRules != "" {
+		if len(flag.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: --diff-rules needs exactly two rules files, e.g. --diff-rules old.yaml new.yaml\n")
+			os.Exit(1)
+		}
+		if err := printRulesDiff(diffRules, flag.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// --compare also takes the second file as a positional argument, but is
+	// handled once the rules are loaded.
+	if compareFile != "" && len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: --compare needs exactly two files, e.g. --compare before.nutmeg after.nutmeg\n")
+		os.Exit(1)
+	}
+
+	// Reject any other positional arguments
+	if compareFile == "" && len(flag.Args()) > 0 {
 		fmt.Fprintf(os.Stderr, "Error: Unexpected positional arguments. Use --input and --output flags instead.\n\n")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	var input string
-	var err error
+	if err := checkDiagnosticsFlags(diagnosticsFormat, diagnosticsOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	junit := diagnosticsFormat == "junit"
+	if err := checkFormatFlags(format, formatVersion, verboseTypes, legendFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkInputFlags(inputFile, manifestFile, sourceMapFile, lineRemapFile, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkFlushFlags(flushEvery, stream, maxTokensPerFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkHashFlags(hash, stream, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkSelfCheckFlags(selfCheck, len(transformNames) > 0); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkDocMarkerFlag(docMarker); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkStreamFlags(stream, noPartialOutput, pairs, len(transformNames) > 0, sourceMapFile, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkChunkFlags(maxTokensPerFile, outputPattern, outputFile, hash, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkEnvelopeFlags(envelope, hash, maxTokensPerFile, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkRulesFlags(rulesFile, rulesInline, rulesDir, inputFile == "" && manifestFile == ""); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	keep, err := makeTypeFilter(onlyTypes, excludeTypes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Read input
-	if inputFile == "" {
-		// Read from stdin
-		input, err = readFromStdin()
+	var transforms []tokenizer.Transform
+	for _, name := range transformNames {
+		transform, err := tokenizer.LookupTransform(name)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-	} else {
-		// Read from file
-		input, err = readFromFile(inputFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading file '%s': %v\n", inputFile, err)
+		transforms = append(transforms, transform)
+	}
+
+	legend, err := loadSemanticLegend(legendFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading semantic legend '%s': %v\n", legendFile, err)
+		os.Exit(1)
+	}
+
+	// Without --token-format-version the current format is written with no
+	// header, as it always has been.
+	version := tokenizer.FormatVersion
+	if formatVersion != 0 {
+		if err := tokenizer.CheckFormatVersion(formatVersion); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		version = formatVersion
+	}
+	encoding := tokenEncoding{version: version, verbose: verboseTypes}
+
+	options := &tokenizer.Options{
+		AnnotateContext: annotateContext,
+		Limits:          limits,
+		DocMarker:       docMarker,
+		Lossless:        lossless,
+		Filename:        inputFile,
+		MultiLineValues: multilineValues,
+		Strict:          strict,
+	}
+	options.LineContinuation = lineContinuation
+	options.QualifiedSeparator = qualifiedSep
+	options.KeywordArguments = keywordArgs
+	options.KeepGoing = keepGoing
+	options.EndOfFileToken = eofToken
+	if textIDs {
+		options.Interner = tokenizer.NewInterner()
+	}
+	if trace {
+		options.Trace = os.Stderr
+	}
+	bridgeCheck, err := tokenizer.ParseBridgeCheck(bridgeCheckName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.BridgeCheck = bridgeCheck
+	numericSign, err := tokenizer.ParseNumericSign(numericSignName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.NumericSign = numericSign
+	columnPolicy, err := tokenizer.ParseColumnPolicy(columnPolicyName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.ColumnPolicy = columnPolicy
+	options.WarnAmbiguousWildcards = warnAmbiguous
+	options.MaxLineLength = maxLineLength
+	options.MaxIntegerBits = maxIntegerBits
+	options.MaxExponent = maxExponent
+	options.AnnotateRuleSource = ruleSource
+	options.WarnTrailingWhitespace = warnTrailing
+	if warnings || warnAmbiguous || maxLineLength > 0 || maxIntegerBits > 0 || maxExponent > 0 || warnTrailing || bridgeCheck == tokenizer.BridgeCheckWarn {
+		options.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
 	}
 
 	// Load rules if specified
-	var t *tokenizer.Tokenizer
-	if rulesFile != "" {
-		rules, err := tokenizer.LoadRulesFile(rulesFile)
+	if rulesFile != "" || rulesInline != "" {
+		var rulesFS fs.FS
+		if rulesDir != "" {
+			rulesFS = os.DirFS(rulesDir)
+		}
+		rules, err := loadRules(rulesFile, rulesInline, rulesFS)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading rules file '%s': %v\n", rulesFile, err)
+			fmt.Fprintf(os.Stderr, "Error loading rules: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -121,21 +429,98 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error applying rules: %v\n", err)
 			os.Exit(1)
 		}
-		t = tokenizer.NewTokenizerWithRules(input, tokenizerRules)
-	} else {
-		t = tokenizer.NewTokenizer(input)
+		options.Rules = tokenizerRules
+	}
+	if printRulesHash {
+		fmt.Println(tokenizer.RulesFingerprint(options.Rules))
+		os.Exit(0)
+	}
+	if explainOperator != "" {
+		info, err := tokenizer.ExplainOperator(options.Rules, explainOperator)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeRecord(os.Stdout, info); err != nil {
+			fmt.Fprintf(os.Stderr, "JSON encoding error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if exportGrammar != "" {
+		grammar, err := tokenizer.ExportGrammar(options.Rules, exportGrammar, "nutmeg")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(grammar)
+		os.Exit(0)
+	}
+	if exportCompletions {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(tokenizer.NewCompletions(options.Rules)); err != nil {
+			fmt.Fprintf(os.Stderr, "JSON encoding error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if compareFile != "" {
+		differ, err := printTokensDiff(compareFile, flag.Arg(0), options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if differ {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
-	// Process input
-	tokens, tokenizeErr := t.Tokenize()
+	// Read input
+	var sources []source
+	switch {
+	case manifestFile != "":
+		sources, err = readManifest(manifestFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading manifest '%s': %v\n", manifestFile, err)
+			os.Exit(1)
+		}
+	case inputFile == "":
+		// Read from stdin
+		input, err := readFromStdin()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
+			os.Exit(1)
+		}
+		sources = []source{{"", input}}
+	default:
+		// Read from file
+		input, err := readFromFile(inputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file '%s': %v\n", inputFile, err)
+			os.Exit(1)
+		}
+		sources = []source{{inputFile, input}}
+	}
+	inputs := make([]string, len(sources))
+	for i, src := range sources {
+		inputs[i] = src.input
+	}
+	if printChecksums {
+		fmt.Println("input", tokenizer.InputFingerprint(inputs...))
+		fmt.Println("rules", tokenizer.RulesFingerprint(options.Rules))
+		os.Exit(0)
+	}
 
 	// Prepare output destination
-	var output io.Writer
+	var destination io.Writer
 	var outputCloser io.Closer
 
 	if outputFile == "" {
 		// Write to stdout
-		output = os.Stdout
+		destination = os.Stdout
 	} else {
 		// Write to file
 		file, err := os.Create(outputFile)
@@ -143,21 +528,209 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error creating output file '%s': %v\n", outputFile, err)
 			os.Exit(1)
 		}
-		output = file
+		destination = file
 		outputCloser = file
 	}
 
-	// Output tokens as JSON, one per line (even if there was an error)
-	for _, token := range tokens {
-		jsonBytes, err := json.Marshal(token)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "JSON encoding error: %v\n", err)
+	// The output is buffered, since a write per token would cost a system
+	// call each. In stream mode it is flushed as tokens are written, after
+	// each one unless --flush-every says otherwise.
+	output := bufio.NewWriter(destination)
+	if stream && flushEvery == 0 {
+		flushEvery = 1
+	}
+
+	// A header is written with an explicit format version, and with
+	// --envelope, which also names the input.
+	var header *tokenizer.Header
+	if formatVersion != 0 || envelope {
+		record := tokenizer.NewHeader(version, tokenizer.RulesFingerprint(options.Rules))
+		record.Verbose = verboseTypes
+		if envelope {
+			record.File = inputFile
+			if manifestFile != "" {
+				record.File = manifestFile
+			}
+		}
+		header = &record
+	}
+
+	// With --max-tokens-per-file the tokens go to a series of files instead,
+	// each with its own header.
+	var chunks *chunkedOutput
+	if maxTokensPerFile > 0 {
+		chunks = &chunkedOutput{pattern: outputPattern, max: maxTokensPerFile, encoding: encoding, header: header}
+	}
+
+	// In stream mode each token is written as soon as it is final, so only
+	// the header is written up front.
+	var emit func(*tokenizer.Token) error
+	written := 0 // The number of tokens written, for the summary
+	if stream {
+		if header != nil && chunks == nil {
+			if err := writeTokens(output, nil, encoding, header); err != nil {
+				fmt.Fprintf(os.Stderr, "JSON encoding error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		emit = func(token *tokenizer.Token) error {
+			if keep != nil && !keep(token) {
+				return nil
+			}
+			written++
+			if chunks != nil {
+				return chunks.write([]*tokenizer.Token{token})
+			}
+			if err := writeTokens(output, []*tokenizer.Token{token}, encoding, nil); err != nil {
+				return err
+			}
+			if written%flushEvery == 0 {
+				return output.Flush()
+			}
+			return nil
+		}
+	}
+	started := time.Now()
+
+	// Process input, stopping at the first file with an error unless a JUnit
+	// report wants a result for every file.
+	var tokens []*tokenizer.Token
+	var tokenizeErr error
+	var selfCheckErrs []error
+	var results []fileResult
+	var remaps tokenizer.LineRemaps // The line remapping table of the only source
+	for _, src := range sources {
+		fileStarted := time.Now()
+		options.Filename = src.name
+		if progress {
+			options.OnProgress = progressBar(os.Stderr, src.name)
+		}
+		t := tokenizer.New(src.input, options)
+		for _, transform := range transforms {
+			t.AddTransform(transform)
+		}
+		var fileTokens []*tokenizer.Token
+		var err error
+		var streamed []*tokenizer.Token // The tokens written in stream mode, for --self-check
+		if stream {
+			err = t.TokenizeStream(func(token *tokenizer.Token) error {
+				if manifestFile != "" && token.File == "" {
+					token.File = src.name
+				}
+				if selfCheck {
+					streamed = append(streamed, token)
+				}
+				return emit(token)
+			})
+		} else {
+			fileTokens, err = t.Tokenize()
+		}
+		// Positions renumbered by ###line directives cannot be checked
+		// against the input, so a file with any is not checked.
+		if selfCheck && len(t.LineRemaps()) == 1 {
+			selfCheckErrs = append(selfCheckErrs, tokenizer.ValidateTokensWithPolicy(append(streamed, fileTokens...), src.input, columnPolicy)...)
+		}
+		remaps = t.LineRemaps()
+		if keep != nil {
+			fileTokens = tokenizer.FilterTokens(fileTokens, keep)
+		}
+		if junit {
+			results = append(results, fileResult{src.name, err, time.Since(fileStarted)})
+		}
+		if manifestFile != "" {
+			tagFile(fileTokens, src.name)
+			if err != nil {
+				err = fmt.Errorf("in '%s': %w", src.name, err)
+			}
+		}
+		tokens = append(tokens, fileTokens...)
+		if err != nil && tokenizeErr == nil {
+			tokenizeErr = err
+		}
+		if err != nil && !junit {
+			break
+		}
+	}
+
+	// With --no-partial-output a failed run writes no tokens at all.
+	suppressed := tokenizeErr != nil && noPartialOutput
+
+	// Pairs and the source map are indexed by position in the output, so they
+	// are computed after filtering. There is only one source when a source
+	// map is wanted.
+	if pairs {
+		tokenizer.MatchBrackets(tokens)
+	}
+	if sourceMapFile != "" && !suppressed {
+		if err := writeSourceMap(sourceMapFile, sources[0].input, tokens, columnPolicy); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing source map '%s': %v\n", sourceMapFile, err)
 			os.Exit(1)
 		}
-		fmt.Fprintln(output, string(jsonBytes))
+	}
+	if lineRemapFile != "" {
+		if err := writeLineRemaps(lineRemapFile, remaps); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing line remapping table '%s': %v\n", lineRemapFile, err)
+			os.Exit(1)
+		}
+	}
+
+	// Write the output (even if there was an error, unless suppressed)
+	switch {
+	case stream || suppressed:
+		err = nil
+	case hash:
+		// A hash of some of the tokens would be mistaken for the hash of the
+		// input, so nothing is written if tokenization failed.
+		if tokenizeErr == nil {
+			_, err = fmt.Fprintln(output, tokenizer.HashTokens(tokens, nil))
+		}
+	case format == "folding":
+		err = writeFoldingRanges(output, tokens)
+	case format == "outline":
+		err = writeOutline(output, tokens)
+	case format == "lsp-semantic-tokens":
+		err = writeSemanticTokens(output, sources[0].input, tokens, legend, columnPolicy)
+	case chunks != nil:
+		err = chunks.write(tokens)
+		written = len(tokens)
+	default:
+		err = writeTokens(output, tokens, encoding, header)
+		written = len(tokens)
+	}
+	if err == nil && envelope {
+		// The summary is written even when no tokens were, so that a
+		// consumer can tell a failed run from a truncated stream.
+		errorCount := 0
+		if tokenizeErr != nil {
+			errorCount = 1
+		}
+		if suppressed && !stream {
+			err = writeTokens(output, nil, encoding, header)
+		}
+		if err == nil {
+			summary := tokenizer.NewSummary(written, errorCount, time.Since(started))
+			summary.InputHash = tokenizer.InputFingerprint(inputs...)
+			summary.RulesHash = header.RulesHash
+			err = writeRecord(output, summary)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "JSON encoding error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := output.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Close output file if we opened one
+	if chunks != nil {
+		if err := chunks.close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	if outputCloser != nil {
 		if err := outputCloser.Close(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error closing output file '%s': %v\n", outputFile, err)
@@ -165,101 +738,762 @@ func main() {
 		}
 	}
 
+	if junit {
+		suite := manifestFile
+		if suite == "" {
+			suite = "nutmeg-tokenizer"
+		}
+		if err := writeJUnitReport(diagnosticsOutput, suite, results, time.Since(started)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing diagnostics report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// A failed self-check is a bug in the tokenizer rather than in the input,
+	// so it fails even with --exit0.
+	if len(selfCheckErrs) > 0 {
+		for _, err := range selfCheckErrs {
+			fmt.Fprintf(os.Stderr, "Self-check error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+
 	// Handle tokenisation error after outputting tokens
 	if tokenizeErr != nil {
 		if exit0 {
 			// With --exit0, exit normally despite error
 			os.Exit(0)
 		} else {
-			// Without --exit0, print error to stderr and exit with error code
-			fmt.Fprintf(os.Stderr, "Tokenization error: %v\n", tokenizeErr)
+			// Without --exit0, print error to stderr and exit with error code,
+			// unless the JUnit report is there already.
+			if !junit || diagnosticsOutput != "" {
+				fmt.Fprintf(os.Stderr, "Tokenization error: %v\n", tokenizeErr)
+			}
 			os.Exit(1)
 		}
 	}
 }
 
-// readFromStdin reads all input from stdin.
-func readFromStdin() (string, error) {
-	bytes, err := io.ReadAll(os.Stdin)
+// checkDiagnosticsFlags reports an error if the diagnostics format is
+// unknown, or a file is given for the text format, which has no report.
+func checkDiagnosticsFlags(format, output string) error {
+	switch format {
+	case "junit":
+		return nil
+	case "text":
+		if output != "" {
+			return fmt.Errorf("--diagnostics-output can only be used with --diagnostics-format junit")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown diagnostics format '%s' (known formats: text, junit)", format)
+}
+
+// fileResult is the outcome of tokenizing one input, for a JUnit report.
+type fileResult struct {
+	name     string
+	err      error // The tokenization error, or nil if there was none
+	duration time.Duration
+}
+
+// The elements of a JUnit XML report, in which each input file is a test
+// case that fails if the file could not be tokenized.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes a JUnit XML report of the results to the named
+// file, or to stderr if the name is "".
+func writeJUnitReport(name, suiteName string, results []fileResult, elapsed time.Duration) error {
+	suite := junitTestSuite{Name: suiteName, Tests: len(results), Time: junitSeconds(elapsed)}
+	for _, result := range results {
+		testCase := junitTestCase{Name: result.name, ClassName: suiteName, Time: junitSeconds(result.duration)}
+		if testCase.Name == "" {
+			testCase.Name = "<stdin>"
+		}
+		if result.err != nil {
+			suite.Failures++
+			testCase.Failure = junitFailureFor(testCase.Name, result.err)
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	report, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
 	if err != nil {
-		return "", err
+		return err
+	}
+	report = append([]byte(xml.Header), append(report, '\n')...)
+	if name == "" {
+		_, err = os.Stderr.Write(report)
+		return err
 	}
-	return string(bytes), nil
+	return os.WriteFile(name, report, 0644)
 }
 
-// readFromFile reads the contents of a file.
-func readFromFile(filename string) (string, error) {
-	bytes, err := os.ReadFile(filename)
+// junitFailureFor describes a file's tokenization error. The text gives the
+// position as file:line:col, which many CI systems turn into a link.
+func junitFailureFor(file string, err error) *junitFailure {
+	var tokErr *tokenizer.Error
+	if !errors.As(err, &tokErr) {
+		// Errors that are not at a position, such as an input too large
+		// for the limits, are reported as they are.
+		return &junitFailure{Message: err.Error(), Type: "error", Text: err.Error()}
+	}
+	start := tokErr.Span.Start
+	return &junitFailure{
+		Message: tokErr.Reason,
+		Type:    "tokenization",
+		Text:    fmt.Sprintf("%s:%d:%d: %s", file, start.Line, start.Col, tokErr.Reason),
+	}
+}
+
+// junitSeconds formats a duration as JUnit times are, in seconds.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// checkFormatFlags reports an error if the output format is unknown, or
+// cannot be used with a token format version or --verbose-types.
+func checkFormatFlags(format string, formatVersion int, verboseTypes bool, legendFile string) error {
+	if legendFile != "" && format != "lsp-semantic-tokens" {
+		return fmt.Errorf("--semantic-legend can only be used with --format lsp-semantic-tokens")
+	}
+	switch format {
+	case "jsonl":
+		return nil
+	case "folding", "outline", "lsp-semantic-tokens":
+		if formatVersion != 0 {
+			return fmt.Errorf("--token-format-version cannot be used with --format %s", format)
+		}
+		if verboseTypes {
+			return fmt.Errorf("--verbose-types cannot be used with --format %s", format)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format '%s' (known formats: jsonl, folding, outline, lsp-semantic-tokens)", format)
+}
+
+// tokenEncoding is how tokens are written as JSON: in which format version,
+// and whether with the full names of types and fields, for --verbose-types.
+type tokenEncoding struct {
+	version int
+	verbose bool
+}
+
+// encode renders a token as JSON.
+func (e tokenEncoding) encode(token *tokenizer.Token) ([]byte, error) {
+	if e.verbose {
+		return tokenizer.EncodeTokenVerbose(token, e.version)
+	}
+	return tokenizer.EncodeToken(token, e.version)
+}
+
+// writeTokens writes the tokens as JSON in the given encoding, one per line,
+// preceded by the header record if there is one.
+func writeTokens(output io.Writer, tokens []*tokenizer.Token, encoding tokenEncoding, header *tokenizer.Header) error {
+	if header != nil {
+		if err := writeRecord(output, header); err != nil {
+			return err
+		}
+	}
+	for _, token := range tokens {
+		jsonBytes, err := encoding.encode(token)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(output, string(jsonBytes))
+	}
+	return nil
+}
+
+// writeRecord writes a record, such as a header, as JSON on a line of its
+// own.
+func writeRecord(output io.Writer, record interface{}) error {
+	jsonBytes, err := json.Marshal(record)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(bytes), nil
+	fmt.Fprintln(output, string(jsonBytes))
+	return nil
 }
 
-// generateDefaultConfig outputs the default configuration in YAML format to stdout.
-func generateDefaultConfig() error {
-	rules := tokenizer.DefaultRules()
+// semanticTokensOutput is the JSON form of --format lsp-semantic-tokens. It
+// uses the LSP field names so that a language server can pass it on as is.
+type semanticTokensOutput struct {
+	Legend struct {
+		TokenTypes     []string `json:"tokenTypes"`
+		TokenModifiers []string `json:"tokenModifiers"`
+	} `json:"legend"`
+	Data []uint32 `json:"data"`
+}
+
+// writeSemanticTokens writes the tokens as a single JSON object holding the
+// LSP semantic tokens legend and data.
+func writeSemanticTokens(output io.Writer, input string, tokens []*tokenizer.Token, legend *tokenizer.SemanticLegend, policy tokenizer.ColumnPolicy) error {
+	data, err := tokenizer.SemanticTokensWithPolicy(input, tokens, legend, policy)
+	if err != nil {
+		return err
+	}
+	var result semanticTokensOutput
+	result.Legend.TokenTypes = legend.TokenTypes
+	result.Legend.TokenModifiers = legend.TokenModifiers
+	// An empty array is written rather than null when nothing is mapped.
+	result.Data = append([]uint32{}, data...)
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(output, string(jsonBytes))
+	return nil
+}
+
+// loadSemanticLegend reads a JSON semantic legend, or returns the default
+// legend if no file is given.
+func loadSemanticLegend(filename string) (*tokenizer.SemanticLegend, error) {
+	if filename == "" {
+		return tokenizer.DefaultSemanticLegend(), nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var legend tokenizer.SemanticLegend
+	if err := json.Unmarshal(data, &legend); err != nil {
+		return nil, err
+	}
+	if err := legend.Check(); err != nil {
+		return nil, err
+	}
+	return &legend, nil
+}
+
+// writeFoldingRanges writes the span of each foldable region of the tokens as
+// JSON, one per line.
+func writeFoldingRanges(output io.Writer, tokens []*tokenizer.Token) error {
+	for _, span := range tokenizer.FoldingRanges(tokens) {
+		jsonBytes, err := json.Marshal(span)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(output, string(jsonBytes))
+	}
+	return nil
+}
+
+// writeOutline writes the outline of the tokens, one JSON tree per line for
+// each top-level block.
+func writeOutline(output io.Writer, tokens []*tokenizer.Token) error {
+	for _, node := range tokenizer.Outline(tokens) {
+		if err := writeRecord(output, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkInputFlags reports an error if the input flags cannot be used
+// together. A source map describes a single input, so it cannot be written
+// for a manifest, and nor can semantic tokens.
+func checkInputFlags(inputFile, manifestFile, sourceMapFile, lineRemapFile, format string) error {
+	if manifestFile == "" {
+		return nil
+	}
+	if inputFile != "" {
+		return fmt.Errorf("--input and --input-manifest cannot be used together")
+	}
+	if sourceMapFile != "" {
+		return fmt.Errorf("--source-map cannot be used with --input-manifest")
+	}
+	if lineRemapFile != "" {
+		return fmt.Errorf("--line-remap cannot be used with --input-manifest")
+	}
+	if format == "lsp-semantic-tokens" {
+		return fmt.Errorf("--format lsp-semantic-tokens cannot be used with --input-manifest")
+	}
+	return nil
+}
+
+// chunkedOutput writes tokens to a series of files named by a pattern, with
+// at most max tokens in each, so that a huge stream of tokens can be
+// processed in parts. Each file starts with the header, if there is one, and
+// a file is only created when there is a token to put in it.
+type chunkedOutput struct {
+	pattern  string
+	max      int
+	encoding tokenEncoding
+	header   *tokenizer.Header
+	file     *os.File
+	writer   *bufio.Writer
+	count    int // The number of tokens in the current file
+	files    int // The number of files created so far
+}
+
+// write writes the tokens, starting new files as the current one fills up.
+func (c *chunkedOutput) write(tokens []*tokenizer.Token) error {
+	for _, token := range tokens {
+		if c.file == nil || c.count == c.max {
+			if err := c.next(); err != nil {
+				return err
+			}
+		}
+		if err := writeTokens(c.writer, []*tokenizer.Token{token}, c.encoding, nil); err != nil {
+			return err
+		}
+		c.count++
+	}
+	return nil
+}
+
+// next closes the current file, if any, and starts the next one.
+func (c *chunkedOutput) next() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	c.files++
+	file, err := os.Create(fmt.Sprintf(c.pattern, c.files))
+	if err != nil {
+		return err
+	}
+	c.file, c.writer, c.count = file, bufio.NewWriter(file), 0
+	return writeTokens(c.writer, nil, c.encoding, c.header)
+}
+
+// close flushes and closes the current file, if any.
+func (c *chunkedOutput) close() error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.writer.Flush()
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	c.file, c.writer = nil, nil
+	return err
+}
+
+// checkSelfCheckFlags reports an error if --self-check is combined with
+// --transform, whose changes to the texts of tokens it would report as
+// failures.
+func checkSelfCheckFlags(selfCheck, transforms bool) error {
+	if selfCheck && transforms {
+		return fmt.Errorf("--self-check cannot be used with --transform")
+	}
+	return nil
+}
+
+// checkDocMarkerFlag reports an error if --doc-marker gives a marker that
+// cannot mark doc comments.
+func checkDocMarkerFlag(docMarker string) error {
+	if docMarker == "" {
+		return nil
+	}
+	if err := tokenizer.CheckDocMarker(docMarker); err != nil {
+		return fmt.Errorf("--doc-marker: %v", err)
+	}
+	return nil
+}
+
+// checkStreamFlags reports an error if --stream is combined with an option
+// that needs all the tokens before anything can be written.
+func checkStreamFlags(stream, noPartialOutput, pairs, transforms bool, sourceMapFile, format string) error {
+	if !stream {
+		return nil
+	}
+	switch {
+	case noPartialOutput:
+		return fmt.Errorf("--stream cannot be used with --no-partial-output")
+	case pairs:
+		return fmt.Errorf("--stream cannot be used with --pairs")
+	case transforms:
+		return fmt.Errorf("--stream cannot be used with --transform")
+	case sourceMapFile != "":
+		return fmt.Errorf("--stream cannot be used with --source-map")
+	case format != "jsonl":
+		return fmt.Errorf("--stream cannot be used with --format %s", format)
+	}
+	return nil
+}
+
+// checkChunkFlags reports an error if --max-tokens-per-file and
+// --output-pattern are not given together, or are combined with an option
+// that writes something other than one token per line.
+func checkChunkFlags(maxTokensPerFile int, outputPattern, outputFile string, hash bool, format string) error {
+	switch {
+	case maxTokensPerFile < 0:
+		return fmt.Errorf("--max-tokens-per-file must be positive")
+	case maxTokensPerFile == 0 && outputPattern == "":
+		return nil
+	case maxTokensPerFile == 0:
+		return fmt.Errorf("--output-pattern can only be used with --max-tokens-per-file")
+	case outputPattern == "":
+		return fmt.Errorf("--max-tokens-per-file needs an --output-pattern")
+	case strings.Count(outputPattern, "%d") != 1 || strings.Count(outputPattern, "%") != 1:
+		return fmt.Errorf("--output-pattern must contain %%d once, and no other %%")
+	case outputFile != "":
+		return fmt.Errorf("--max-tokens-per-file cannot be used with --output")
+	case hash:
+		return fmt.Errorf("--max-tokens-per-file cannot be used with --hash")
+	case format != "jsonl":
+		return fmt.Errorf("--max-tokens-per-file cannot be used with --format %s", format)
+	}
+	return nil
+}
+
+// checkEnvelopeFlags reports an error if --envelope is combined with an
+// option that writes something other than a single stream of tokens.
+func checkEnvelopeFlags(envelope, hash bool, maxTokensPerFile int, format string) error {
+	switch {
+	case !envelope:
+		return nil
+	case hash:
+		return fmt.Errorf("--envelope cannot be used with --hash")
+	case maxTokensPerFile > 0:
+		return fmt.Errorf("--envelope cannot be used with --max-tokens-per-file")
+	case format != "jsonl":
+		return fmt.Errorf("--envelope cannot be used with --format %s", format)
+	}
+	return nil
+}
+
+// checkFlushFlags reports an error if --flush-every is negative or given
+// without a stream of tokens to flush.
+func checkFlushFlags(flushEvery int, stream bool, maxTokensPerFile int) error {
+	switch {
+	case flushEvery == 0:
+		return nil
+	case flushEvery < 0:
+		return fmt.Errorf("--flush-every must be positive")
+	case !stream:
+		return fmt.Errorf("--flush-every can only be used with --stream")
+	case maxTokensPerFile > 0:
+		return fmt.Errorf("--flush-every cannot be used with --max-tokens-per-file")
+	}
+	return nil
+}
+
+// checkHashFlags reports an error if --hash is combined with an option that
+// also chooses what is written.
+func checkHashFlags(hash, stream bool, format string) error {
+	if !hash {
+		return nil
+	}
+	if stream {
+		return fmt.Errorf("--hash cannot be used with --stream")
+	}
+	if format != "jsonl" {
+		return fmt.Errorf("--hash cannot be used with --format %s", format)
+	}
+	return nil
+}
 
-	// Convert TokenizerRules to RulesFile format
-	rulesFile := &tokenizer.RulesFile{}
+// checkRulesFlags reports an error if the rules flags cannot be used
+// together. Reading the rules from stdin leaves no way to read the input, so
+// the input must then come from files.
+func checkRulesFlags(rulesFile, rulesInline, rulesDir string, inputFromStdin bool) error {
+	if rulesFile != "" && rulesInline != "" {
+		return fmt.Errorf("--rules and --rules-inline cannot be used together")
+	}
+	if rulesDir != "" && (rulesFile == "" || rulesFile == "-") {
+		return fmt.Errorf("--rules-dir needs --rules to name a file within it")
+	}
+	if rulesFile == "-" && inputFromStdin {
+		return fmt.Errorf("--rules - reads the rules from stdin, so --input must name a file")
+	}
+	return nil
+}
 
-	// Convert bracket rules
-	for text, closedBy := range rules.DelimiterMappings {
-		props := rules.DelimiterProperties[text]
-		rulesFile.Bracket = append(rulesFile.Bracket, tokenizer.BracketRule{
-			Text:      text,
-			ClosedBy:  closedBy,
-			InfixPrec: props.InfixPrec,
-			Prefix:    props.Prefix,
-		})
+// source is one input to be tokenized, with the name it is known by.
+type source struct {
+	name  string
+	input string
+}
+
+// manifest is the JSON form of an --input-manifest file.
+type manifest struct {
+	Files []string `json:"files"` // Relative to the manifest's directory
+}
+
+// readManifest reads the files listed in a manifest. Each source is named
+// by its path as given in the manifest.
+func readManifest(filename string) ([]source, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(filename)
+	sources := make([]source, len(m.Files))
+	for i, name := range m.Files {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		input, err := readFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading '%s': %w", name, err)
+		}
+		sources[i] = source{name, input}
 	}
+	return sources, nil
+}
 
-	// Convert prefix rules
-	for text := range rules.PrefixTokens {
-		rulesFile.Prefix = append(rulesFile.Prefix, tokenizer.PrefixRule{
-			Text: text,
-		})
+// tagFile records the file on each token that does not already name one,
+// through a ###line directive.
+func tagFile(tokens []*tokenizer.Token, file string) {
+	for _, token := range tokens {
+		if token.File == "" {
+			token.File = file
+		}
 	}
+}
 
-	// Convert start rules
-	for text, data := range rules.StartTokens {
-		rulesFile.Start = append(rulesFile.Start, tokenizer.StartRule{
-			Text:      text,
-			ClosedBy:  data.ClosedBy,
-			Expecting: data.Expecting, // Include the expecting field as it exists in StartTokenData
-		})
+// progressBar returns a progress function that draws a bar for the named
+// input on w, redrawing it only when the percentage changes, and ends the
+// line when the input is finished.
+func progressBar(w io.Writer, name string) tokenizer.ProgressFunc {
+	if name == "" {
+		name = "stdin"
 	}
+	const width = 30
+	percent := -1
+	return func(done, total int) {
+		p := 100
+		if total > 0 {
+			p = done * 100 / total
+		}
+		if p == percent {
+			return
+		}
+		percent = p
+		filled := width * p / 100
+		fmt.Fprintf(w, "\r%s [%s%s] %3d%% %d/%d bytes", name,
+			strings.Repeat("#", filled), strings.Repeat(" ", width-filled), p, done, total)
+		if done == total {
+			fmt.Fprintln(w)
+		}
+	}
+}
 
-	// Convert bridge rules
-	for text, data := range rules.BridgeTokens {
-		rulesFile.Bridge = append(rulesFile.Bridge, tokenizer.BridgeRule{
-			Text:      text,
-			Expecting: data.Expecting,
-			In:        data.In,
-		})
+// loadRules reads the rules given by --rules or --rules-inline. A rules file
+// of "-" means stdin. If rulesFS is not nil, the rules file is read from it,
+// as a program that embeds its rules would, rather than from the disk.
+func loadRules(rulesFile, rulesInline string, rulesFS fs.FS) (*tokenizer.RulesFile, error) {
+	switch {
+	case rulesInline != "":
+		rules, err := tokenizer.ParseRulesFile([]byte(rulesInline))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse YAML in --rules-inline: %w", err)
+		}
+		return rules, nil
+	case rulesFile == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules from stdin: %w", err)
+		}
+		rules, err := tokenizer.ParseRulesFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse YAML in rules from stdin: %w", err)
+		}
+		return rules, nil
+	case rulesFS != nil:
+		return tokenizer.LoadRulesFS(rulesFS, rulesFile)
+	default:
+		return tokenizer.LoadRulesFile(rulesFile)
 	}
+}
+
+// stringList is a flag that collects the values of every use of it.
+type stringList []string
 
-	// Convert wildcard rules
-	for text := range rules.WildcardTokens {
-		rulesFile.Wildcard = append(rulesFile.Wildcard, tokenizer.WildcardRule{
-			Text: text,
-		})
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// makeTypeFilter builds the token predicate for the --only-types and
+// --exclude-types flags. It returns nil if neither flag was given.
+func makeTypeFilter(onlyTypes, excludeTypes string) (tokenizer.TokenPredicate, error) {
+	if onlyTypes != "" && excludeTypes != "" {
+		return nil, fmt.Errorf("--only-types and --exclude-types cannot be used together")
 	}
+	if onlyTypes != "" {
+		types, err := tokenizer.ParseTokenTypes(onlyTypes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --only-types: %w", err)
+		}
+		return tokenizer.OfTypes(types...), nil
+	}
+	if excludeTypes != "" {
+		types, err := tokenizer.ParseTokenTypes(excludeTypes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --exclude-types: %w", err)
+		}
+		return tokenizer.NotOfTypes(types...), nil
+	}
+	return nil, nil
+}
 
-	// Convert operator rules
-	for text, precedence := range rules.OperatorPrecedences {
-		rulesFile.Operator = append(rulesFile.Operator, tokenizer.OperatorRule{
-			Text:       text,
-			Precedence: precedence,
-		})
+// writeSourceMap writes the source map for the tokens, whose columns count
+// what the policy says, to a JSON file.
+func writeSourceMap(filename, input string, tokens []*tokenizer.Token, policy tokenizer.ColumnPolicy) error {
+	jsonBytes, err := json.Marshal(tokenizer.NewSourceMapWithPolicy(input, tokens, policy))
+	if err != nil {
+		return err
 	}
+	return os.WriteFile(filename, append(jsonBytes, '\n'), 0644)
+}
 
-	// Marshal to YAML and output to stdout
-	yamlBytes, err := yaml.Marshal(rulesFile)
+// writeLineRemaps writes the line remapping table to a JSON file.
+func writeLineRemaps(filename string, remaps tokenizer.LineRemaps) error {
+	jsonBytes, err := json.Marshal(remaps)
 	if err != nil {
-		return fmt.Errorf("failed to marshal rules to YAML: %w", err)
+		return err
 	}
+	return os.WriteFile(filename, append(jsonBytes, '\n'), 0644)
+}
 
-	fmt.Print(string(yamlBytes))
+// printRulesDiff writes the differences between the effective rules of two
+// rules files to stdout, one JSON object per line.
+func printRulesDiff(oldFile, newFile string) error {
+	oldRules, err := loadEffectiveRules(oldFile)
+	if err != nil {
+		return err
+	}
+	newRules, err := loadEffectiveRules(newFile)
+	if err != nil {
+		return err
+	}
+	for _, change := range tokenizer.DiffRules(oldRules, newRules) {
+		jsonBytes, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonBytes))
+	}
 	return nil
 }
+
+// printTokensDiff tokenizes two files and prints the differences between
+// their tokens, one JSON object per line, describing the first of them on
+// stderr. It reports whether there were any.
+func printTokensDiff(oldFile, newFile string, options *tokenizer.Options) (bool, error) {
+	oldTokens, err := tokenizeFile(oldFile, options)
+	if err != nil {
+		return false, err
+	}
+	newTokens, err := tokenizeFile(newFile, options)
+	if err != nil {
+		return false, err
+	}
+	changes := tokenizer.DiffTokens(oldTokens, newTokens)
+	for _, change := range changes {
+		jsonBytes, err := json.Marshal(change)
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(jsonBytes))
+	}
+	if len(changes) > 0 {
+		first := changes[0]
+		fmt.Fprintf(os.Stderr, "First difference: %s at %s, %s at %s\n",
+			oldFile, tokenLocation(oldTokens, first.OldIndex), newFile, tokenLocation(newTokens, first.NewIndex))
+	}
+	return len(changes) > 0, nil
+}
+
+// tokenizeFile reads a file and tokenizes it with the options.
+func tokenizeFile(filename string, options *tokenizer.Options) ([]*tokenizer.Token, error) {
+	input, err := readFromFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file '%s': %w", filename, err)
+	}
+	options.Filename = filename
+	tokens, err := tokenizer.New(input, options).Tokenize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to tokenize '%s': %w", filename, err)
+	}
+	return tokens, nil
+}
+
+// tokenLocation describes where the token at index is, for a message.
+func tokenLocation(tokens []*tokenizer.Token, index int) string {
+	if index >= len(tokens) {
+		return "the end"
+	}
+	start := tokens[index].Span.Start
+	return fmt.Sprintf("%d:%d '%s'", start.Line, start.Col, tokens[index].Text)
+}
+
+// loadEffectiveRules loads a rules file and applies it to the defaults.
+func loadEffectiveRules(filename string) (*tokenizer.TokenizerRules, error) {
+	rules, err := tokenizer.LoadRulesFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	tokenizerRules, err := tokenizer.ApplyRulesToDefaults(rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply rules file '%s': %w", filename, err)
+	}
+	return tokenizerRules, nil
+}
+
+// readFromStdin reads all input from stdin.
+func readFromStdin() (string, error) {
+	bytes, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return bytesToString(bytes), nil
+}
+
+// readFromFile reads the contents of a file.
+func readFromFile(filename string) (string, error) {
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return bytesToString(bytes), nil
+}
+
+// bytesToString returns the bytes as a string without copying them, which
+// matters for large inputs. The bytes must not be changed afterwards, which
+// holds for the freshly read input.
+func bytesToString(bytes []byte) string {
+	return unsafe.String(unsafe.SliceData(bytes), len(bytes))
+}
+
+// generateDefaultConfig outputs the default configuration in YAML format to stdout.
+func generateDefaultConfig() error {
+	// The default rules are read from a rules file, which is printed as it
+	// is so that its comments are kept.
+	_, err := os.Stdout.Write(tokenizer.DefaultRulesYAML())
+	return err
+}
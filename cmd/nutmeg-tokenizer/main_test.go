@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// binary is the command built by TestMain, which the tests run as a user
+// would.
+var binary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "nutmeg-tokenizer-test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot make a temporary directory: %v\n", err)
+		os.Exit(1)
+	}
+	binary = filepath.Join(dir, "nutmeg-tokenizer")
+	if output, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot build the command: %v\n%s", err, output)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// run runs the command with the input on stdin, returning its stderr and
+// exit code.
+func run(t *testing.T, input string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(binary, args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("cannot run the command: %v", err)
+	}
+	return stderr.String(), 0
+}
+
+func TestSelfCheckWithLineDirectives(t *testing.T) {
+	stderr, code := run(t, "###line 10 \"gen.tmpl\"\ndef f(x)\n  x\nend\n", "--self-check")
+	if code != 0 || stderr != "" {
+		t.Errorf("Expected a file with ###line directives to pass, got exit code %d and %q", code, stderr)
+	}
+}
+
+func TestSelfCheckWithTransform(t *testing.T) {
+	stderr, code := run(t, `"a"   "b"`, "--self-check", "--transform", "merge-strings")
+	if code != 1 || !strings.Contains(stderr, "--self-check cannot be used with --transform") {
+		t.Errorf("Expected --self-check to be refused with --transform, got exit code %d and %q", code, stderr)
+	}
+	if strings.Contains(stderr, "Self-check error") {
+		t.Errorf("Expected no self-check errors, got %q", stderr)
+	}
+}
+
+func TestDocMarkerFlag(t *testing.T) {
+	stderr, code := run(t, "x\n", "--doc-marker", "###")
+	if code != 1 || !strings.Contains(stderr, "--doc-marker") {
+		t.Errorf("Expected --doc-marker ### to be refused, got exit code %d and %q", code, stderr)
+	}
+}
@@ -0,0 +1,55 @@
+package tokenizer
+
+// The blocks of a tokenArena start small, so that short inputs stay cheap,
+// and double in size up to a limit.
+const (
+	arenaFirstBlockSize = 16
+	arenaMaxBlockSize   = 256
+)
+
+// tokenArena stores tokens in blocks, so that tokenizing a large
+// input makes one allocation per block rather than one per token. Blocks are
+// never moved once allocated, so pointers into the arena remain valid.
+type tokenArena struct {
+	blocks [][]Token
+	next   int // Index of the block currently being filled
+}
+
+// alloc stores a copy of token in the arena and returns a pointer to the copy.
+// The argument does not escape, so callers may pass the result of one of the
+// NewXxxToken constructors without it being allocated on the heap.
+func (a *tokenArena) alloc(token *Token) *Token {
+	if a.next == len(a.blocks) {
+		size := arenaFirstBlockSize
+		if n := len(a.blocks); n > 0 {
+			size = min(2*cap(a.blocks[n-1]), arenaMaxBlockSize)
+		}
+		a.blocks = append(a.blocks, make([]Token, 0, size))
+	}
+	block := &a.blocks[a.next]
+	*block = append(*block, *token)
+	stored := &(*block)[len(*block)-1]
+	if len(*block) == cap(*block) {
+		a.next++
+	}
+	return stored
+}
+
+// recycle empties the arena so that its blocks can be reused. It must only be
+// called when no pointers into the arena are still in use.
+func (a *tokenArena) recycle() {
+	for i := range a.blocks {
+		// Clearing the used part of each block drops its references to token
+		// text and subtokens, which would otherwise be kept alive.
+		clear(a.blocks[i])
+		a.blocks[i] = a.blocks[i][:0]
+	}
+	a.next = 0
+}
+
+// release abandons the arena's blocks to whoever still holds pointers into
+// them, and starts afresh.
+func (a *tokenArena) release() {
+	a.blocks = nil
+	a.next = 0
+}
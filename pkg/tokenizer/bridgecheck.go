@@ -0,0 +1,56 @@
+package tokenizer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// BridgeCheck says what to do with a bridge token, such as catch, that is not
+// directly within one of the start tokens of its in list, such as try.
+type BridgeCheck int
+
+const (
+	BridgeCheckOff   BridgeCheck = iota // Bridge tokens are not checked
+	BridgeCheckWarn                     // A misplaced bridge token is logged as a warning
+	BridgeCheckError                    // A misplaced bridge token stops tokenizing with an error
+)
+
+// bridgeCheckNames are the names of the bridge checks, indexed by check.
+var bridgeCheckNames = []string{"off", "warn", "error"}
+
+// String returns the name of the bridge check.
+func (c BridgeCheck) String() string {
+	if c >= 0 && int(c) < len(bridgeCheckNames) {
+		return bridgeCheckNames[c]
+	}
+	return fmt.Sprintf("BridgeCheck(%d)", int(c))
+}
+
+// ParseBridgeCheck returns the bridge check with the given name: off, warn
+// or error.
+func ParseBridgeCheck(name string) (BridgeCheck, error) {
+	if i := slices.Index(bridgeCheckNames, name); i >= 0 {
+		return BridgeCheck(i), nil
+	}
+	return BridgeCheckOff, fmt.Errorf("unknown bridge check '%s' (expected off, warn or error)", name)
+}
+
+// SetBridgeCheck controls whether bridge tokens are checked against the start
+// token that encloses them. Warnings go to the logger set by SetLogger.
+func (t *Tokenizer) SetBridgeCheck(check BridgeCheck) {
+	t.bridgeCheck = check
+}
+
+// misplacedBridge returns a description of the problem if the bridge token
+// is not directly within one of the start tokens of its in list, or "" if it
+// is. A bridge token with an empty in list may appear anywhere.
+func (t *Tokenizer) misplacedBridge(token *Token) string {
+	if len(token.In) == 0 {
+		return ""
+	}
+	if n := len(t.expectingStack); n > 0 && slices.Contains(token.In, t.expectingStack[n-1].start) {
+		return ""
+	}
+	return fmt.Sprintf("'%s' is only allowed within %s", token.Text, strings.Join(token.In, ", "))
+}
@@ -0,0 +1,68 @@
+package tokenizer
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestBridgeCheckOff(t *testing.T) {
+	tokens, err := New("catch e", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens[0].Type != BridgeTokenType {
+		t.Errorf("expected an unchecked bridge token, got %s", tokens[0].Type)
+	}
+}
+
+func TestBridgeCheckError(t *testing.T) {
+	options := &Options{BridgeCheck: BridgeCheckError}
+
+	if _, err := New("try f() catch e endtry", options).Tokenize(); err != nil {
+		t.Errorf("unexpected error for catch within try: %v", err)
+	}
+
+	tokens, err := New("if x then catch e endif", options).Tokenize()
+	if err == nil || !strings.Contains(err.Error(), "'catch' is only allowed within try") {
+		t.Fatalf("expected an error for catch within if, got %v", err)
+	}
+	last := tokens[len(tokens)-1]
+	if last.Type != ExceptionTokenType || last.Text != "catch" {
+		t.Errorf("expected catch to become an exception token, got %s %q", last.Type, last.Text)
+	}
+	if !strings.Contains(err.Error(), "line 1, column 11") {
+		t.Errorf("expected the error at catch, got %v", err)
+	}
+}
+
+func TestBridgeCheckWarn(t *testing.T) {
+	var log bytes.Buffer
+	tokenizer := New("do x", &Options{
+		BridgeCheck: BridgeCheckWarn,
+		Logger:      slog.New(slog.NewTextHandler(&log, nil)),
+	})
+	tokens, err := tokenizer.Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens[0].Type != BridgeTokenType {
+		t.Errorf("expected the bridge token to be kept, got %s", tokens[0].Type)
+	}
+	if !strings.Contains(log.String(), `msg="'do' is only allowed within def, for"`) {
+		t.Errorf("expected a warning, got %q", log.String())
+	}
+}
+
+func TestParseBridgeCheck(t *testing.T) {
+	for _, check := range []BridgeCheck{BridgeCheckOff, BridgeCheckWarn, BridgeCheckError} {
+		parsed, err := ParseBridgeCheck(check.String())
+		if err != nil || parsed != check {
+			t.Errorf("expected %s to parse, got %v (%v)", check, parsed, err)
+		}
+	}
+	if _, err := ParseBridgeCheck("strict"); err == nil {
+		t.Errorf("expected an error for an unknown bridge check")
+	}
+}
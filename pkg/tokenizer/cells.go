@@ -0,0 +1,80 @@
+package tokenizer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Cell is the tokens of one cell of a notebook tokenized with TokenizeCells.
+type Cell struct {
+	FirstLine int      // The line of the whole document that the cell starts on
+	Tokens    []*Token // The cell's tokens, with positions in the whole document
+	Local     []Span   // The span of each token relative to the start of the cell
+}
+
+// TokenizeCells tokenizes a list of cells, such as those of a notebook, as
+// one document, so that brackets and start and end tokens may be opened in
+// one cell and closed in a later one. The cells are joined with newlines, and
+// the tokens are returned grouped by the cell they start in. A token that
+// runs on into a later cell, such as an unterminated string, belongs to the
+// cell it starts in. Unlike Tokenize, it also checks that every opener is
+// closed and every closer closes something across the whole document, since
+// a notebook frontend cannot otherwise tell which cell is at fault. As for
+// Tokenize, the tokens before an error are returned along with it, and the
+// error's position can be found in its cell with CellPosition. ###line
+// directives change the reported lines and so should not be used in cells.
+func TokenizeCells(cells []string, opts *Options) ([]Cell, error) {
+	result := make([]Cell, len(cells))
+	line := 1
+	for i, cell := range cells {
+		result[i].FirstLine = line
+		line += strings.Count(cell, "\n") + 1
+	}
+
+	tokens, err := New(strings.Join(cells, "\n"), opts).Tokenize()
+	if err == nil {
+		err = checkBalanced(tokens)
+	}
+	for _, token := range tokens {
+		index, start := CellPosition(result, token.Span.Start)
+		_, end := CellPosition(result[index:index+1], token.Span.End)
+		result[index].Tokens = append(result[index].Tokens, token)
+		result[index].Local = append(result[index].Local, Span{Start: start, End: end})
+	}
+	return result, err
+}
+
+// CellPosition returns the index of the cell containing a position of the
+// document made by TokenizeCells, and the position relative to that cell.
+func CellPosition(cells []Cell, p Position) (int, Position) {
+	// Find the last cell that starts at or before the line.
+	index := sort.Search(len(cells), func(i int) bool {
+		return cells[i].FirstLine > p.Line
+	}) - 1
+	// A position before the first cell cannot come from the tokenizer, but
+	// is clamped to the first cell rather than indexing out of range.
+	index = max(index, 0)
+	return index, Position{Line: p.Line - cells[index].FirstLine + 1, Col: p.Col}
+}
+
+// checkBalanced returns an error at the first opener that is never closed or
+// closer that closes nothing, as paired by MatchBrackets, or nil if there is
+// none.
+func checkBalanced(tokens []*Token) error {
+	paired := make([]bool, len(tokens))
+	matchPairs(tokens, func(opener, closer int) {
+		paired[opener] = true
+		paired[closer] = true
+	})
+	for i, token := range tokens {
+		switch {
+		case paired[i]:
+			// The token is balanced.
+		case IsOpener(token):
+			return errorAt(token.Span, "unclosed '%s'", token.Text)
+		case IsCloser(token):
+			return errorAt(token.Span, "unmatched '%s'", token.Text)
+		}
+	}
+	return nil
+}
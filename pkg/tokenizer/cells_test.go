@@ -0,0 +1,73 @@
+package tokenizer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeCells(t *testing.T) {
+	cells := []string{"x := [1,\n  2", "]\nif x then", "y endif"}
+	result, err := TokenizeCells(cells, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 cells, got %d", len(result))
+	}
+
+	expected := [][]string{{"x", ":=", "[", "1", ",", "2"}, {"]", "if", "x", "then"}, {"y", "endif"}}
+	for i, cell := range result {
+		if got := tokenTexts(cell.Tokens); !reflect.DeepEqual(got, expected[i]) {
+			t.Errorf("Cell %d: expected %v, got %v", i, expected[i], got)
+		}
+	}
+	if got := []int{result[0].FirstLine, result[1].FirstLine, result[2].FirstLine}; !reflect.DeepEqual(got, []int{1, 3, 5}) {
+		t.Errorf("Expected the cells to start on lines 1, 3 and 5, got %v", got)
+	}
+
+	// The bracket closed in the second cell is at line 3 of the document
+	// and line 1 of its cell.
+	closing := result[1]
+	if got := closing.Tokens[0].Span.Start; got != (Position{3, 1}) {
+		t.Errorf("Expected the global position 3:1, got %v", got)
+	}
+	if got := closing.Local[0]; got != (Span{Position{1, 1}, Position{1, 2}}) {
+		t.Errorf("Expected the local span 1:1-1:2, got %v", got)
+	}
+	if got := result[1].Local[1].Start; got != (Position{2, 1}) {
+		t.Errorf("Expected if at 2:1 of its cell, got %v", got)
+	}
+}
+
+func TestTokenizeCellsUnclosed(t *testing.T) {
+	// A start token left open by the last cell is an error of the whole
+	// document, and the tokens before it are still grouped by cell.
+	result, err := TokenizeCells([]string{"if x then", "y"}, nil)
+	var tokErr *Error
+	if !errors.As(err, &tokErr) {
+		t.Fatalf("Expected a tokenisation error, got %v", err)
+	}
+	if got := tokenTexts(result[1].Tokens); !reflect.DeepEqual(got, []string{"y"}) {
+		t.Errorf("Expected [y] in the second cell, got %v", got)
+	}
+
+	if tokErr.Reason != "unclosed 'if'" {
+		t.Errorf("Expected the if to be reported as unclosed, got %q", tokErr.Reason)
+	}
+
+	cell, local := CellPosition(result, Position{2, 2})
+	if cell != 1 || local != (Position{1, 2}) {
+		t.Errorf("Expected cell 1 at 1:2, got cell %d at %v", cell, local)
+	}
+
+	// A closer with nothing to close is reported in its own cell.
+	result, err = TokenizeCells([]string{"x := 1", "f(x))"}, nil)
+	if !errors.As(err, &tokErr) {
+		t.Fatalf("Expected a tokenisation error, got %v", err)
+	}
+	cell, local = CellPosition(result, tokErr.Span.Start)
+	if tokErr.Reason != "unmatched ')'" || cell != 1 || local != (Position{1, 5}) {
+		t.Errorf("Expected an unmatched ')' at 1:5 of cell 1, got %q in cell %d at %v", tokErr.Reason, cell, local)
+	}
+}
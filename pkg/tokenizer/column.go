@@ -0,0 +1,57 @@
+package tokenizer
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ColumnPolicy says what the columns of positions count. The zero value
+// counts characters, which is the default. NewLineIndex, SourceText,
+// ValidateTokens, SemanticTokens and NewSourceMap take columns that count
+// characters; their WithPolicy forms take columns that count what a policy
+// says.
+type ColumnPolicy int
+
+const (
+	ColumnRunes ColumnPolicy = iota // Columns count characters, that is Unicode code points
+	ColumnBytes                     // Columns count bytes, so that they are offsets into the line
+	ColumnUTF16                     // Columns count UTF-16 code units, as the positions of LSP do
+)
+
+// columnPolicyNames are the names of the column policies, indexed by policy.
+var columnPolicyNames = []string{"runes", "bytes", "utf16"}
+
+// String returns the name of the column policy.
+func (p ColumnPolicy) String() string {
+	if p >= 0 && int(p) < len(columnPolicyNames) {
+		return columnPolicyNames[p]
+	}
+	return fmt.Sprintf("ColumnPolicy(%d)", int(p))
+}
+
+// ParseColumnPolicy returns the column policy with the given name: runes,
+// bytes or utf16.
+func ParseColumnPolicy(name string) (ColumnPolicy, error) {
+	if i := slices.Index(columnPolicyNames, name); i >= 0 {
+		return ColumnPolicy(i), nil
+	}
+	return ColumnRunes, fmt.Errorf("unknown column policy '%s' (expected runes, bytes or utf16)", name)
+}
+
+// width returns the number of columns that text on one line takes up. An
+// invalid byte takes up one column, as it is read as one character.
+func (p ColumnPolicy) width(text string) int {
+	switch p {
+	case ColumnRunes:
+		return utf8.RuneCountInString(text)
+	case ColumnUTF16:
+		n := 0
+		for _, r := range text {
+			n += utf16.RuneLen(r)
+		}
+		return n
+	}
+	return len(text)
+}
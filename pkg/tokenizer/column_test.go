@@ -0,0 +1,119 @@
+package tokenizer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestColumnPolicy(t *testing.T) {
+	spans := func(input string, policy ColumnPolicy) []string {
+		t.Helper()
+		tokens, err := New(input, &Options{ColumnPolicy: policy}).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", input, err)
+		}
+		var result []string
+		for _, token := range tokens {
+			result = append(result, fmt.Sprintf("%s %d:%d-%d:%d", token.Text, token.Span.Start.Line, token.Span.Start.Col, token.Span.End.Line, token.Span.End.Col))
+		}
+		return result
+	}
+	tests := []struct {
+		input    string
+		policy   ColumnPolicy
+		expected []string
+	}{
+		{"«héllo» + 'ñame'", ColumnBytes, []string{"«héllo» 1:1-1:11", "+ 1:12-1:13", "'ñame' 1:14-1:21"}},
+		{"«héllo» + 'ñame'", ColumnRunes, []string{"«héllo» 1:1-1:8", "+ 1:9-1:10", "'ñame' 1:11-1:17"}},
+		{"«héllo» + 'ñame'", ColumnUTF16, []string{"«héllo» 1:1-1:8", "+ 1:9-1:10", "'ñame' 1:11-1:17"}},
+		{"x := \"😀\" y", ColumnBytes, []string{"x 1:1-1:2", ":= 1:3-1:5", "\"😀\" 1:6-1:12", "y 1:13-1:14"}},
+		{"x := \"😀\" y", ColumnRunes, []string{"x 1:1-1:2", ":= 1:3-1:5", "\"😀\" 1:6-1:9", "y 1:10-1:11"}},
+		{"x := \"😀\" y", ColumnUTF16, []string{"x 1:1-1:2", ":= 1:3-1:5", "\"😀\" 1:6-1:10", "y 1:11-1:12"}},
+		{"f(«α»)\n  ### ⍝ β\ng", ColumnRunes, []string{"f 1:1-1:2", "( 1:2-1:3", "«α» 1:3-1:6", ") 1:6-1:7", "g 3:1-3:2"}},
+		{"«««\n  «é»\n  »»»\nñ", ColumnRunes, []string{"«««\n  «é»\n  »»» 1:1-3:6", "ñ 4:1-4:2"}},
+	}
+	for _, test := range tests {
+		if got := spans(test.input, test.policy); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("For %q with %s expected %v, got %v", test.input, test.policy, test.expected, got)
+		}
+	}
+}
+
+func TestColumnPolicyDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  Options
+		expected []string
+	}{
+		{"éééééé\n", Options{MaxLineLength: 4}, []string{"1:5-1:7 line is 6 characters long, more than 4"}},
+		{"éééééé\n", Options{MaxLineLength: 4, ColumnPolicy: ColumnBytes}, []string{"1:9-1:13 line is 6 characters long, more than 4"}},
+		{"😀😀😀\n", Options{MaxLineLength: 2, ColumnPolicy: ColumnUTF16}, []string{"1:5-1:7 line is 3 characters long, more than 2"}},
+		{"«é»  \nx", Options{WarnTrailingWhitespace: true, ColumnPolicy: ColumnRunes}, []string{"1:4-1:6 trailing whitespace"}},
+	}
+	for _, test := range tests {
+		result, err := New(test.input, &test.options).TokenizeResult()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		var messages []string
+		for _, d := range result.Diagnostics {
+			messages = append(messages, fmt.Sprintf("%d:%d-%d:%d %s", d.Span.Start.Line, d.Span.Start.Col, d.Span.End.Line, d.Span.End.Col, d.Message))
+		}
+		if !reflect.DeepEqual(messages, test.expected) {
+			t.Errorf("For %q with %s expected %v, got %v", test.input, test.options.ColumnPolicy, test.expected, messages)
+		}
+	}
+
+	// An error after multibyte characters is placed in the same columns as
+	// the tokens.
+	_, err := New("«é» \"x", &Options{ColumnPolicy: ColumnRunes}).Tokenize()
+	if tokErr, ok := err.(*Error); !ok || tokErr.Span.Start != (Position{1, 5}) {
+		t.Errorf("Expected an error at 1:5, got %v", err)
+	}
+}
+
+func TestParseColumnPolicy(t *testing.T) {
+	for _, policy := range []ColumnPolicy{ColumnBytes, ColumnRunes, ColumnUTF16} {
+		if got, err := ParseColumnPolicy(policy.String()); err != nil || got != policy {
+			t.Errorf("Expected %s to parse back, got %v, %v", policy, got, err)
+		}
+	}
+	if _, err := ParseColumnPolicy("chars"); err == nil {
+		t.Errorf("Expected an error for an unknown column policy")
+	}
+}
+
+func TestColumnPolicyHelpers(t *testing.T) {
+	input := "«héllo» + \"😀\"\nñ := x"
+	for _, policy := range []ColumnPolicy{ColumnBytes, ColumnRunes, ColumnUTF16} {
+		tokenizer := New(input, &Options{ColumnPolicy: policy})
+		tokens, err := tokenizer.Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error with %s: %v", policy, err)
+		}
+		if errs := ValidateTokensWithPolicy(tokens, input, policy); len(errs) != 0 {
+			t.Errorf("With %s expected no span errors, got %v", policy, errs)
+		}
+		index := tokenizer.LineIndex()
+		sourceMap := NewSourceMapWithPolicy(input, tokens, policy)
+		for i, token := range tokens {
+			if got := token.SourceTextWithPolicy(input, policy); got != token.Text {
+				t.Errorf("With %s expected the source text %q, got %q", policy, token.Text, got)
+			}
+			if got := index.Position(sourceMap.Tokens[i][0]); got != token.Span.Start {
+				t.Errorf("With %s expected %q to start at %v, got %v", policy, token.Text, token.Span.Start, got)
+			}
+		}
+	}
+
+	// A column between the two UTF-16 code units of an emoji is within no
+	// character.
+	index := NewLineIndexWithPolicy("\"😀\"", ColumnUTF16)
+	if got := index.Offset(Position{1, 3}); got != -1 {
+		t.Errorf("Expected -1 for a column within a character, got %d", got)
+	}
+	if got := index.Offset(Position{1, 4}); got != 5 {
+		t.Errorf("Expected the offset 5 after the emoji, got %d", got)
+	}
+}
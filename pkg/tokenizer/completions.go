@@ -0,0 +1,88 @@
+package tokenizer
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// Completions is the data an editor needs to offer keyword completions, such
+// as the closing keyword of the innermost open form, taken from a set of
+// rules. Every list is sorted.
+type Completions struct {
+	Keywords map[string][]string         `json:"keywords"` // Token texts by rules file section, plus "end"
+	Starts   map[string]StartCompletion  `json:"starts"`
+	Bridges  map[string]BridgeCompletion `json:"bridges"`
+	Brackets map[string][]string         `json:"brackets"` // The closers of each open bracket
+}
+
+// StartCompletion is what may follow a start token.
+type StartCompletion struct {
+	Expecting []string `json:"expecting"` // The labels the start token expects
+	Bridges   []string `json:"bridges"`   // The bridge tokens allowed within the form
+	ClosedBy  []string `json:"closed_by"`
+}
+
+// BridgeCompletion is what may follow a bridge token, and where it may be.
+type BridgeCompletion struct {
+	Expecting []string `json:"expecting"`
+	In        []string `json:"in"` // The start tokens whose forms it may appear in
+}
+
+// NewCompletions gathers the completion data of the rules, or of the default
+// rules if rules is nil.
+func NewCompletions(rules *TokenizerRules) *Completions {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	c := &Completions{
+		Keywords: map[string][]string{},
+		Starts:   map[string]StartCompletion{},
+		Bridges:  map[string]BridgeCompletion{},
+		Brackets: map[string][]string{},
+	}
+
+	bridgesIn := map[string][]string{}
+	for text, data := range rules.BridgeTokens {
+		c.Bridges[text] = BridgeCompletion{sortedCopy(data.Expecting), sortedCopy(data.In)}
+		for _, start := range data.In {
+			bridgesIn[start] = append(bridgesIn[start], text)
+		}
+	}
+	ends := map[string]bool{}
+	for text, data := range rules.StartTokens {
+		c.Starts[text] = StartCompletion{sortedCopy(data.Expecting), sortedCopy(bridgesIn[text]), sortedCopy(data.ClosedBy)}
+		for _, end := range data.ClosedBy {
+			ends[end] = true
+		}
+	}
+	for text, closedBy := range rules.DelimiterMappings {
+		c.Brackets[text] = sortedCopy(closedBy)
+	}
+
+	c.Keywords["start"] = sortedKeys(rules.StartTokens)
+	c.Keywords["end"] = sortedKeys(ends)
+	c.Keywords["bridge"] = sortedKeys(rules.BridgeTokens)
+	c.Keywords["prefix"] = sortedKeys(rules.PrefixTokens)
+	c.Keywords["wildcard"] = sortedKeys(rules.WildcardTokens)
+	c.Keywords["operator"] = sortedKeys(rules.OperatorPrecedences)
+	c.Keywords["bracket"] = sortedKeys(rules.DelimiterMappings)
+	c.Keywords["mark"] = sortedKeys(rules.MarkTokens)
+	c.Keywords["sigil"] = sortedKeys(rules.SigilRoles)
+	return c
+}
+
+// sortedCopy returns a sorted copy of the list, which is empty rather than
+// nil so that it prints as [].
+func sortedCopy(list []string) []string {
+	sorted := append([]string{}, list...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// sortedKeys returns the keys of the map in sorted order. Iterating over
+// these rather than the map keeps anything derived from it deterministic.
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,53 @@
+package tokenizer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewCompletions(t *testing.T) {
+	c := NewCompletions(nil)
+
+	ifStart, ok := c.Starts["if"]
+	if !ok {
+		t.Fatalf("expected completions for if")
+	}
+	if !slices.Equal(ifStart.Expecting, []string{"then"}) {
+		t.Errorf("expected if to expect then, got %v", ifStart.Expecting)
+	}
+	if !slices.Contains(ifStart.ClosedBy, "endif") {
+		t.Errorf("expected if to be closed by endif, got %v", ifStart.ClosedBy)
+	}
+	if !slices.Contains(ifStart.Bridges, "elseif") || slices.Contains(ifStart.Bridges, "case") {
+		t.Errorf("expected elseif but not case as bridges of if, got %v", ifStart.Bridges)
+	}
+	if !slices.Equal(c.Bridges["case"].In, []string{"switch"}) {
+		t.Errorf("expected case to be in switch, got %v", c.Bridges["case"].In)
+	}
+	if !slices.Contains(c.Keywords["end"], "endif") {
+		t.Errorf("expected endif among the end keywords, got %v", c.Keywords["end"])
+	}
+	if !slices.Equal(c.Brackets["("], []string{")"}) {
+		t.Errorf("expected ( to be closed by ), got %v", c.Brackets["("])
+	}
+}
+
+func TestNewCompletionsCustomRules(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Start: []StartRule{{Text: "unless", ClosedBy: []string{"endunless"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := NewCompletions(rules)
+	unless := c.Starts["unless"]
+	if unless.Expecting == nil || unless.Bridges == nil {
+		t.Errorf("expected empty lists rather than nil, got %+v", unless)
+	}
+	if !slices.Equal(unless.ClosedBy, []string{"endunless"}) {
+		t.Errorf("expected unless to be closed by endunless, got %v", unless.ClosedBy)
+	}
+	if _, ok := c.Starts["if"]; ok {
+		t.Errorf("expected custom start rules to replace the defaults")
+	}
+}
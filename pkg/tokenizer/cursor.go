@@ -0,0 +1,103 @@
+package tokenizer
+
+import "slices"
+
+// TokenCursor steps through a token stream, as a parser does. The position
+// is the index of the next token to be read, from 0 up to the number of
+// tokens.
+type TokenCursor struct {
+	tokens   []*Token
+	position int
+	marks    []int // Stack of marked positions
+}
+
+// NewTokenCursor creates a cursor at the start of the tokens.
+func NewTokenCursor(tokens []*Token) *TokenCursor {
+	return &TokenCursor{tokens: tokens}
+}
+
+// Peek returns the next token without moving, or nil at the end.
+func (c *TokenCursor) Peek() *Token {
+	if c.position < len(c.tokens) {
+		return c.tokens[c.position]
+	}
+	return nil
+}
+
+// Next returns the next token and moves past it, or returns nil at the end.
+func (c *TokenCursor) Next() *Token {
+	token := c.Peek()
+	if token != nil {
+		c.position++
+	}
+	return token
+}
+
+// Prev moves back over the previous token and returns it, or returns nil at
+// the start.
+func (c *TokenCursor) Prev() *Token {
+	if c.position == 0 {
+		return nil
+	}
+	c.position--
+	return c.tokens[c.position]
+}
+
+// Position returns the index of the next token.
+func (c *TokenCursor) Position() int {
+	return c.position
+}
+
+// AtEnd reports whether every token has been read.
+func (c *TokenCursor) AtEnd() bool {
+	return c.position >= len(c.tokens)
+}
+
+// Seek moves to the given index, clamped to the bounds of the tokens.
+func (c *TokenCursor) Seek(index int) {
+	c.position = max(0, min(index, len(c.tokens)))
+}
+
+// Mark saves the position, so that a parser can look ahead and then Reset.
+// Marks nest.
+func (c *TokenCursor) Mark() {
+	c.marks = append(c.marks, c.position)
+}
+
+// Reset returns to the most recent mark and forgets it. It does nothing if
+// there is no mark.
+func (c *TokenCursor) Reset() {
+	if len(c.marks) == 0 {
+		return
+	}
+	n1 := len(c.marks) - 1
+	c.position = c.marks[n1]
+	c.marks = c.marks[:n1]
+}
+
+// Unmark forgets the most recent mark without moving, once the look-ahead
+// has succeeded. It does nothing if there is no mark.
+func (c *TokenCursor) Unmark() {
+	if len(c.marks) > 0 {
+		c.marks = c.marks[:len(c.marks)-1]
+	}
+}
+
+// IsOpener reports whether the token opens a nested region: a start token
+// or an open bracket. It can be used as a TokenPredicate.
+func IsOpener(token *Token) bool {
+	return token.Type == StartTokenType || token.Type == OpenDelimiterTokenType
+}
+
+// IsCloser reports whether the token closes a nested region: an end token or
+// a close bracket. It can be used as a TokenPredicate.
+func IsCloser(token *Token) bool {
+	return token.Type == EndTokenType || token.Type == CloseDelimiterTokenType
+}
+
+// MatchesClosedBy reports whether the closer is one of the tokens that can
+// close the opener. A closer with an alias, such as the :) digraph of }, is
+// known by its alias.
+func MatchesClosedBy(opener, closer *Token) bool {
+	return IsCloser(closer) && slices.Contains(opener.ClosedBy, closer.CanonicalText())
+}
@@ -0,0 +1,75 @@
+package tokenizer
+
+import "testing"
+
+func TestTokenCursor(t *testing.T) {
+	tokens, err := New("f(x) + y", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := NewTokenCursor(tokens)
+
+	text := func(token *Token) string {
+		if token == nil {
+			return "<nil>"
+		}
+		return token.Text
+	}
+	check := func(step, got, want string) {
+		t.Helper()
+		if got != want {
+			t.Errorf("%s: expected %q, got %q", step, want, got)
+		}
+	}
+
+	check("Prev at start", text(c.Prev()), "<nil>")
+	check("Peek", text(c.Peek()), "f")
+	check("Next", text(c.Next()), "f")
+	check("Next", text(c.Next()), "(")
+
+	c.Mark()
+	check("Next after Mark", text(c.Next()), "x")
+	c.Mark()
+	c.Next()
+	c.Reset()
+	check("Peek after inner Reset", text(c.Peek()), ")")
+	c.Reset()
+	check("Peek after outer Reset", text(c.Peek()), "x")
+
+	c.Mark()
+	c.Next()
+	c.Unmark()
+	c.Reset() // No mark is left, so this does nothing.
+	check("Peek after Unmark", text(c.Peek()), ")")
+
+	check("Prev", text(c.Prev()), "x")
+	c.Seek(100)
+	if !c.AtEnd() || c.Position() != len(tokens) {
+		t.Errorf("expected Seek past the end to clamp, got position %d", c.Position())
+	}
+	check("Next at end", text(c.Next()), "<nil>")
+	check("Prev from end", text(c.Prev()), "y")
+	c.Seek(-1)
+	check("Peek after Seek(-1)", text(c.Peek()), "f")
+}
+
+func TestOpenerCloserPredicates(t *testing.T) {
+	tokens, err := New("if (x) then y endif", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ifToken, openParen, closeParen, endif := tokens[0], tokens[1], tokens[3], tokens[6]
+
+	if !IsOpener(ifToken) || !IsOpener(openParen) || IsOpener(closeParen) || IsOpener(tokens[2]) {
+		t.Errorf("IsOpener gave the wrong answer")
+	}
+	if !IsCloser(endif) || !IsCloser(closeParen) || IsCloser(openParen) || IsCloser(tokens[2]) {
+		t.Errorf("IsCloser gave the wrong answer")
+	}
+	if !MatchesClosedBy(ifToken, endif) || !MatchesClosedBy(openParen, closeParen) {
+		t.Errorf("expected openers to match their closers")
+	}
+	if MatchesClosedBy(ifToken, closeParen) || MatchesClosedBy(openParen, endif) {
+		t.Errorf("expected openers not to match other closers")
+	}
+}
@@ -0,0 +1,79 @@
+package tokenizer
+
+import "fmt"
+
+// tokenizeReplacement tokenizes the replacement text of a define with the
+// given rules. Defines are not expanded within it.
+func tokenizeReplacement(rules *TokenizerRules, replacement string) ([]*Token, error) {
+	sub := New(replacement, &Options{Rules: rules})
+	sub.expanding = true
+	tokens, err := sub.Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("the replacement has no tokens")
+	}
+	return tokens, nil
+}
+
+// checkDefines reports an error if the replacement of any define cannot be
+// tokenized, so that mistakes are found when the rules are loaded rather
+// than when a define is first used. The replacements are tokenized with a
+// Clone of the rules, because New freezes the rules it is given and the rules
+// being checked are still being built.
+func checkDefines(rules *TokenizerRules) error {
+	if len(rules.Defines) == 0 {
+		return nil
+	}
+	check := rules.Clone()
+	for _, text := range sortedKeys(rules.Defines) {
+		if _, err := tokenizeReplacement(check, rules.Defines[text]); err != nil {
+			return fmt.Errorf("define '%s': %w", text, err)
+		}
+	}
+	return nil
+}
+
+// expandDefine replaces a defined token with the tokens of its replacement.
+// The first of those is returned and the rest are left in t.expansion, to be
+// added after it. Every token of the expansion takes the span of the defined
+// token, which is also recorded as the span it was expanded from.
+func (t *Tokenizer) expandDefine(text, replacement string, span Span) *Token {
+	tokens, err := tokenizeReplacement(t.rules, replacement)
+	if err != nil {
+		// Replacements are checked when rules are loaded from a rules file,
+		// so this is only reached with rules that were built by hand.
+		return t.arena.alloc(NewExceptionToken(text, fmt.Sprintf("invalid define: %v", err), span))
+	}
+	expandedFrom := span
+	for _, token := range tokens {
+		relocate(token, span)
+		token.ExpandedFrom = &expandedFrom
+		// Newlines in the replacement are not newlines in the input.
+		token.LnBefore = nil
+		token.LnAfter = nil
+	}
+	t.expansion = tokens[1:]
+	return tokens[0]
+}
+
+// relocate gives a token and its subtokens the span.
+func relocate(token *Token, span Span) {
+	token.Span = span
+	for _, subtoken := range token.Subtokens {
+		relocate(subtoken, span)
+	}
+}
+
+// addExpansion adds the tokens left over from expanding a define.
+func (t *Tokenizer) addExpansion() error {
+	expansion := t.expansion
+	t.expansion = nil
+	for _, token := range expansion {
+		if err := t.addTokenAndManageStack(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
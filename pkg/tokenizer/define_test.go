@@ -0,0 +1,80 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefine(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{Define: []DefineRule{
+		{Text: "unless", As: "if not"},
+		{Text: "endunless", As: "endif"},
+		{Text: "not", As: "!"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Checking the replacements does not freeze the rules, which are only
+	// frozen once they are used.
+	if rules.Frozen() {
+		t.Errorf("expected the rules not to be frozen until they are used")
+	}
+	tokens, err := New("unless x then y\nendunless", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	unless := Span{Position{1, 1}, Position{1, 7}}
+	endunless := Span{Position{2, 1}, Position{2, 10}}
+	expected := []struct {
+		text         string
+		tokenType    TokenType
+		span         Span
+		expandedFrom *Span
+	}{
+		{"if", StartTokenType, unless, &unless},
+		// Defines are not expanded within a replacement.
+		{"not", VariableTokenType, unless, &unless},
+		{"x", VariableTokenType, Span{Position{1, 8}, Position{1, 9}}, nil},
+		{"then", BridgeTokenType, Span{Position{1, 10}, Position{1, 14}}, nil},
+		{"y", VariableTokenType, Span{Position{1, 15}, Position{1, 16}}, nil},
+		{"endif", EndTokenType, endunless, &endunless},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, want := range expected {
+		got := tokens[i]
+		if got.Text != want.text || got.Type != want.tokenType || got.Span != want.span {
+			t.Errorf("token %d: expected %q %s %v, got %q %s %v",
+				i, want.text, want.tokenType, want.span, got.Text, got.Type, got.Span)
+		}
+		if (got.ExpandedFrom == nil) != (want.expandedFrom == nil) ||
+			(got.ExpandedFrom != nil && *got.ExpandedFrom != *want.expandedFrom) {
+			t.Errorf("token %d: expected expanded_from %v, got %v", i, want.expandedFrom, got.ExpandedFrom)
+		}
+	}
+	if tokens[4].LnAfter == nil || !*tokens[4].LnAfter {
+		t.Errorf("expected the newline before a define to be recorded")
+	}
+}
+
+func TestDefineErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		define DefineRule
+		errMsg string
+	}{
+		{"empty replacement", DefineRule{Text: "nothing", As: "  "}, "define 'nothing': the replacement has no tokens"},
+		{"bad replacement", DefineRule{Text: "bad", As: `"unterminated`}, "define 'bad':"},
+		{"conflict", DefineRule{Text: "if", As: "when"}, "token 'if' is defined in both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ApplyRulesToDefaults(&RulesFile{Define: []DefineRule{tt.define}})
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
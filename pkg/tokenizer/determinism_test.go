@@ -0,0 +1,90 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"testing"
+)
+
+// determinismInput exercises the tokens whose attributes are derived from the
+// rule maps: wildcards, end tokens, close delimiters and defines.
+const determinismInput = `def f(x) : unless x then [g(x)} : endunless endif
+try y := "a\(b)" catch e : end
+if a then b else c :`
+
+// shuffledRulesFile returns a rules file with the same rules in an order
+// chosen by the seed.
+func shuffledRulesFile(seed uint64) *RulesFile {
+	file := &RulesFile{
+		Bracket: []BracketRule{
+			{Text: "(", ClosedBy: []string{")"}, Prefix: true},
+			{Text: "[", ClosedBy: []string{"]", "}"}, Prefix: true},
+			{Text: "{", ClosedBy: []string{"}", "]"}, Prefix: true},
+		},
+		Start: []StartRule{
+			{Text: "def", Expecting: []string{"=>>"}, ClosedBy: []string{"end", "enddef"}},
+			{Text: "if", Expecting: []string{"then"}, ClosedBy: []string{"end", "endif"}},
+			{Text: "try", Expecting: []string{"catch", "else"}, ClosedBy: []string{"end", "endtry"}},
+		},
+		Bridge: []BridgeRule{
+			{Text: "=>>", Expecting: []string{"end", "enddef"}, In: []string{"def"}},
+			{Text: "then", Expecting: []string{"else", "end", "endif"}, In: []string{"if"}},
+			{Text: "else", Expecting: []string{"end", "endif", "endtry"}, In: []string{"if", "try"}},
+			{Text: "catch", Expecting: []string{"end", "endtry"}, In: []string{"try"}},
+		},
+		Wildcard: []WildcardRule{{Text: ":"}},
+		Define: []DefineRule{
+			{Text: "unless", As: "if not"},
+			{Text: "endunless", As: "endif"},
+		},
+	}
+	r := rand.New(rand.NewPCG(seed, seed))
+	r.Shuffle(len(file.Bracket), func(i, j int) { file.Bracket[i], file.Bracket[j] = file.Bracket[j], file.Bracket[i] })
+	r.Shuffle(len(file.Start), func(i, j int) { file.Start[i], file.Start[j] = file.Start[j], file.Start[i] })
+	r.Shuffle(len(file.Bridge), func(i, j int) { file.Bridge[i], file.Bridge[j] = file.Bridge[j], file.Bridge[i] })
+	r.Shuffle(len(file.Define), func(i, j int) { file.Define[i], file.Define[j] = file.Define[j], file.Define[i] })
+	return file
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	var expected string
+	for seed := uint64(0); seed < 50; seed++ {
+		rules, err := ApplyRulesToDefaults(shuffledRulesFile(seed))
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+		// Each seed builds its own rules, and so its own maps, which Go
+		// iterates over in a different order each time.
+		tokens, err := New(determinismInput, &Options{Rules: rules, AnnotateContext: true}).Tokenize()
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+		data, err := json.Marshal(tokens)
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+		if seed == 0 {
+			expected = string(data)
+		} else if string(data) != expected {
+			t.Fatalf("seed %d: tokens differ from seed 0:\n%s\n%s", seed, data, expected)
+		}
+	}
+}
+
+func TestDeterministicRuleErrors(t *testing.T) {
+	var expected string
+	for seed := uint64(0); seed < 50; seed++ {
+		file := shuffledRulesFile(seed)
+		// Several tokens conflict, but the same one is always reported.
+		file.Prefix = []PrefixRule{{Text: "then"}, {Text: "catch"}, {Text: "else"}}
+		_, err := ApplyRulesToDefaults(file)
+		if err == nil {
+			t.Fatalf("seed %d: expected a conflict", seed)
+		}
+		if seed == 0 {
+			expected = err.Error()
+		} else if err.Error() != expected {
+			t.Fatalf("seed %d: expected %q, got %q", seed, expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Severity says how serious a diagnostic is.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota // The input was tokenized, perhaps not as intended
+	SeverityError                   // Tokenizing stopped, or went on past an exception token
+)
+
+// severityNames are the names of the severities, indexed by severity.
+var severityNames = []string{"warning", "error"}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText writes the severity as its name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a severity from its name.
+func (s *Severity) UnmarshalText(text []byte) error {
+	if i := slices.Index(severityNames, string(text)); i >= 0 {
+		*s = Severity(i)
+		return nil
+	}
+	return fmt.Errorf("invalid severity '%s' (expected warning or error)", text)
+}
+
+// Diagnostic is a problem found in the input.
+type Diagnostic struct {
+	Span     Span     `json:"span"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Result is the outcome of tokenizing an input: the tokens together with the
+// diagnostics about it, in the order they were found.
+type Result struct {
+	Tokens      []*Token     `json:"tokens"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// TokenizeResult processes the input like Tokenize, but also returns the
+// diagnostics. The conditions that are only warned about, such as an unknown
+// escape sequence, a wildcard with no expected label to stand for or sign
+// characters that are not a known operator, are warnings unless the
+// tokenizer is strict, when the first of them stops tokenizing with an
+// error. An error that stops tokenizing is returned, and is also the last
+// diagnostic. In keep-going mode the errors gone on past are diagnostics
+// where they were found, and the first of them is returned.
+func (t *Tokenizer) TokenizeResult() (*Result, error) {
+	tokens, err := t.Tokenize()
+	result := &Result{Tokens: tokens, Diagnostics: t.diagnostics}
+	var tokenizeErr *Error
+	if errors.As(err, &tokenizeErr) && err != t.keepGoingErr {
+		result.Diagnostics = append(result.Diagnostics, Diagnostic{tokenizeErr.Span, SeverityError, tokenizeErr.Reason})
+	}
+	return result, err
+}
+
+// SetStrict controls whether conditions that are normally only warned about
+// stop tokenizing with an error.
+func (t *Tokenizer) SetStrict(strict bool) {
+	t.strict = strict
+}
@@ -0,0 +1,54 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeResultCollectsWarnings(t *testing.T) {
+	result, err := New(`"a\qb" :`, nil).TokenizeResult()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Tokens) != 2 {
+		t.Errorf("expected 2 tokens, got %d", len(result.Tokens))
+	}
+	expected := []Diagnostic{
+		{Span{Position{1, 3}, Position{1, 5}}, SeverityWarning, "unknown escape sequence"},
+		{Span{Position{1, 8}, Position{1, 9}}, SeverityWarning, "wildcard has no expected label to stand for"},
+	}
+	if !reflect.DeepEqual(result.Diagnostics, expected) {
+		t.Errorf("expected %v, got %v", expected, result.Diagnostics)
+	}
+}
+
+func TestStrictTurnsWarningsIntoErrors(t *testing.T) {
+	result, err := New("x := 1 ~~ y", &Options{Strict: true}).TokenizeResult()
+	var tokenizeErr *Error
+	if !errors.As(err, &tokenizeErr) || tokenizeErr.Reason != "sign characters are not a known operator" {
+		t.Fatalf("expected an unknown operator error, got %v", err)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Severity != SeverityError {
+		t.Errorf("expected a single error diagnostic, got %v", result.Diagnostics)
+	}
+	// Tokenizing stops at the token that was warned about.
+	if last := result.Tokens[len(result.Tokens)-1]; last.Text != "~" {
+		t.Errorf("expected to stop at '~', got %q", last.Text)
+	}
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	data, err := json.Marshal(Diagnostic{Span{Position{1, 2}, Position{1, 4}}, SeverityError, "oops"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != `{"span":[1,2,1,4],"severity":"error","message":"oops"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var severity Severity
+	if err := severity.UnmarshalText([]byte("warning")); err != nil || severity != SeverityWarning {
+		t.Errorf("expected warning, got %v (%v)", severity, err)
+	}
+}
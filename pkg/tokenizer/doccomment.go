@@ -0,0 +1,42 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultDocMarker is the prefix that marks a comment as a doc comment unless
+// Options.DocMarker says otherwise. Because it begins with the ordinary
+// comment marker, tools that do not know about doc comments skip them.
+const DefaultDocMarker = "####"
+
+// CheckDocMarker reports an error if the marker cannot mark doc comments. It
+// must begin with the comment marker ###, so that doc comments are still
+// comments, and be longer than it, so that not every comment is a doc
+// comment.
+func CheckDocMarker(marker string) error {
+	if !strings.HasPrefix(marker, "###") || marker == "###" {
+		return fmt.Errorf("doc marker %q must begin with ### and be longer than it", marker)
+	}
+	return nil
+}
+
+// docCommentText returns the text of a doc comment line, without the marker
+// and the single space that conventionally follows it.
+func docCommentText(line, marker string) string {
+	text := strings.TrimPrefix(line, marker)
+	text = strings.TrimPrefix(text, " ")
+	return strings.TrimRight(text, " \t")
+}
+
+// attachDoc gives the token the doc comments that came before it, one line
+// per comment line. Doc comments at the end of the input have no token to be
+// attached to and are dropped.
+func (t *Tokenizer) attachDoc(token *Token) {
+	if len(t.doc) == 0 {
+		return
+	}
+	doc := strings.Join(t.doc, "\n")
+	token.Doc = &doc
+	t.doc = t.doc[:0]
+}
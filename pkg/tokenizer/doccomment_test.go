@@ -0,0 +1,70 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		marker   string
+		expected map[int]string // Doc by token index
+	}{
+		{
+			name:     "Single line",
+			input:    "#### Adds one.\ndef f(x) x + 1 end",
+			expected: map[int]string{0: "Adds one."},
+		},
+		{
+			name:     "Several lines",
+			input:    "x\n#### First.\n####\n####   Indented.\ny",
+			expected: map[int]string{1: "First.\n\n  Indented."},
+		},
+		{
+			name:     "Ordinary comments are not docs",
+			input:    "### Not a doc.\nx",
+			expected: map[int]string{},
+		},
+		{
+			name:     "Trailing doc is dropped",
+			input:    "x\n#### Nothing follows.\n",
+			expected: map[int]string{},
+		},
+		{
+			name:     "Custom marker",
+			input:    "###! Doc.\n#### Not a doc.\nx",
+			marker:   "###!",
+			expected: map[int]string{0: "Doc."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := New(tt.input, &Options{DocMarker: tt.marker}).Tokenize()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i, token := range tokens {
+				want, hasDoc := tt.expected[i]
+				switch {
+				case !hasDoc && token.Doc != nil:
+					t.Errorf("token %d %q: expected no doc, got %q", i, token.Text, *token.Doc)
+				case hasDoc && token.Doc == nil:
+					t.Errorf("token %d %q: expected doc %q, got none", i, token.Text, want)
+				case hasDoc && *token.Doc != want:
+					t.Errorf("token %d %q: expected doc %q, got %q", i, token.Text, want, *token.Doc)
+				}
+			}
+		})
+	}
+}
+
+func TestDocMarkerErrors(t *testing.T) {
+	for _, marker := range []string{"###", "#", "//", "##!"} {
+		_, err := New("x", &Options{DocMarker: marker}).Tokenize()
+		if err == nil || !strings.Contains(err.Error(), "must begin with ###") {
+			t.Errorf("%q: expected the marker to be rejected, got %v", marker, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package tokenizer
+
+// addEndOfFile adds the end of file token at the end of the input, listing
+// the openers that are still open. Like any other token, it has ln_before if
+// a newline comes before it.
+func (t *Tokenizer) addEndOfFile() {
+	end := t.here()
+	token := t.arena.alloc(NewToken("", EndOfFileTokenType, Span{end, end}))
+	if t.newlineAtEnd {
+		lnBefore := true
+		token.LnBefore = &lnBefore
+	}
+	token.Unclosed = unclosedOpeners(t.tokens)
+	t.attachFile(token)
+	t.tokens = append(t.tokens, token)
+}
+
+// unclosedOpeners returns the texts of the openers among the tokens that are
+// not closed, as paired by MatchBrackets, outermost first.
+func unclosedOpeners(tokens []*Token) []string {
+	paired := make([]bool, len(tokens))
+	matchPairs(tokens, func(opener, _ int) {
+		paired[opener] = true
+	})
+	var unclosed []string
+	for i, token := range tokens {
+		if IsOpener(token) && !paired[i] {
+			unclosed = append(unclosed, token.Text)
+		}
+	}
+	return unclosed
+}
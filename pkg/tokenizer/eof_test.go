@@ -0,0 +1,71 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEndOfFileToken(t *testing.T) {
+	tests := []struct {
+		input    string
+		end      Position
+		unclosed []string
+	}{
+		{"def f(x) =>>\n  g([1,\n", Position{3, 1}, []string{"def", "(", "["}},
+		{"if x then y endif", Position{1, 18}, nil},
+		{"f(x]", Position{1, 5}, []string{"("}},
+		{"", Position{1, 1}, nil},
+	}
+	for _, test := range tests {
+		tokens, err := New(test.input, &Options{EndOfFileToken: true}).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		eof := tokens[len(tokens)-1]
+		if eof.Type != EndOfFileTokenType || eof.Text != "" || eof.Span != (Span{test.end, test.end}) {
+			t.Errorf("For %q expected an end of file token at %v, got %s %q at %v", test.input, test.end, eof.Type, eof.Text, eof.Span)
+		}
+		if !reflect.DeepEqual(eof.Unclosed, test.unclosed) {
+			t.Errorf("For %q expected %v to be unclosed, got %v", test.input, test.unclosed, eof.Unclosed)
+		}
+	}
+
+	// It has ln_before when the input ends with a newline, as the last
+	// token has ln_after.
+	for input, expected := range map[string]bool{"x\n": true, "x ### c\n": true, "x ### c": false, "\n": true} {
+		tokens, err := New(input, &Options{EndOfFileToken: true}).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", input, err)
+		}
+		eof := tokens[len(tokens)-1]
+		if got := eof.LnBefore != nil && *eof.LnBefore; got != expected {
+			t.Errorf("For %q expected ln_before %v, got %v", input, expected, got)
+		}
+		if last := tokens[0]; len(tokens) > 1 && (last.LnAfter != nil && *last.LnAfter) != expected {
+			t.Errorf("For %q expected ln_after %v on %q", input, expected, last.Text)
+		}
+	}
+
+	// Without the option there is none, and a failed run has none either.
+	tokens, _ := New("x", nil).Tokenize()
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("Expected no end of file token, got %v", got)
+	}
+	tokens, _ = New("x \"y", &Options{EndOfFileToken: true}).Tokenize()
+	if got := tokens[len(tokens)-1].Type; got == EndOfFileTokenType {
+		t.Errorf("Expected no end of file token after an error")
+	}
+
+	// It is streamed last.
+	var streamed []TokenType
+	err := New("(x", &Options{EndOfFileToken: true}).TokenizeStream(func(token *Token) error {
+		streamed = append(streamed, token.Type)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []TokenType{OpenDelimiterTokenType, VariableTokenType, EndOfFileTokenType}; !reflect.DeepEqual(streamed, expected) {
+		t.Errorf("Expected %v, got %v", expected, streamed)
+	}
+}
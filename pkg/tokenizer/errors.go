@@ -0,0 +1,43 @@
+package tokenizer
+
+import "fmt"
+
+// Error describes a tokenisation failure together with the span of the
+// source text that caused it.
+type Error struct {
+	Span   Span
+	Reason string
+	Err    error // The underlying cause, such as ErrLimitExceeded, if any
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("tokenisation error at line %d, column %d: %s",
+		e.Span.Start.Line, e.Span.Start.Col, e.Reason)
+}
+
+// Unwrap returns the underlying cause of the error, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// here returns the current position of the tokenizer.
+func (t *Tokenizer) here() Position {
+	return Position{Line: t.line + t.lineOffset, Col: t.column}
+}
+
+// spanFrom returns the span from start up to the current position.
+func (t *Tokenizer) spanFrom(start Position) Span {
+	return Span{Start: start, End: t.here()}
+}
+
+// errorAt creates an Error covering the given span.
+func errorAt(span Span, format string, args ...interface{}) error {
+	return &Error{Span: span, Reason: fmt.Sprintf(format, args...)}
+}
+
+// errorFrom creates an Error covering the text from start up to the current
+// position.
+func (t *Tokenizer) errorFrom(start Position, format string, args ...interface{}) error {
+	return errorAt(t.spanFrom(start), format, args...)
+}
@@ -0,0 +1,89 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NumberRule is the number section of a rules file.
+type NumberRule struct {
+	// The letters that can start the exponent of a number, by base, in place
+	// of the default ones. An exponent that starts with p or P scales the
+	// number by a power of 2, and one that starts with any other letter by a
+	// power of 10.
+	ExponentMarkers map[int]string `yaml:"exponent_markers,omitempty"`
+}
+
+// The letters that start an exponent when the rules do not say otherwise.
+// Hexadecimal numbers also take the binary exponents of C, as in 0x1.8p3.
+const (
+	defaultExponentMarkers = "e"
+	hexExponentMarkers     = "epP"
+)
+
+// compileNumberRule checks the exponent markers of a number rule. A marker
+// must be a letter that cannot be mistaken for a digit of the base or the
+// end of a radix prefix.
+func compileNumberRule(rule NumberRule) (map[int]string, error) {
+	markers := make(map[int]string, len(rule.ExponentMarkers))
+	for _, base := range sortedKeys(rule.ExponentMarkers) {
+		letters := rule.ExponentMarkers[base]
+		if base < 2 || base > 36 {
+			return nil, fmt.Errorf("invalid base %d in number rules (expected 2 to 36)", base)
+		}
+		for _, c := range []byte(letters) {
+			switch {
+			case 'a' <= c && c <= 'z':
+				if strings.IndexByte("xobtr", c) >= 0 {
+					return nil, fmt.Errorf("exponent marker '%c' for base %d would end a radix prefix", c, base)
+				}
+			case 'A' <= c && c <= 'Z':
+				if int(c-'A')+10 < base || (base == 3 && c == 'T') {
+					return nil, fmt.Errorf("exponent marker '%c' for base %d is one of its digits", c, base)
+				}
+			default:
+				return nil, fmt.Errorf("exponent marker '%c' for base %d is not an ASCII letter", c, base)
+			}
+		}
+		markers[base] = letters
+	}
+	return markers, nil
+}
+
+// exponentMarkers returns the letters that can start the exponent of a
+// number in the base.
+func (t *Tokenizer) exponentMarkers(base int) string {
+	if markers, ok := t.rules.ExponentMarkers[base]; ok {
+		return markers
+	}
+	if base == 16 {
+		return hexExponentMarkers
+	}
+	return defaultExponentMarkers
+}
+
+// scanExponent looks for an exponent after the digits of a number, which are
+// the first n bytes of the rest of the input. An upper case marker looks like
+// a digit, so it may be among the digits matched, which then end before it.
+// It returns the length of the digits, and the marker and exponent, if there
+// is one.
+func (t *Tokenizer) scanExponent(n int, markers string) (int, string, string) {
+	text := t.input[t.position:]
+	for i := 0; i <= n && i < len(text); i++ {
+		if strings.IndexByte(markers, text[i]) < 0 {
+			continue
+		}
+		if exponent := exponentRegex.FindString(text[i+1:]); exponent != "" {
+			return i, text[i : i+1], exponent
+		}
+	}
+	return n, "", ""
+}
+
+// exponentBase returns the number that an exponent with the marker scales by.
+func exponentBase(marker string) int {
+	if marker == "p" || marker == "P" {
+		return 2
+	}
+	return 10
+}
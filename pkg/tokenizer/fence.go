@@ -0,0 +1,29 @@
+package tokenizer
+
+// FenceFunc tokenizes the body of a multi-line string in the embedded language
+// named by its specifier, such as sql in @sql""" ... """. The body is the
+// value of the string: its lines dedented, with escapes processed unless the
+// string is raw, and joined by line breaks. The span runs from the start of
+// the first line of the body to the start of the closing line. The tokens are
+// used as they are returned, so their spans are up to the function. It
+// returns nil for a language it does not know.
+type FenceFunc func(specifier, body string, span Span) []*Token
+
+// tokenizeFence gives a multi-line string with a specifier the tokens that the
+// OnFence function finds in its body.
+func (t *Tokenizer) tokenizeFence(token *Token) {
+	if t.onFence == nil || token.Specifier == nil || *token.Specifier == "" {
+		return
+	}
+	// An empty body has nothing to tokenize, and a body with interpolations
+	// has no value to pass on.
+	if len(token.Subtokens) == 0 {
+		return
+	}
+	body, ok := joinMultilineValue(token.Subtokens)
+	if !ok {
+		return
+	}
+	span := Span{token.Subtokens[0].Span.Start, token.Subtokens[len(token.Subtokens)-1].Span.End}
+	token.Embedded = t.onFence(*token.Specifier, body, span)
+}
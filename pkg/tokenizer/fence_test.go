@@ -0,0 +1,53 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOnFence(t *testing.T) {
+	var specifiers, bodies []string
+	var spans []Span
+	onFence := func(specifier, body string, span Span) []*Token {
+		specifiers = append(specifiers, specifier)
+		bodies = append(bodies, body)
+		spans = append(spans, span)
+		if specifier != "sql" {
+			return nil
+		}
+		var tokens []*Token
+		for _, word := range strings.Fields(body) {
+			tokens = append(tokens, NewToken(word, VariableTokenType, span))
+		}
+		return tokens
+	}
+	input := "q := @sql\"\"\"\n    select *\n    from t\n    \"\"\"\nr := \"\"\"\n  plain\n  \"\"\"\np := \"\"\"py\n  x\n  \"\"\""
+	tokens, err := New(input, &Options{OnFence: onFence}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Only the strings with a specifier are passed on.
+	if strings.Join(specifiers, ",") != "sql,py" {
+		t.Fatalf("expected the sql and py fences, got %q", specifiers)
+	}
+	if bodies[0] != "select *\nfrom t" {
+		t.Errorf("expected the dedented body, got %q", bodies[0])
+	}
+	if want := (Span{Position{2, 5}, Position{4, 1}}); spans[0] != want {
+		t.Errorf("expected body span %v, got %v", want, spans[0])
+	}
+
+	var texts []string
+	for _, token := range tokens[2].Embedded {
+		texts = append(texts, token.Text)
+	}
+	if strings.Join(texts, " ") != "select * from t" {
+		t.Errorf("expected the embedded sql tokens, got %q", texts)
+	}
+	for _, i := range []int{5, 8} {
+		if tokens[i].Embedded != nil {
+			t.Errorf("expected no embedded tokens for %q", tokens[i].Text)
+		}
+	}
+}
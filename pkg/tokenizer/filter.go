@@ -0,0 +1,61 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenPredicate reports whether a token should be kept.
+type TokenPredicate func(token *Token) bool
+
+// FilterTokens returns the tokens for which keep returns true, preserving
+// their order. The input slice is not modified.
+func FilterTokens(tokens []*Token, keep TokenPredicate) []*Token {
+	filtered := make([]*Token, 0, len(tokens))
+	for _, token := range tokens {
+		if keep(token) {
+			filtered = append(filtered, token)
+		}
+	}
+	return filtered
+}
+
+// OfTypes returns a predicate that accepts tokens of any of the given types.
+func OfTypes(types ...TokenType) TokenPredicate {
+	wanted := make(map[TokenType]bool, len(types))
+	for _, tokenType := range types {
+		wanted[tokenType] = true
+	}
+	return func(token *Token) bool {
+		return wanted[token.Type]
+	}
+}
+
+// NotOfTypes returns a predicate that rejects tokens of any of the given types.
+func NotOfTypes(types ...TokenType) TokenPredicate {
+	unwanted := OfTypes(types...)
+	return func(token *Token) bool {
+		return !unwanted(token)
+	}
+}
+
+// ParseTokenTypes parses a comma-separated list of token type codes, such as
+// "S,E,O", as used by the --only-types and --exclude-types CLI flags.
+func ParseTokenTypes(list string) ([]TokenType, error) {
+	var types []TokenType
+	for _, code := range strings.Split(list, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		tokenType := TokenType(code)
+		if !tokenType.IsKnown() {
+			return nil, fmt.Errorf("unknown token type '%s'", code)
+		}
+		types = append(types, tokenType)
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no token types given")
+	}
+	return types, nil
+}
@@ -0,0 +1,45 @@
+package tokenizer
+
+import "testing"
+
+func TestFilterTokens(t *testing.T) {
+	tokens, err := NewTokenizer("def f(x) x + 1 end").Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	structural := FilterTokens(tokens, OfTypes(StartTokenType, EndTokenType))
+	if len(structural) != 2 || structural[0].Text != "def" || structural[1].Text != "end" {
+		t.Errorf("Expected [def end], got %v", tokenTexts(structural))
+	}
+
+	rest := FilterTokens(tokens, NotOfTypes(StartTokenType, EndTokenType))
+	if len(rest)+len(structural) != len(tokens) {
+		t.Errorf("Expected %d remaining tokens, got %d", len(tokens)-len(structural), len(rest))
+	}
+}
+
+func TestParseTokenTypes(t *testing.T) {
+	types, err := ParseTokenTypes("S, E,[")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(types) != 3 || types[0] != StartTokenType || types[1] != EndTokenType || types[2] != OpenDelimiterTokenType {
+		t.Errorf("Unexpected types %v", types)
+	}
+
+	for _, bad := range []string{"", "S,Z", ","} {
+		if _, err := ParseTokenTypes(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+// tokenTexts returns the texts of the tokens, for use in test messages.
+func tokenTexts(tokens []*Token) []string {
+	texts := make([]string, len(tokens))
+	for i, token := range tokens {
+		texts[i] = token.Text
+	}
+	return texts
+}
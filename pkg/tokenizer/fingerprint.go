@@ -0,0 +1,41 @@
+package tokenizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// RulesFingerprint returns a content hash of the effective rules, in the form
+// "sha256:<hex>". Any change to the rules gives a different fingerprint, so it
+// can be used to invalidate caches of tokens when the dialect changes. A nil
+// rules is taken to mean the default rules.
+func RulesFingerprint(rules *TokenizerRules) string {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	// encoding/json writes map keys in sorted order, so the encoding does not
+	// depend on the iteration order of the rule maps.
+	data, err := json.Marshal(rules)
+	if err != nil {
+		// The rules consist only of strings, numbers and booleans, which
+		// always encode successfully.
+		panic(fmt.Sprintf("cannot encode rules: %v", err))
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// InputFingerprint returns a content hash of the inputs, taken in order, in
+// the same "sha256:<hex>" form as RulesFingerprint. For a single input it is
+// the SHA-256 of its bytes, so it can be checked with standard tools.
+// Together with the rules fingerprint it identifies exactly what produced a
+// stream of tokens.
+func InputFingerprint(inputs ...string) string {
+	hash := sha256.New()
+	for _, input := range inputs {
+		hash.Write([]byte(input))
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,52 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInputFingerprint(t *testing.T) {
+	// The SHA-256 of "abc", as printed by sha256sum.
+	expected := "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := InputFingerprint("abc"); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if got := InputFingerprint("a", "bc"); got != expected {
+		t.Errorf("Expected several inputs to be hashed in order, got %s", got)
+	}
+}
+
+func TestRulesFingerprint(t *testing.T) {
+	defaults := RulesFingerprint(DefaultRules())
+	if !strings.HasPrefix(defaults, "sha256:") {
+		t.Errorf("Expected a sha256 fingerprint, got %s", defaults)
+	}
+	if got := RulesFingerprint(nil); got != defaults {
+		t.Errorf("Expected nil rules to match the defaults, got %s and %s", got, defaults)
+	}
+
+	apply := func(rulesFile *RulesFile) string {
+		rules, err := ApplyRulesToDefaults(rulesFile)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return RulesFingerprint(rules)
+	}
+
+	// The order of rules within a file does not affect the fingerprint.
+	forwards := apply(&RulesFile{Mark: []MarkRule{{Text: ";"}, {Text: "|"}}})
+	backwards := apply(&RulesFile{Mark: []MarkRule{{Text: "|"}, {Text: ";"}}})
+	if forwards != backwards {
+		t.Errorf("Expected the same fingerprint regardless of rule order, got %s and %s", forwards, backwards)
+	}
+	if forwards == defaults {
+		t.Errorf("Expected changed rules to change the fingerprint")
+	}
+
+	// A change to any detail of a rule changes the fingerprint.
+	first := apply(&RulesFile{Operator: []OperatorRule{{Text: "<>", Precedence: &[3]int{0, 500, 0}}}})
+	second := apply(&RulesFile{Operator: []OperatorRule{{Text: "<>", Precedence: &[3]int{0, 501, 0}}}})
+	if first == second {
+		t.Errorf("Expected a precedence change to change the fingerprint")
+	}
+}
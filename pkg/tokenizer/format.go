@@ -0,0 +1,184 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+// FormatVersion is the version of the token JSON format produced by this
+// package. It is increased whenever the format changes in a way that could
+// break existing consumers.
+//
+// Version 2 writes arities as "zero", "one" or "many" rather than 0, 1 or 2.
+const FormatVersion = 2
+
+// MinFormatVersion is the oldest format version that can still be produced.
+const MinFormatVersion = 1
+
+// formatShims convert a token into a value whose JSON encoding matches an
+// older format version. Versions without an entry use the Token as is.
+var formatShims = map[int]func(token *Token) interface{}{
+	1: tokenV1,
+}
+
+// tokenV1 converts a token to format version 1, in which arities are numbers.
+// An unescaped quote can only appear in JSON as part of its structure, so the
+// arity field can be found in the encoding by its text.
+func tokenV1(token *Token) interface{} {
+	jsonBytes, err := json.Marshal(token)
+	if err != nil {
+		// A token consists only of strings, numbers and booleans, which
+		// always encode successfully.
+		panic(fmt.Sprintf("cannot encode token: %v", err))
+	}
+	for i, name := range arityNames {
+		jsonBytes = bytes.ReplaceAll(jsonBytes,
+			[]byte(fmt.Sprintf(`"arity":%q`, name)), []byte(fmt.Sprintf(`"arity":%d`, i)))
+	}
+	return json.RawMessage(jsonBytes)
+}
+
+// verboseFields are the full names of the fields of the token JSON whose
+// names are abbreviated.
+var verboseFields = []struct{ short, full string }{
+	{"ln_before", "newline_before"},
+	{"ln_after", "newline_after"},
+	{"exp_base", "exponent_base"},
+	{"infix", "infix_precedence"},
+}
+
+// Spans and precedences are written as arrays, which verbose output writes as
+// objects with named fields.
+var (
+	verboseSpanRegex       = regexp.MustCompile(`"(span|expanded_from)":\[(\d+),(\d+),(\d+),(\d+)\]`)
+	verbosePrecedenceRegex = regexp.MustCompile(`"precedence":\[(-?\d+),(-?\d+),(-?\d+)\]`)
+)
+
+// EncodeTokenVerbose renders a token as JSON in the given format version,
+// like EncodeToken, but for reading by people: token types are written as
+// their names, such as "start" rather than "S", abbreviated fields are written
+// with their full names, and spans and precedences are written as objects.
+// As in tokenV1, an unescaped quote can only appear in JSON as part of its
+// structure, so fields and types can be found in the encoding by their text.
+func EncodeTokenVerbose(token *Token, version int) ([]byte, error) {
+	jsonBytes, err := EncodeToken(token, version)
+	if err != nil {
+		return nil, err
+	}
+	for _, tokenType := range knownTokenTypes {
+		jsonBytes = bytes.ReplaceAll(jsonBytes,
+			[]byte(fmt.Sprintf(`"type":%q`, tokenType)), []byte(fmt.Sprintf(`"type":%q`, tokenType.Name())))
+	}
+	for _, field := range verboseFields {
+		jsonBytes = bytes.ReplaceAll(jsonBytes,
+			[]byte(fmt.Sprintf(`%q:`, field.short)), []byte(fmt.Sprintf(`%q:`, field.full)))
+	}
+	jsonBytes = verboseSpanRegex.ReplaceAll(jsonBytes,
+		[]byte(`"$1":{"start":{"line":$2,"col":$3},"end":{"line":$4,"col":$5}}`))
+	jsonBytes = verbosePrecedenceRegex.ReplaceAll(jsonBytes,
+		[]byte(`"precedence":{"prefix":$1,"infix":$2,"postfix":$3}`))
+	return jsonBytes, nil
+}
+
+// Header is the record emitted before the token stream to tell consumers
+// which format version follows and which rules produced it.
+type Header struct {
+	Kind          string `json:"kind"` // Always "header"
+	FormatVersion int    `json:"format_version"`
+	RulesHash     string `json:"rules_hash,omitempty"` // The RulesFingerprint of the rules used
+	File          string `json:"file,omitempty"`       // The input the tokens are from, if it has a name
+	Verbose       bool   `json:"verbose,omitempty"`    // Whether the tokens are written by EncodeTokenVerbose
+}
+
+// NewHeader creates the header record for the given format version and rules
+// fingerprint. The fingerprint may be empty.
+func NewHeader(version int, rulesHash string) Header {
+	return Header{Kind: "header", FormatVersion: version, RulesHash: rulesHash}
+}
+
+// Summary is the record emitted after the token stream to tell consumers
+// that it is complete and how tokenizing went.
+type Summary struct {
+	Kind       string `json:"kind"` // Always "summary"
+	TokenCount int    `json:"token_count"`
+	ErrorCount int    `json:"error_count"`
+	DurationMs int64  `json:"duration_ms"`
+	InputHash  string `json:"input_hash,omitempty"` // The InputFingerprint of the input
+	RulesHash  string `json:"rules_hash,omitempty"` // The RulesFingerprint of the rules used
+}
+
+// NewSummary creates the summary record for a stream of tokens, given the
+// number of tokens written, the number of errors and how long tokenizing
+// took.
+func NewSummary(tokenCount, errorCount int, duration time.Duration) Summary {
+	return Summary{Kind: "summary", TokenCount: tokenCount, ErrorCount: errorCount, DurationMs: duration.Milliseconds()}
+}
+
+// CheckFormatVersion reports an error if the format version cannot be
+// produced.
+func CheckFormatVersion(version int) error {
+	if version < MinFormatVersion || version > FormatVersion {
+		return fmt.Errorf("unsupported token format version %d (supported: %d to %d)",
+			version, MinFormatVersion, FormatVersion)
+	}
+	return nil
+}
+
+// EncodeToken renders a token as JSON in the given format version.
+func EncodeToken(token *Token, version int) ([]byte, error) {
+	if err := CheckFormatVersion(version); err != nil {
+		return nil, err
+	}
+	if shim, ok := formatShims[version]; ok {
+		return json.Marshal(shim(token))
+	}
+	return json.Marshal(token)
+}
+
+// ReadTokens reads back a stream of tokens written as JSON, one per line, as
+// by EncodeToken and the command line tool, so that Go programs can consume
+// its output. Header and summary records are skipped, after checking that the
+// header's format version can be read. Tokens written by EncodeTokenVerbose
+// cannot be read back. The tokens read before an error are returned with it.
+func ReadTokens(r io.Reader) ([]*Token, error) {
+	decoder := json.NewDecoder(r)
+	var tokens []*Token
+	for record := 1; ; record++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			return tokens, nil
+		} else if err != nil {
+			return tokens, fmt.Errorf("record %d: %w", record, err)
+		}
+
+		// Tokens have no kind, which only headers and summaries have.
+		var header Header
+		if err := json.Unmarshal(raw, &header); err != nil {
+			return tokens, fmt.Errorf("record %d: %w", record, err)
+		}
+		switch header.Kind {
+		case "":
+			var token Token
+			if err := json.Unmarshal(raw, &token); err != nil {
+				return tokens, fmt.Errorf("record %d: %w", record, err)
+			}
+			tokens = append(tokens, &token)
+		case "header":
+			if err := CheckFormatVersion(header.FormatVersion); err != nil {
+				return tokens, fmt.Errorf("record %d: %w", record, err)
+			}
+			if header.Verbose {
+				return tokens, fmt.Errorf("record %d: verbose tokens cannot be read back", record)
+			}
+		case "summary":
+			// The summary describes the run rather than any token.
+		default:
+			return tokens, fmt.Errorf("record %d: unknown kind of record '%s'", record, header.Kind)
+		}
+	}
+}
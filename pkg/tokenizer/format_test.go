@@ -0,0 +1,203 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeaderJSON(t *testing.T) {
+	jsonBytes, err := json.Marshal(NewHeader(FormatVersion, ""))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"kind":"header","format_version":2}`
+	if string(jsonBytes) != expected {
+		t.Errorf("Expected %s, got %s", expected, jsonBytes)
+	}
+}
+
+func TestSummaryJSON(t *testing.T) {
+	jsonBytes, err := json.Marshal(NewSummary(3, 1, 1500*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"kind":"summary","token_count":3,"error_count":1,"duration_ms":1500}`
+	if string(jsonBytes) != expected {
+		t.Errorf("Expected %s, got %s", expected, jsonBytes)
+	}
+}
+
+func TestEncodeToken(t *testing.T) {
+	tokens, err := New(`if x then "a\(b)" endif`, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The current version is the plain JSON encoding of the token.
+	for _, token := range tokens {
+		got, err := EncodeToken(token, FormatVersion)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want, _ := json.Marshal(token)
+		if string(got) != string(want) {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+
+	for _, version := range []int{MinFormatVersion - 1, FormatVersion + 1} {
+		if _, err := EncodeToken(tokens[0], version); err == nil {
+			t.Errorf("Expected an error for format version %d", version)
+		}
+	}
+}
+
+func TestEncodeTokenVersion1(t *testing.T) {
+	tokens, err := New(`if x then return y endif`, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	current, err := EncodeToken(tokens[0], FormatVersion)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(current), `"arity":"one"`) {
+		t.Errorf("Expected a named arity, got %s", current)
+	}
+
+	// Version 1 writes arities as numbers.
+	for _, token := range tokens {
+		got, err := EncodeToken(token, 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Contains(string(got), `"arity":"`) {
+			t.Errorf("Expected numeric arities in version 1, got %s", got)
+		}
+	}
+	v1, _ := EncodeToken(tokens[0], 1)
+	if !strings.Contains(string(v1), `"arity":1`) {
+		t.Errorf("Expected arity 1, got %s", v1)
+	}
+}
+
+func TestEncodeTokenVerbose(t *testing.T) {
+	tokens, err := New("def f(x) =>> -x end\n", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[int]string{
+		1: `{"text":"f","span":{"start":{"line":1,"col":5},"end":{"line":1,"col":6}},"type":"variable"}`,
+		2: `{"text":"(","span":{"start":{"line":1,"col":6},"end":{"line":1,"col":7}},"type":"open_delimiter","closed_by":[")"],"infix_precedence":2020,"prefix":true}`,
+		6: `{"text":"-","span":{"start":{"line":1,"col":14},"end":{"line":1,"col":15}},"type":"operator","precedence":{"prefix":90,"infix":2090,"postfix":0}}`,
+		8: `{"text":"end","span":{"start":{"line":1,"col":17},"end":{"line":1,"col":20}},"type":"end","closes":["class","def","fn","for","if","ifnot","let","switch","transaction","try"],"newline_after":true}`,
+	}
+	for i, want := range expected {
+		got, err := EncodeTokenVerbose(tokens[i], FormatVersion)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Token %d: expected %s, got %s", i, want, got)
+		}
+	}
+
+	// A text that looks like a field or a type is left alone.
+	token := NewStringToken(`"type":"S"`, `"ln_after":[1,2,3]`, Span{Position{1, 1}, Position{1, 2}})
+	got, err := EncodeTokenVerbose(token, FormatVersion)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(got), `"value":"\"ln_after\":[1,2,3]"`) || !strings.Contains(string(got), `"type":"string"`) {
+		t.Errorf("Expected only the structure to be renamed, got %s", got)
+	}
+
+	for _, tokenType := range knownTokenTypes {
+		if tokenType.Name() == string(tokenType) {
+			t.Errorf("Expected a name for the token type %s", tokenType)
+		}
+	}
+}
+
+func TestArityText(t *testing.T) {
+	for _, text := range []string{"many", "2"} {
+		var arity Arity
+		if err := arity.UnmarshalText([]byte(text)); err != nil || arity != Many {
+			t.Errorf("Expected %q to read as many, got %v (%v)", text, arity, err)
+		}
+	}
+	var arity Arity
+	if err := arity.UnmarshalText([]byte("several")); err == nil {
+		t.Errorf("Expected an error for an unknown arity")
+	}
+	if _, err := Arity(7).MarshalText(); err == nil {
+		t.Errorf("Expected an error writing an invalid arity")
+	}
+}
+
+func TestReadTokens(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{QuasiQuote: []QuasiQuoteRule{{Open: "$(", Hole: "$"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	input := "def f(x) =>> return -0t1T0 + 0x1_F.8p3 + @sql\"q\" + \"a\\(b)c\"; $( g($y) ) end\n"
+	tokens, err := New(input, &Options{Rules: rules, AnnotateRuleSource: true, NumericSign: NumericSignFold}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	MatchBrackets(tokens)
+
+	for _, version := range []int{1, FormatVersion} {
+		var stream bytes.Buffer
+		header, _ := json.Marshal(NewHeader(version, RulesFingerprint(rules)))
+		stream.Write(append(header, '\n'))
+		for _, token := range tokens {
+			jsonBytes, err := EncodeToken(token, version)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			stream.Write(append(jsonBytes, '\n'))
+		}
+		summary, _ := json.Marshal(NewSummary(len(tokens), 0, 0))
+		stream.Write(append(summary, '\n'))
+
+		read, err := ReadTokens(&stream)
+		if err != nil {
+			t.Fatalf("Version %d: unexpected error: %v", version, err)
+		}
+		if len(read) != len(tokens) {
+			t.Fatalf("Version %d: expected %d tokens, got %d", version, len(tokens), len(read))
+		}
+		// Every field round-trips, so the tokens encode the same again.
+		for i := range tokens {
+			want, _ := json.Marshal(tokens[i])
+			got, _ := json.Marshal(read[i])
+			if string(got) != string(want) {
+				t.Errorf("Version %d: expected %s, got %s", version, want, got)
+			}
+		}
+	}
+}
+
+func TestReadTokensErrors(t *testing.T) {
+	tests := []struct {
+		stream string
+		reason string
+	}{
+		{`{"kind":"header","format_version":9}`, "record 1: unsupported token format version 9"},
+		{`{"kind":"header","format_version":2,"verbose":true}`, "record 1: verbose tokens cannot be read back"},
+		{"{\"text\":\"x\",\"span\":[1,1,1,2],\"type\":\"V\"}\n{\"text\":", "record 2: unexpected EOF"},
+		{`{"kind":"footer"}`, "record 1: unknown kind of record 'footer'"},
+		{`{"text":"if","span":[1,1,1,3],"type":"S","arity":"several"}`, "record 1: invalid arity 'several'"},
+	}
+	for _, test := range tests {
+		_, err := ReadTokens(strings.NewReader(test.stream))
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%q: expected an error %q, got %v", test.stream, test.reason, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fuzzTimeout bounds how long a single fuzz input may take to tokenize, so
+// that an input which makes the tokenizer loop forever is reported as a
+// failure rather than hanging the fuzzer.
+const fuzzTimeout = 5 * time.Second
+
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"def foo(x) x + 1 end",
+		"if x then y elseif z then w else v endif",
+		"x := 0x1F + 2r1010 * 3.14e-2 + 0t1T0.1",
+		`"a\(b)c" 'it''s' ` + "`tick`",
+		`"A\n\t\\" "\(f("\(g)"))"`,
+		`@sql"select *" @"raw" @tag"""` + "\n  x\n  \"\"\"",
+		"\"\"\"\n  one\n  two\n  \"\"\"",
+		"a ### comment\nb\r\nc",
+		"[1, 2] {x: y} (z)",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens, _ := tokenizeWithTimeout(t, New(input, nil))
+		if !hasLineDirective(input) {
+			checkSpansIncrease(t, tokens)
+		}
+
+		tokens, err := tokenizeWithTimeout(t, New(input, &Options{Lossless: true}))
+		if err == nil {
+			checkLossless(t, input, tokens)
+		}
+	})
+}
+
+func FuzzRulesFile(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"bracket:\n  - text: \"<\"\n    closed_by: [\">\"]\n    infix: 30\n    prefix: true\n",
+		"start:\n  - text: loop\n    closed_by: [endloop]\n    expecting: [do]\n",
+		"bridge:\n  - text: do\n    in: [loop]\n    expecting: [endloop]\n",
+		"operator:\n  - text: \"+++\"\n    precedence: [10, 20, 30]\n",
+		"wildcard:\n  - text: \":\"\n",
+		"mark:\n  - text: \";\"\n",
+		"prefix:\n  - text: return\n",
+	} {
+		f.Add([]byte(seed), "loop x do y endloop a <b> c +++ d; return :")
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, input string) {
+		rulesFile, err := ParseRulesFile(data)
+		if err != nil {
+			return
+		}
+		rules, err := ApplyRulesToDefaults(rulesFile)
+		if err != nil {
+			return
+		}
+		tokens, _ := tokenizeWithTimeout(t, New(input, &Options{Rules: rules}))
+		if !hasLineDirective(input) {
+			checkSpansIncrease(t, tokens)
+		}
+	})
+}
+
+// tokenizeWithTimeout runs the tokenizer, failing the test if it does not
+// finish within fuzzTimeout.
+func tokenizeWithTimeout(t *testing.T, tokenizer *Tokenizer) ([]*Token, error) {
+	t.Helper()
+	type result struct {
+		tokens []*Token
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		tokens, err := tokenizer.Tokenize()
+		done <- result{tokens, err}
+	}()
+	select {
+	case r := <-done:
+		return r.tokens, r.err
+	case <-time.After(fuzzTimeout):
+		t.Fatalf("Tokenizer did not finish within %v", fuzzTimeout)
+		return nil, nil
+	}
+}
+
+// hasLineDirective reports whether the input may contain a ###line
+// directive, after which spans are free to go backwards.
+func hasLineDirective(input string) bool {
+	return strings.Contains(input, "###line")
+}
+
+// checkSpansIncrease verifies that every span is well formed and that tokens,
+// and the subtokens within them, appear in order without overlapping.
+func checkSpansIncrease(t *testing.T, tokens []*Token) {
+	t.Helper()
+	var previous *Token
+	for _, token := range tokens {
+		if token.Span.End.Before(token.Span.Start) {
+			t.Fatalf("Token %q has a span that ends before it starts: %v", token.Text, token.Span)
+		}
+		if previous != nil && token.Span.Start.Before(previous.Span.End) {
+			t.Fatalf("Token %q at %v overlaps the previous token %q at %v",
+				token.Text, token.Span, previous.Text, previous.Span)
+		}
+		for _, sub := range token.Subtokens {
+			if sub.Span.Start.Before(token.Span.Start) || token.Span.End.Before(sub.Span.End) {
+				t.Fatalf("Subtoken %q at %v lies outside its token %q at %v",
+					sub.Text, sub.Span, token.Text, token.Span)
+			}
+		}
+		checkSpansIncrease(t, token.Subtokens)
+		previous = token
+	}
+}
+
+// checkLossless verifies that the texts of the tokens of a lossless
+// tokenization add up to the input.
+func checkLossless(t *testing.T, input string, tokens []*Token) {
+	t.Helper()
+	var text strings.Builder
+	for _, token := range tokens {
+		text.WriteString(token.Text)
+	}
+	if text.String() != input {
+		t.Fatalf("Lossless tokens do not reproduce the input:\n got %q\nwant %q", text.String(), input)
+	}
+}
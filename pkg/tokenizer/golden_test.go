@@ -0,0 +1,115 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// TestGolden tokenizes each testdata/golden/*.nutmeg file and compares the
+// result with the matching .golden.jsonl file. If a .rules.yaml file with the
+// same base name exists, its rules are used. Run `go test -update` to
+// regenerate the golden files after an intended change in behaviour.
+func TestGolden(t *testing.T) {
+	sources, err := filepath.Glob(filepath.Join("testdata", "golden", "*.nutmeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) == 0 {
+		t.Fatal("No golden test sources found")
+	}
+
+	for _, source := range sources {
+		base := strings.TrimSuffix(source, ".nutmeg")
+		t.Run(filepath.Base(base), func(t *testing.T) {
+			got := goldenOutput(t, source, base+".rules.yaml")
+			goldenFile := base + ".golden.jsonl"
+
+			if *update {
+				if err := os.WriteFile(goldenFile, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("Missing golden file (run go test -update): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Output differs from %s (run go test -update to accept):\n%s",
+					goldenFile, firstDifference(want, got))
+			}
+		})
+	}
+}
+
+// goldenOutput tokenizes the source file and renders the tokens as JSON, one
+// per line, as the command line tool does. A tokenisation error is recorded as
+// a final {"error": ...} line.
+func goldenOutput(t *testing.T, source, rulesFile string) []byte {
+	t.Helper()
+	input, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenizer := New(string(input), nil)
+	if _, err := os.Stat(rulesFile); err == nil {
+		rules, err := LoadRulesFile(rulesFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenizerRules, err := ApplyRulesToDefaults(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenizer = New(string(input), &Options{Rules: tokenizerRules})
+	}
+
+	tokens, tokenizeErr := tokenizer.Tokenize()
+	var out bytes.Buffer
+	for _, token := range tokens {
+		jsonBytes, err := json.Marshal(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(jsonBytes)
+		out.WriteByte('\n')
+	}
+	if tokenizeErr != nil {
+		jsonBytes, err := json.Marshal(map[string]string{"error": tokenizeErr.Error()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(jsonBytes)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// firstDifference describes the first line at which want and got differ.
+func firstDifference(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return "no difference"
+}
@@ -0,0 +1,278 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// GrammarFormats are the formats that ExportGrammar can write.
+var GrammarFormats = []string{"textmate", "tree-sitter-lexer"}
+
+// grammarWords are the token texts of a set of rules, grouped by how an
+// editor should highlight them. Each group is sorted longest first, so that
+// an alternation of them prefers the longest match.
+type grammarWords struct {
+	keywords  []string // Start, end, bridge, prefix and wildcard tokens
+	operators []string
+	openers   []string
+	closers   []string
+	marks     []string
+	quotes    []grammarQuote // Sorted by opening quote
+}
+
+// grammarQuote is a quote character of the rules.
+type grammarQuote struct {
+	open, close string
+	raw         bool
+}
+
+// collectGrammarWords groups the tokens of the rules. Defines are left out
+// because they stand for other tokens.
+func collectGrammarWords(rules *TokenizerRules) grammarWords {
+	var words grammarWords
+	for text, entry := range rules.TokenLookup {
+		switch entry.Type {
+		case CustomStart, CustomEnd, CustomBridge, CustomPrefix, CustomWildcard:
+			words.keywords = append(words.keywords, text)
+		case CustomOperator:
+			words.operators = append(words.operators, text)
+		case CustomOpenDelimiter:
+			words.openers = append(words.openers, text)
+		case CustomCloseDelimiter:
+			words.closers = append(words.closers, text)
+		case CustomMark:
+			words.marks = append(words.marks, text)
+		}
+	}
+	for text, data := range rules.quotes() {
+		r, _ := utf8.DecodeRuneInString(text)
+		words.quotes = append(words.quotes, grammarQuote{text, string(getMatchingCloseQuote(r)), data.Raw})
+	}
+	sort.Slice(words.quotes, func(i, j int) bool {
+		return words.quotes[i].open < words.quotes[j].open
+	})
+	for _, group := range [][]string{words.keywords, words.operators, words.openers, words.closers, words.marks} {
+		sort.Slice(group, func(i, j int) bool {
+			if len(group[i]) != len(group[j]) {
+				return len(group[i]) > len(group[j])
+			}
+			return group[i] < group[j]
+		})
+	}
+	return words
+}
+
+// ExportGrammar derives a highlighting grammar for the given format from the
+// rules, or from the default rules if rules is nil. The grammar is only an
+// approximation of the tokenizer: it knows the keywords, operators, brackets
+// and marks of the rules and the forms of strings, numbers and comments, but
+// not the context that the tokenizer tracks, such as the labels a start token
+// expects.
+func ExportGrammar(rules *TokenizerRules, format, name string) (string, error) {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	words := collectGrammarWords(rules)
+	switch format {
+	case "textmate":
+		return exportTextMate(words, name)
+	case "tree-sitter-lexer":
+		return exportTreeSitterLexer(words, name), nil
+	}
+	return "", fmt.Errorf("unknown grammar format '%s' (known formats: %s)", format, strings.Join(GrammarFormats, ", "))
+}
+
+// The patterns below are shared by both grammars. They are taken from the
+// tokenizer's own regular expressions where possible, so that they stay in
+// step with it.
+var (
+	grammarIdentifier = strings.TrimPrefix(identifierRegex.String(), "^")
+	grammarSigns      = strings.TrimPrefix(operatorRegex.String(), "^")
+	grammarRadix      = strings.TrimPrefix(radixRegex.String(), "^") + `(?:[epP][+-]?\d+)?`
+	grammarDecimal    = strings.TrimPrefix(decimalRegex.String(), "^") + `(?:e[+-]?\d+)?`
+)
+
+// alternation returns a regular expression that matches any of the texts.
+// Texts that look like identifiers only match as whole words.
+func alternation(texts []string) string {
+	var words, symbols []string
+	for _, text := range texts {
+		if identifierRegex.FindString(text) == text {
+			words = append(words, regexp.QuoteMeta(text))
+		} else {
+			symbols = append(symbols, regexp.QuoteMeta(text))
+		}
+	}
+	var parts []string
+	if len(words) > 0 {
+		parts = append(parts, `\b(?:`+strings.Join(words, "|")+`)\b`)
+	}
+	if len(symbols) > 0 {
+		parts = append(parts, `(?:`+strings.Join(symbols, "|")+`)`)
+	}
+	return strings.Join(parts, "|")
+}
+
+// textMateRule is a rule in a TextMate grammar.
+type textMateRule struct {
+	Name     string         `json:"name,omitempty"`
+	Match    string         `json:"match,omitempty"`
+	Begin    string         `json:"begin,omitempty"`
+	End      string         `json:"end,omitempty"`
+	Include  string         `json:"include,omitempty"`
+	Patterns []textMateRule `json:"patterns,omitempty"`
+}
+
+// textMateGrammar is the JSON form of a TextMate grammar.
+type textMateGrammar struct {
+	Name       string                  `json:"name"`
+	ScopeName  string                  `json:"scopeName"`
+	FileTypes  []string                `json:"fileTypes"`
+	Patterns   []textMateRule          `json:"patterns"`
+	Repository map[string]textMateRule `json:"repository"`
+}
+
+// exportTextMate writes the grammar as a TextMate JSON grammar.
+func exportTextMate(words grammarWords, name string) (string, error) {
+	escape := textMateRule{Name: "constant.character.escape." + name, Match: `\\.`}
+	var strs []textMateRule
+	for _, quote := range words.quotes {
+		openQuote, closeQuote := regexp.QuoteMeta(quote.open), regexp.QuoteMeta(quote.close)
+		strs = append(strs, textMateRule{
+			Name:  "string.quoted.triple." + name,
+			Begin: `(?:@\w*)?` + openQuote + openQuote + openQuote,
+			End:   closeQuote + closeQuote + closeQuote,
+		})
+	}
+	for _, quote := range words.quotes {
+		rule := textMateRule{
+			Name:  "string.quoted." + name,
+			Begin: `(?:@\w*)?` + regexp.QuoteMeta(quote.open),
+			End:   regexp.QuoteMeta(quote.close),
+		}
+		if !quote.raw {
+			rule.Patterns = []textMateRule{escape}
+		}
+		strs = append(strs, rule)
+	}
+
+	grammar := textMateGrammar{
+		Name:      name,
+		ScopeName: "source." + name,
+		FileTypes: []string{name},
+		Repository: map[string]textMateRule{
+			"comment": {Name: "comment.line.number-sign." + name, Match: `###.*$`},
+			"string":  {Patterns: strs},
+			"number":  {Name: "constant.numeric." + name, Match: `\b(?:` + grammarRadix + `|` + grammarDecimal + `)`},
+			"operator": {Name: "keyword.operator." + name,
+				Match: strings.Join([]string{alternation(words.operators), grammarSigns}, "|")},
+		},
+	}
+	sections := []struct {
+		key, scope string
+		texts      []string
+	}{
+		{"keyword", "keyword.control", words.keywords},
+		{"open", "punctuation.section.begin", words.openers},
+		{"close", "punctuation.section.end", words.closers},
+		{"mark", "punctuation.separator", words.marks},
+	}
+	for _, section := range sections {
+		if len(section.texts) > 0 {
+			grammar.Repository[section.key] = textMateRule{Name: section.scope + "." + name, Match: alternation(section.texts)}
+		}
+	}
+	// The order matters, as the first pattern to match wins.
+	for _, key := range []string{"comment", "string", "number", "keyword", "open", "close", "mark", "operator"} {
+		if _, ok := grammar.Repository[key]; ok {
+			grammar.Patterns = append(grammar.Patterns, textMateRule{Include: "#" + key})
+		}
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(grammar); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// exportTreeSitterLexer writes the grammar as a tree-sitter grammar.js that
+// only recognises tokens, which is enough for highlighting queries.
+func exportTreeSitterLexer(words grammarWords, name string) string {
+	var b strings.Builder
+	rule := func(ruleName, body string) {
+		fmt.Fprintf(&b, "    %s: $ => %s,\n", ruleName, body)
+	}
+	choice := func(texts []string) string {
+		quoted := make([]string, len(texts))
+		for i, text := range texts {
+			quoted[i] = strconv.Quote(text)
+		}
+		return "choice(" + strings.Join(quoted, ", ") + ")"
+	}
+
+	tokens := []string{"$.string", "$.number"}
+	sections := []struct {
+		name  string
+		texts []string
+	}{
+		{"keyword", words.keywords},
+		{"open_bracket", words.openers},
+		{"close_bracket", words.closers},
+		{"mark", words.marks},
+	}
+	for _, section := range sections {
+		if len(section.texts) > 0 {
+			tokens = append(tokens, "$."+section.name)
+		}
+	}
+	tokens = append(tokens, "$.operator", "$.identifier")
+
+	fmt.Fprintf(&b, "// A token-level grammar generated by nutmeg-tokenizer --export-grammar.\n")
+	fmt.Fprintf(&b, "module.exports = grammar({\n")
+	fmt.Fprintf(&b, "  name: %s,\n", strconv.Quote(name))
+	fmt.Fprintf(&b, "  extras: $ => [/\\s/, $.comment],\n")
+	fmt.Fprintf(&b, "  word: $ => $.identifier,\n")
+	fmt.Fprintf(&b, "  rules: {\n")
+	rule("source_file", "repeat($._token)")
+	rule("_token", "choice("+strings.Join(tokens, ", ")+")")
+	for _, section := range sections {
+		if len(section.texts) > 0 {
+			rule(section.name, choice(section.texts))
+		}
+	}
+	operators := "/" + grammarSigns + "/"
+	if len(words.operators) > 0 {
+		operators = strings.TrimSuffix(choice(words.operators), ")") + ", " + operators + ")"
+	}
+	rule("operator", operators)
+	var strs []string
+	for _, quote := range words.quotes {
+		openQuote, q := regexp.QuoteMeta(quote.open), regexp.QuoteMeta(quote.close)
+		strs = append(strs, "/(@\\w*)?"+openQuote+openQuote+openQuote+"([^"+q+"]|"+q+"[^"+q+"]|"+q+q+"[^"+q+"])*"+q+q+q+"/")
+	}
+	for _, quote := range words.quotes {
+		openQuote, closeQuote := regexp.QuoteMeta(quote.open), regexp.QuoteMeta(quote.close)
+		if quote.raw {
+			strs = append(strs, "/"+openQuote+"[^"+closeQuote+"\\n]*"+closeQuote+"/")
+		} else {
+			strs = append(strs, "/(@\\w*)?"+openQuote+"([^"+closeQuote+"\\\\\\n]|\\\\.)*"+closeQuote+"/")
+		}
+	}
+	rule("string", "choice("+strings.Join(strs, ", ")+")")
+	rule("number", "choice(/"+grammarRadix+"/, /"+grammarDecimal+"/)")
+	rule("comment", "/###.*/")
+	rule("identifier", "/"+grammarIdentifier+"/")
+	fmt.Fprintf(&b, "  },\n")
+	fmt.Fprintf(&b, "});\n")
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExportGrammarTextMate(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Start: []StartRule{{Text: "unless", ClosedBy: []string{"endunless"}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text, err := ExportGrammar(rules, "textmate", "nutmeg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var grammar textMateGrammar
+	if err := json.Unmarshal([]byte(text), &grammar); err != nil {
+		t.Fatalf("expected valid JSON: %v", err)
+	}
+	if grammar.ScopeName != "source.nutmeg" {
+		t.Errorf("expected scope source.nutmeg, got %q", grammar.ScopeName)
+	}
+	keywords := grammar.Repository["keyword"].Match
+	for _, word := range []string{"unless", "endunless"} {
+		if !strings.Contains(keywords, word) {
+			t.Errorf("expected the keywords to include %q, got %s", word, keywords)
+		}
+	}
+	// The longer keyword comes first, so that it is preferred.
+	if strings.Index(keywords, "endunless") > strings.Index(keywords, "unless|") {
+		t.Errorf("expected endunless before unless, got %s", keywords)
+	}
+	if !strings.Contains(grammar.Repository["open"].Match, `\(`) {
+		t.Errorf("expected ( to be quoted in %s", grammar.Repository["open"].Match)
+	}
+}
+
+func TestExportGrammarTreeSitter(t *testing.T) {
+	text, err := ExportGrammar(nil, "tree-sitter-lexer", "nutmeg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{`name: "nutmeg"`, `keyword: $ => choice(`, `"endif"`, `mark: $ => choice(",", ";")`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected the grammar to contain %s, got\n%s", want, text)
+		}
+	}
+}
+
+func TestExportGrammarUnknownFormat(t *testing.T) {
+	_, err := ExportGrammar(nil, "vim", "nutmeg")
+	if err == nil || !strings.Contains(err.Error(), "unknown grammar format 'vim'") {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package tokenizer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// HashOptions selects what HashTokens takes into account. The zero value, or
+// a nil *HashOptions, hashes the tokens without their comments.
+type HashOptions struct {
+	Comments bool // Include comment tokens in the hash
+}
+
+// HashTokens returns a content hash of the tokens, in the form
+// "sha256:<hex>". Only the type, text and alias of each token are hashed, and
+// whitespace and comment tokens are skipped, so that reformatting a source
+// or editing its comments does not change its hash. Two streams that
+// DiffTokens finds no differences between have the same hash.
+func HashTokens(tokens []*Token, opts *HashOptions) string {
+	if opts == nil {
+		opts = &HashOptions{}
+	}
+	hash := sha256.New()
+	var buffer []byte
+	// Each field is written with its length in front, so that no two
+	// different streams give the same bytes.
+	field := func(text string) {
+		buffer = binary.AppendUvarint(buffer, uint64(len(text)))
+		buffer = append(buffer, text...)
+	}
+	for _, token := range tokens {
+		if token.Type == WhitespaceTokenType || (token.Type == CommentTokenType && !opts.Comments) {
+			continue
+		}
+		buffer = buffer[:0]
+		field(string(token.Type))
+		field(token.Text)
+		if token.Alias != nil {
+			buffer = append(buffer, 1)
+			field(*token.Alias)
+		} else {
+			buffer = append(buffer, 0)
+		}
+		hash.Write(buffer)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashTokens(t *testing.T) {
+	hash := func(input string, options *Options, opts *HashOptions) string {
+		tokens, err := New(input, options).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return HashTokens(tokens, opts)
+	}
+
+	base := hash("f(x, y) + z ### sum", nil, nil)
+	if !strings.HasPrefix(base, "sha256:") {
+		t.Errorf("Expected a sha256 hash, got %s", base)
+	}
+
+	// Layout, whitespace tokens and comments do not affect the hash.
+	same := []struct {
+		input   string
+		options *Options
+	}{
+		{"f( x,\n  y )\n+ z", nil},
+		{"f(x, y) + z ### a different comment", &Options{Lossless: true}},
+	}
+	for _, test := range same {
+		if got := hash(test.input, test.options, nil); got != base {
+			t.Errorf("Expected %q to hash the same as the original, got %s and %s", test.input, got, base)
+		}
+	}
+
+	// Any change to the tokens changes the hash.
+	for _, input := range []string{"f(x, y) - z", "f(xy) + z", "f(x, y) + \"z\""} {
+		if got := hash(input, nil, nil); got == base {
+			t.Errorf("Expected %q to hash differently from the original", input)
+		}
+	}
+
+	// Comments count when asked for.
+	lossless := &Options{Lossless: true}
+	withComments := &HashOptions{Comments: true}
+	if hash("x ### one", lossless, withComments) == hash("x ### two", lossless, withComments) {
+		t.Errorf("Expected comments to change the hash when they are included")
+	}
+}
@@ -0,0 +1,97 @@
+package tokenizer
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// IdentifierRule is a rule of the identifier section, which lets a character
+// that is not a letter, digit or underscore be part of an identifier, such as
+// the - of kebab-case or the ' of a prime.
+type IdentifierRule struct {
+	Char  string `yaml:"char"`
+	Where string `yaml:"where"` // IdentifierInner, IdentifierTrailing or IdentifierSuffix
+}
+
+// Where in an identifier the character of an identifier rule may appear.
+const (
+	IdentifierInner    = "inner"    // Between two parts, the second starting with a letter or _, as - in kebab-case
+	IdentifierTrailing = "trailing" // Any number of them at the end, as ' in x''
+	IdentifierSuffix   = "suffix"   // Once at the very end, as ? in empty?
+)
+
+// compileIdentifierRules checks the identifier rules and returns where each
+// character may appear, by character.
+func compileIdentifierRules(rules []IdentifierRule) (map[string]string, error) {
+	chars := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		r, size := utf8.DecodeRuneInString(rule.Char)
+		switch {
+		case rule.Char == "":
+			return nil, fmt.Errorf("identifier rule with no character")
+		case size != len(rule.Char):
+			return nil, fmt.Errorf("identifier rule '%s' is not a single character", rule.Char)
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r):
+			return nil, fmt.Errorf("identifier rule '%s' is a letter, digit, underscore or space", rule.Char)
+		}
+		switch rule.Where {
+		case IdentifierInner, IdentifierTrailing, IdentifierSuffix:
+		default:
+			return nil, fmt.Errorf("identifier character '%s' has the unknown place '%s' (expected %s, %s or %s)",
+				rule.Char, rule.Where, IdentifierInner, IdentifierTrailing, IdentifierSuffix)
+		}
+		if where, ok := chars[rule.Char]; ok && where != rule.Where {
+			return nil, fmt.Errorf("identifier character '%s' is given the places '%s' and '%s'", rule.Char, where, rule.Where)
+		}
+		chars[rule.Char] = rule.Where
+	}
+	return chars, nil
+}
+
+// matchIdentifier returns the identifier at the start of s, or "" if there is
+// none, including the extra characters of the identifier rules.
+func (t *Tokenizer) matchIdentifier(s string) string {
+	name := identifierRegex.FindString(s)
+	if name == "" || len(t.rules.IdentifierChars) == 0 {
+		return name
+	}
+	end := len(name)
+	where := func() (string, int) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if size == 0 {
+			return "", 0
+		}
+		return t.rules.IdentifierChars[string(r)], size
+	}
+	for {
+		// An inner character joins on another part, which must start with
+		// a letter or underscore, so that x-1 is still a subtraction.
+		place, size := where()
+		if place != IdentifierInner {
+			break
+		}
+		next, _ := utf8.DecodeRuneInString(s[end+size:])
+		if next != '_' && !isASCIILetter(next) {
+			break
+		}
+		end += size + len(identifierRegex.FindString(s[end+size:]))
+	}
+	for {
+		place, size := where()
+		if place != IdentifierTrailing {
+			break
+		}
+		end += size
+	}
+	if place, size := where(); place == IdentifierSuffix {
+		end += size
+	}
+	return s[:end]
+}
+
+// isASCIILetter reports whether r can start an identifier other than with an
+// underscore.
+func isASCIILetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
@@ -0,0 +1,89 @@
+package tokenizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// identifierRules returns rules with - inside identifiers, ' at their end and
+// ? or ! as a suffix.
+func identifierRules(t *testing.T) *TokenizerRules {
+	t.Helper()
+	rules, err := ApplyRulesToDefaults(&RulesFile{Identifier: []IdentifierRule{
+		{Char: "-", Where: IdentifierInner},
+		{Char: "'", Where: IdentifierTrailing},
+		{Char: "?", Where: IdentifierSuffix},
+		{Char: "!", Where: IdentifierSuffix},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return rules
+}
+
+func TestIdentifierChars(t *testing.T) {
+	rules := identifierRules(t)
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"foo-bar-baz", []string{"foo-bar-baz"}},
+		// The part after an inner character must start with a letter or _.
+		{"x-1 a - b", []string{"x", "-", "1", "a", "-", "b"}},
+		{"a-_b c-", []string{"a-_b", "c", "-"}},
+		{"x'' y'", []string{"x''", "y'"}},
+		{"empty? save! x'?", []string{"empty?", "save!", "x'?"}},
+		// Only one suffix is taken, and nothing may follow it.
+		{"p?? q?-r", []string{"p?", "?", "q?", "-", "r"}},
+	}
+	for _, test := range tests {
+		tokens, err := New(test.input, &Options{Rules: rules}).Tokenize()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", test.input, err)
+		}
+		if got := tokenTexts(tokens); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expected, got)
+		}
+	}
+
+	// Keywords are only keywords without the extra characters.
+	tokens, err := New("if done? then end-if endif", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	types := []TokenType{StartTokenType, VariableTokenType, BridgeTokenType, VariableTokenType, EndTokenType}
+	for i, token := range tokens {
+		if token.Type != types[i] {
+			t.Errorf("Expected %q to be %s, got %s", token.Text, types[i], token.Type)
+		}
+	}
+
+	// The parts of a qualified identifier may have them too.
+	tokens, err = New("a::b-c?", &Options{Rules: rules, QualifiedSeparator: "::"}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != 1 || !reflect.DeepEqual(tokens[0].Parts, []string{"a", "b-c?"}) {
+		t.Errorf("Expected the parts [a b-c?], got %v", tokens[0].Parts)
+	}
+}
+
+func TestIdentifierRuleErrors(t *testing.T) {
+	tests := []struct {
+		rules  []IdentifierRule
+		reason string
+	}{
+		{[]IdentifierRule{{Char: "", Where: IdentifierInner}}, "no character"},
+		{[]IdentifierRule{{Char: "->", Where: IdentifierInner}}, "not a single character"},
+		{[]IdentifierRule{{Char: "a", Where: IdentifierInner}}, "is a letter"},
+		{[]IdentifierRule{{Char: "-", Where: "middle"}}, "unknown place 'middle'"},
+		{[]IdentifierRule{{Char: "?", Where: IdentifierSuffix}, {Char: "?", Where: IdentifierTrailing}}, "given the places"},
+	}
+	for _, test := range tests {
+		_, err := ApplyRulesToDefaults(&RulesFile{Identifier: test.rules})
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%v: expected an error %q, got %v", test.rules, test.reason, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tokenizer
+
+import (
+	"strings"
+	"sync"
+)
+
+// Interner keeps one copy of each distinct token text and numbers them, so
+// that the many tokens with the same text, such as keywords, operators and
+// common names, share it. The copies do not refer to the input, so the
+// tokens of a large input can be kept without keeping the whole input. An
+// interner may be shared by several tokenizers, including those of a Pool,
+// so that their IDs agree, and is safe for concurrent use.
+type Interner struct {
+	mu    sync.Mutex
+	ids   map[string]int
+	texts []string
+}
+
+// NewInterner creates an empty interner.
+func NewInterner() *Interner {
+	return &Interner{ids: make(map[string]int)}
+}
+
+// Intern returns the shared copy of the text and its ID. IDs start at 1 and
+// are given in the order the texts are first seen.
+func (in *Interner) Intern(text string) (string, int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.intern(text)
+}
+
+// intern is Intern for a caller that holds the lock.
+func (in *Interner) intern(text string) (string, int) {
+	if id, ok := in.ids[text]; ok {
+		return in.texts[id-1], id
+	}
+	// The text is copied so that it does not keep the input it was cut from.
+	text = strings.Clone(text)
+	in.texts = append(in.texts, text)
+	in.ids[text] = len(in.texts)
+	return text, len(in.texts)
+}
+
+// Text returns the text with the given ID, or "" if there is none.
+func (in *Interner) Text(id int) string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if id < 1 || id > len(in.texts) {
+		return ""
+	}
+	return in.texts[id-1]
+}
+
+// Len returns the number of distinct texts interned.
+func (in *Interner) Len() int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return len(in.texts)
+}
+
+// internTokens replaces the texts of the tokens, and of the tokens within
+// them, with their interned copies and sets their TextIDs, if the tokenizer
+// has an interner.
+func (t *Tokenizer) internTokens(tokens []*Token) {
+	if t.interner == nil || len(tokens) == 0 {
+		return
+	}
+	t.interner.mu.Lock()
+	defer t.interner.mu.Unlock()
+	internAll(t.interner, tokens)
+}
+
+// internAll interns the tokens and their subtokens and embedded tokens.
+func internAll(in *Interner, tokens []*Token) {
+	for _, token := range tokens {
+		token.Text, token.TextID = in.intern(token.Text)
+		internAll(in, token.Subtokens)
+		internAll(in, token.Embedded)
+	}
+}
@@ -0,0 +1,96 @@
+package tokenizer
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestInterner(t *testing.T) {
+	interner := NewInterner()
+	input := "x := x + f(\"x\", y) + y"
+	tokens, err := New(input, &Options{Interner: interner}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	byText := map[string]*Token{}
+	for _, token := range tokens {
+		if token.TextID == 0 {
+			t.Fatalf("Expected %q to have a text ID", token.Text)
+		}
+		if interner.Text(token.TextID) != token.Text {
+			t.Errorf("Expected ID %d to be %q, got %q", token.TextID, token.Text, interner.Text(token.TextID))
+		}
+		first, ok := byText[token.Text]
+		if !ok {
+			byText[token.Text] = token
+			continue
+		}
+		if first.TextID != token.TextID || unsafe.StringData(first.Text) != unsafe.StringData(token.Text) {
+			t.Errorf("Expected the two %q tokens to share their text and ID", token.Text)
+		}
+	}
+	if interner.Len() != len(byText) {
+		t.Errorf("Expected %d distinct texts, got %d", len(byText), interner.Len())
+	}
+	if tokens[0].TextID != 1 {
+		t.Errorf("Expected the first text to be given ID 1, got %d", tokens[0].TextID)
+	}
+
+	// The texts are copies, so they do not keep the input.
+	inputStart, inputEnd := uintptr(unsafe.Pointer(unsafe.StringData(input))), uintptr(len(input))
+	for _, token := range tokens {
+		if p := uintptr(unsafe.Pointer(unsafe.StringData(token.Text))); p-inputStart < inputEnd {
+			t.Errorf("Expected %q not to point into the input", token.Text)
+		}
+	}
+
+	// A second input shares the IDs of the first.
+	more, err := New("y x", &Options{Interner: interner}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if more[1].TextID != tokens[0].TextID {
+		t.Errorf("Expected x to keep the ID %d, got %d", tokens[0].TextID, more[1].TextID)
+	}
+
+	if interner.Text(0) != "" || interner.Text(interner.Len()+1) != "" {
+		t.Errorf("Expected no text for IDs out of range")
+	}
+}
+
+func TestInternerSubtokensAndStream(t *testing.T) {
+	interner := NewInterner()
+	var streamed []*Token
+	err := New("$( [b] ) b", &Options{Rules: quasiQuoteRules(t), Interner: interner}).TokenizeStream(func(token *Token) error {
+		streamed = append(streamed, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(streamed) != 2 {
+		t.Fatalf("Expected 2 tokens, got %d", len(streamed))
+	}
+	var b *Token
+	for _, sub := range streamed[0].Subtokens {
+		if sub.Text == "b" {
+			b = sub
+		}
+	}
+	if b == nil {
+		t.Fatalf("Expected b among the subtokens of %q", streamed[0].Text)
+	}
+	if b.TextID == 0 || b.TextID != streamed[1].TextID {
+		t.Errorf("Expected the b within the template to share the ID of the b after it, got %d and %d", b.TextID, streamed[1].TextID)
+	}
+
+	// Without an interner, tokens have no ID.
+	tokens, err := New("b", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tokens[0].TextID != 0 {
+		t.Errorf("Expected no text ID without an interner, got %d", tokens[0].TextID)
+	}
+}
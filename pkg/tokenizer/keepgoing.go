@@ -0,0 +1,62 @@
+package tokenizer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// readSingleLineString reads a single-line string with read. In keep-going
+// mode a string that read cannot finish, such as one with no closing quote,
+// becomes an exception token covering the rest of its line instead, so that
+// tokenizing goes on with the next line.
+func (t *Tokenizer) readSingleLineString(read func() (*Token, error)) (*Token, error) {
+	if !t.keepGoing {
+		return read()
+	}
+	t.markPosition()
+	marks, depth := len(t.markStack), t.interpolationDepth
+	token, err := read()
+	// An interpolation that fails can leave its own marks behind.
+	t.markStack = t.markStack[:marks]
+	t.lineNoStack = t.lineNoStack[:marks]
+	t.lineColStack = t.lineColStack[:marks]
+	var tokErr *Error
+	if err == nil || !errors.As(err, &tokErr) || errors.Is(err, ErrLimitExceeded) {
+		// Going on past a limit would defeat it, so only errors in the
+		// string itself are recovered from.
+		t.popMark()
+		return token, err
+	}
+
+	t.resetPosition()
+	t.interpolationDepth = depth
+	start := t.here()
+	rest := t.input[t.position:]
+	end := strings.IndexAny(rest, "\r\n")
+	if end < 0 {
+		end = len(rest)
+	}
+	t.advance(end)
+	return t.arena.alloc(NewExceptionToken(rest[:end], tokErr.Reason, t.spanFrom(start))), nil
+}
+
+// keepGoingPast records the error of an exception token that tokenizing goes
+// on past in keep-going mode, as an error diagnostic, and logs it if there is
+// a logger. The first such error is returned once tokenizing is finished.
+func (t *Tokenizer) keepGoingPast(token *Token) {
+	err := &Error{Span: token.Span, Reason: *token.Reason}
+	if t.keepGoingErr == nil {
+		t.keepGoingErr = err
+	}
+	t.diagnostics = append(t.diagnostics, Diagnostic{err.Span, SeverityError, err.Reason})
+	if t.logger == nil {
+		return
+	}
+	t.logger.LogAttrs(context.Background(), slog.LevelError, err.Reason,
+		slog.Int("line", token.Span.Start.Line),
+		slog.Int("col", token.Span.Start.Col),
+		slog.String("text", token.Text),
+	)
+}
@@ -0,0 +1,94 @@
+package tokenizer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestKeepGoing(t *testing.T) {
+	input := "x := \"abc\ny := 'a\\(b + 1\r\nz := @raw\"oops\nf(\"ok\")"
+	result, err := New(input, &Options{KeepGoing: true}).TokenizeResult()
+	var tokErr *Error
+	if !errors.As(err, &tokErr) || tokErr.Reason != "line break in string" || tokErr.Span.Start != (Position{1, 6}) {
+		t.Fatalf("Expected the first error to be returned, got %v", err)
+	}
+
+	expected := []string{"x", ":=", "\"abc", "y", ":=", "'a\\(b + 1", "z", ":=", "@raw\"oops", "f", "(", "\"ok\"", ")"}
+	if got := tokenTexts(result.Tokens); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for _, i := range []int{2, 5, 8} {
+		token := result.Tokens[i]
+		if token.Type != ExceptionTokenType || token.LnAfter == nil || !*token.LnAfter {
+			t.Errorf("Expected %q to be an exception token ending its line, got %s", token.Text, token.Type)
+		}
+	}
+	if got := result.Tokens[5].Span; got != (Span{Position{2, 6}, Position{2, 15}}) {
+		t.Errorf("Expected the exception token to stop before the line break, got %v", got)
+	}
+	if result.Tokens[3].LnBefore == nil || !*result.Tokens[3].LnBefore {
+		t.Errorf("Expected the token after a recovered string to start a line")
+	}
+
+	var messages []string
+	for _, d := range result.Diagnostics {
+		if d.Severity != SeverityError {
+			t.Errorf("Expected error diagnostics, got %s", d.Severity)
+		}
+		messages = append(messages, d.Message)
+	}
+	if expected := []string{"line break in string", "line break in interpolation", "line break in raw string"}; !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Expected one diagnostic per error %v, got %v", expected, messages)
+	}
+}
+
+func TestKeepGoingAfterInterpolation(t *testing.T) {
+	// The interpolation opens a start token and a bracket before failing, and
+	// neither may be left open for the next line.
+	input := "s := 'a\\(if f(x]) b' + t\nu := (2)\n"
+	result, err := New(input, &Options{KeepGoing: true, EndOfFileToken: true, AnnotateContext: true}).TokenizeResult()
+	var tokErr *Error
+	if !errors.As(err, &tokErr) || tokErr.Reason != "mismatched bracket" {
+		t.Fatalf("Expected the interpolation's error to be returned, got %v", err)
+	}
+
+	expected := []string{"s", ":=", "'a\\(if f(x]) b' + t", "u", ":=", "(", "2", ")", ""}
+	if got := tokenTexts(result.Tokens); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	if token := result.Tokens[2]; token.Type != ExceptionTokenType || token.Span != (Span{Position{1, 6}, Position{1, 25}}) {
+		t.Errorf("Expected an exception token running to the end of the line, got %+v", token)
+	}
+	if eof := result.Tokens[len(result.Tokens)-1]; len(eof.Unclosed) != 0 {
+		t.Errorf("Expected nothing left open, got %v", eof.Unclosed)
+	}
+	if token := result.Tokens[5]; token.Type != OpenDelimiterTokenType || token.Context != nil {
+		t.Errorf("Expected the next line to be tokenized outside the interpolation, got %+v", token)
+	}
+}
+
+func TestKeepGoingEndOfInput(t *testing.T) {
+	// A string left open at the end of the input takes the rest of it.
+	var streamed []*Token
+	err := New("a «b", &Options{KeepGoing: true}).TokenizeStream(func(token *Token) error {
+		streamed = append(streamed, token)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if got := tokenTexts(streamed); !reflect.DeepEqual(got, []string{"a", "«b"}) {
+		t.Errorf("Expected [a «b], got %v", got)
+	}
+
+	// A limit still stops tokenizing.
+	input := "x := \"a\\(\"b\\(c)\")\" y"
+	tokens, err := New(input, &Options{KeepGoing: true, Limits: Limits{MaxInterpolationDepth: 1}}).Tokenize()
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected a limit error, got %v", err)
+	}
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, []string{"x", ":="}) {
+		t.Errorf("Expected the tokens before the limit, got %v", got)
+	}
+}
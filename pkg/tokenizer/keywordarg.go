@@ -0,0 +1,42 @@
+package tokenizer
+
+// matchKeywordArg reads a keyword argument, such as x: in f(x: 1), if
+// keyword arguments are enabled and the identifier name at the current
+// position starts one. It must be followed directly by a wildcard, and be
+// the first thing within parentheses or follow a comma there. The name and
+// the wildcard are read as one token, so that the wildcard is not taken for
+// a label it could stand for.
+func (t *Tokenizer) matchKeywordArg(name string) *Token {
+	if !t.keywordArgs || len(t.brackets) == 0 || t.brackets[len(t.brackets)-1] != "(" {
+		return nil
+	}
+	last := t.lastToken()
+	if last == nil || !(last.Type == OpenDelimiterTokenType || (last.Type == MarkTokenType && last.Text == ",")) {
+		return nil
+	}
+	wildcard := t.wildcardAt(t.input[t.position+len(name):])
+	if wildcard == "" {
+		return nil
+	}
+
+	start := t.here()
+	text := t.input[t.position : t.position+len(name)+len(wildcard)]
+	t.advance(len(text))
+	token := t.arena.alloc(NewToken(text, KeywordArgTokenType, t.spanFrom(start)))
+	token.Value = &name
+	return token
+}
+
+// trackBrackets keeps the stack of open brackets up to date with the token
+// just added. A closing bracket closes the innermost open one, whatever it
+// is, as checking that they match is left to the parser.
+func (t *Tokenizer) trackBrackets(token *Token) {
+	switch token.Type {
+	case OpenDelimiterTokenType:
+		t.brackets = append(t.brackets, token.CanonicalText())
+	case CloseDelimiterTokenType:
+		if len(t.brackets) > 0 {
+			t.brackets = t.brackets[:len(t.brackets)-1]
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeywordArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{"f(x: 1, y: 2)", []TokenType{
+			VariableTokenType, OpenDelimiterTokenType, KeywordArgTokenType, NumericLiteralTokenType,
+			MarkTokenType, KeywordArgTokenType, NumericLiteralTokenType, CloseDelimiterTokenType,
+		}},
+		// Only the innermost parentheses count, so the name in the list is
+		// an ordinary variable.
+		{"f([x: 1])", []TokenType{
+			VariableTokenType, OpenDelimiterTokenType, OpenDelimiterTokenType, VariableTokenType,
+			UnclassifiedTokenType, NumericLiteralTokenType, CloseDelimiterTokenType, CloseDelimiterTokenType,
+		}},
+		// After the closing bracket of a nested call the parentheses of the
+		// outer call are innermost again.
+		{"f(g(a), b: 2)", []TokenType{
+			VariableTokenType, OpenDelimiterTokenType, VariableTokenType, OpenDelimiterTokenType,
+			VariableTokenType, CloseDelimiterTokenType, MarkTokenType, KeywordArgTokenType,
+			NumericLiteralTokenType, CloseDelimiterTokenType,
+		}},
+		// A space before the wildcard, or a longer operator, is not a keyword
+		// argument.
+		{"f(x :1, y::z)", []TokenType{
+			VariableTokenType, OpenDelimiterTokenType, VariableTokenType, UnclassifiedTokenType,
+			NumericLiteralTokenType, MarkTokenType, VariableTokenType, UnclassifiedTokenType,
+			UnclassifiedTokenType, VariableTokenType, CloseDelimiterTokenType,
+		}},
+	}
+	for _, test := range tests {
+		tokens, _ := New(test.input, &Options{KeywordArguments: true}).Tokenize()
+		var types []TokenType
+		for _, token := range tokens {
+			types = append(types, token.Type)
+		}
+		if !reflect.DeepEqual(types, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expected, types)
+		}
+	}
+
+	tokens, err := New("f(x: 1)", &Options{KeywordArguments: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokens[2]; got.Text != "x:" || *got.Value != "x" || got.Span != (Span{Position{1, 3}, Position{1, 5}}) {
+		t.Errorf("Expected x: with the value x at 1:3-1:5, got %+v", got)
+	}
+
+	// Without the option, the wildcard is read as usual.
+	tokens, _ = NewTokenizer("f(x: 1)").Tokenize()
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, []string{"f", "(", "x", ":", "1", ")"}) {
+		t.Errorf("Expected the keyword argument to be split without the option, got %v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package tokenizer
+
+import "strings"
+
+// matchLabel reads a statement label, such as outer: in outer: for, if the
+// identifier name at the current position starts one. A label is a name at
+// the start of a statement followed directly by a wildcard and then by a
+// start keyword. It is read as one token, so that the wildcard is not taken
+// for a label that it could otherwise stand for, such as the end of the
+// enclosing form.
+func (t *Tokenizer) matchLabel(name string) *Token {
+	if !t.atStatementStart() || t.awaitingBridge() {
+		return nil
+	}
+	rest := t.input[t.position+len(name):]
+	wildcard := t.wildcardAt(rest)
+	if wildcard == "" {
+		return nil
+	}
+	keyword := identifierRegex.FindString(strings.TrimLeft(rest[len(wildcard):], " \t"))
+	if entry, ok := t.rules.TokenLookup[keyword]; !ok || entry.Type != CustomStart {
+		return nil
+	}
+
+	start := t.here()
+	text := t.input[t.position : t.position+len(name)+len(wildcard)]
+	t.advance(len(text))
+	token := t.arena.alloc(NewToken(text, LabelNameTokenType, t.spanFrom(start)))
+	token.Value = &name
+	return token
+}
+
+// wildcardAt returns the wildcard at the start of rest, or "" if there is
+// none. A wildcard of sign characters that runs on into more of them, such as
+// : in ::, is part of an operator rather than a wildcard.
+func (t *Tokenizer) wildcardAt(rest string) string {
+	wildcard := ""
+	for text := range t.rules.WildcardTokens {
+		if len(text) > len(wildcard) && strings.HasPrefix(rest, text) {
+			wildcard = text
+		}
+	}
+	if operatorRegex.MatchString(wildcard) && operatorRegex.MatchString(rest[len(wildcard):]) {
+		return ""
+	}
+	return wildcard
+}
+
+// atStatementStart reports whether the next token starts a statement: it is
+// the first token, or follows a line break, a mark such as ; or a bridge
+// token such as do. Trivia tokens are skipped over.
+func (t *Tokenizer) atStatementStart() bool {
+	last := t.lastToken()
+	if last == nil || (last.LnAfter != nil && *last.LnAfter) {
+		return true
+	}
+	return last.Type == MarkTokenType || last.Type == BridgeTokenType
+}
+
+// awaitingBridge reports whether the innermost open start token cannot be
+// closed before one of the bridges it expects, as def f(x) must be followed
+// by =>>. A wildcard there is that bridge rather than part of a label.
+func (t *Tokenizer) awaitingBridge() bool {
+	candidates := t.wildcardCandidates()
+	for _, candidate := range candidates {
+		if t.rules.TokenLookup[candidate].Type != CustomBridge {
+			return false
+		}
+	}
+	return len(candidates) > 0
+}
+
+// lastToken returns the last token other than trivia, or nil if there is
+// none.
+func (t *Tokenizer) lastToken() *Token {
+	for i := len(t.tokens) - 1; i >= 0; i-- {
+		if token := t.tokens[i]; token.Type != WhitespaceTokenType && token.Type != CommentTokenType {
+			return token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatementLabels(t *testing.T) {
+	input := "outer: for i in xs do\n  inner: for j in ys do x endfor; again: for k in zs do z endfor\nendfor"
+	tokens, err := NewTokenizer(input).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var labels []string
+	for _, token := range tokens {
+		if token.Type == LabelNameTokenType {
+			labels = append(labels, token.Text+"="+*token.Value)
+		}
+	}
+	if expected := []string{"outer:=outer", "inner:=inner", "again:=again"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected labels %v, got %v", expected, labels)
+	}
+	if got := tokens[0].Span; got != (Span{Position{1, 1}, Position{1, 7}}) {
+		t.Errorf("Expected the label to span 1:1-1:7, got %v", got)
+	}
+	if got := tokens[len(tokens)-1]; got.Type != EndTokenType || got.Text != "endfor" {
+		t.Errorf("Expected the outer loop to be closed by endfor, got %+v", got)
+	}
+}
+
+func TestNotStatementLabels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		// Not at the start of a statement.
+		{"f x: for", []TokenType{VariableTokenType, VariableTokenType, UnclassifiedTokenType, StartTokenType}},
+		// Not followed by a start keyword.
+		{"x: y", []TokenType{VariableTokenType, UnclassifiedTokenType, VariableTokenType}},
+		// Not followed directly by the wildcard.
+		{"x : for", []TokenType{VariableTokenType, UnclassifiedTokenType, StartTokenType}},
+		// The wildcard is the bridge that the open def is waiting for.
+		{"def f(x)\n  g: if a then b endif\nend", []TokenType{
+			StartTokenType, VariableTokenType, OpenDelimiterTokenType, VariableTokenType, CloseDelimiterTokenType,
+			VariableTokenType, BridgeTokenType, StartTokenType, VariableTokenType, BridgeTokenType,
+			VariableTokenType, EndTokenType, EndTokenType}},
+	}
+	for _, test := range tests {
+		tokens, _ := NewTokenizer(test.input).Tokenize()
+		var types []TokenType
+		for _, token := range tokens {
+			types = append(types, token.Type)
+		}
+		if !reflect.DeepEqual(types, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expected, types)
+		}
+	}
+}
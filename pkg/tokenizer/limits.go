@@ -0,0 +1,67 @@
+package tokenizer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is wrapped by the Error returned when tokenizing stops
+// because one of the tokenizer's Limits was reached. Use errors.Is to detect
+// it.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bounds the work a tokenizer will do, so that a service embedding it
+// cannot be overwhelmed by adversarial input. A zero field means that the
+// corresponding quantity is not limited.
+type Limits struct {
+	MaxInputBytes         int // Maximum length of the input in bytes
+	MaxTokens             int // Maximum number of top-level tokens
+	MaxInterpolationDepth int // Maximum nesting of interpolations within strings
+}
+
+// SetLimits sets the resource limits for the tokenizer.
+func (t *Tokenizer) SetLimits(limits Limits) {
+	t.limits = limits
+}
+
+// limitErrorAt creates an Error covering the given span that wraps
+// ErrLimitExceeded.
+func limitErrorAt(span Span, format string, args ...interface{}) error {
+	return &Error{Span: span, Reason: fmt.Sprintf(format, args...), Err: ErrLimitExceeded}
+}
+
+// checkInputSize reports an error if the input is larger than allowed.
+func (t *Tokenizer) checkInputSize() error {
+	if t.limits.MaxInputBytes > 0 && len(t.input) > t.limits.MaxInputBytes {
+		start := Position{Line: 1, Col: 1}
+		return limitErrorAt(Span{start, start}, "input of %d bytes exceeds the limit of %d bytes",
+			len(t.input), t.limits.MaxInputBytes)
+	}
+	return nil
+}
+
+// checkTokenCount reports an error if adding the token would produce more
+// tokens than allowed.
+func (t *Tokenizer) checkTokenCount(token *Token) error {
+	if t.limits.MaxTokens > 0 && len(t.tokens) >= t.limits.MaxTokens {
+		return limitErrorAt(token.Span, "token count exceeds the limit of %d", t.limits.MaxTokens)
+	}
+	return nil
+}
+
+// enterInterpolation records entry into an interpolation whose opening bracket
+// began at start, reporting an error if it is nested more deeply than allowed. Each
+// successful call must be matched by a call to leaveInterpolation.
+func (t *Tokenizer) enterInterpolation(start Position) error {
+	if t.limits.MaxInterpolationDepth > 0 && t.interpolationDepth >= t.limits.MaxInterpolationDepth {
+		return limitErrorAt(t.spanFrom(start), "interpolation nesting exceeds the limit of %d",
+			t.limits.MaxInterpolationDepth)
+	}
+	t.interpolationDepth++
+	return nil
+}
+
+// leaveInterpolation records leaving a nested interpolation.
+func (t *Tokenizer) leaveInterpolation() {
+	t.interpolationDepth--
+}
@@ -0,0 +1,67 @@
+package tokenizer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// nestedInterpolation returns a string literal with depth nested
+// interpolations, such as "\("\(x)")" for a depth of 2.
+func nestedInterpolation(depth int) string {
+	return strings.Repeat(`"\(`, depth) + "x" + strings.Repeat(`)"`, depth)
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		limits   Limits
+		expected Span // Span of the limit error, or the zero span for success
+	}{
+		{"No limits", nestedInterpolation(50), Limits{}, Span{}},
+		{"Input within size", "a b c", Limits{MaxInputBytes: 5}, Span{}},
+		{"Input too large", "a b c d", Limits{MaxInputBytes: 5}, Span{Position{1, 1}, Position{1, 1}}},
+		{"Tokens within count", "a b c", Limits{MaxTokens: 3}, Span{}},
+		{"Too many tokens", "a b\nc d", Limits{MaxTokens: 3}, Span{Position{2, 3}, Position{2, 4}}},
+		{"Interpolation within depth", nestedInterpolation(3), Limits{MaxInterpolationDepth: 3}, Span{}},
+		{"Interpolation too deep", nestedInterpolation(4), Limits{MaxInterpolationDepth: 3}, Span{Position{1, 12}, Position{1, 13}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenizer := NewTokenizer(tt.input)
+			tokenizer.SetLimits(tt.limits)
+			_, err := tokenizer.Tokenize()
+
+			if tt.expected == (Span{}) {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("Expected a limit error, got %v", err)
+			}
+			var tokErr *Error
+			if !errors.As(err, &tokErr) {
+				t.Fatalf("Expected a tokenizer Error, got %v", err)
+			}
+			if tokErr.Span != tt.expected {
+				t.Errorf("Expected error span %v, got %v", tt.expected, tokErr.Span)
+			}
+		})
+	}
+}
+
+func TestLimitsKeepTokensSoFar(t *testing.T) {
+	tokenizer := NewTokenizer("a b c d e")
+	tokenizer.SetLimits(Limits{MaxTokens: 2})
+	tokens, err := tokenizer.Tokenize()
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("Expected a limit error, got %v", err)
+	}
+	if got := tokenTexts(tokens); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("Expected the first two tokens, got %v", got)
+	}
+}
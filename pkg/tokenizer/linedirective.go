@@ -0,0 +1,106 @@
+package tokenizer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lineDirectiveRegex matches a ###line directive, such as ###line 12 "a.tmpl".
+var lineDirectiveRegex = regexp.MustCompile(`^###line[ \t]+(\d+)(?:[ \t]+"([^"]*)")?[ \t]*$`)
+
+// applyLineDirective checks whether a comment is a ###line directive and if
+// so makes the line after it report the given line number, and optionally
+// file, in the positions of the tokens that follow. Comments that begin like
+// a directive but do not match its syntax are warned about and ignored.
+func (t *Tokenizer) applyLineDirective(comment string, start Position) {
+	if !isLineDirectiveLike(comment) {
+		return
+	}
+	match := lineDirectiveRegex.FindStringSubmatch(comment)
+	if match == nil {
+		t.warn(start, comment, "malformed ###line directive")
+		return
+	}
+	line, err := strconv.Atoi(match[1])
+	if err != nil || line < 1 {
+		t.warn(start, comment, "invalid line number in ###line directive")
+		return
+	}
+	// The directive is on the current line, so the next line is line.
+	t.lineOffset = line - (t.line + 1)
+	if match[2] != "" {
+		t.file = match[2]
+	}
+	remap := LineRemap{InputLine: t.line + 1, Line: line}
+	if t.file != t.filename {
+		remap.File = t.file
+	}
+	t.remaps = append(t.remaps, remap)
+}
+
+// isLineDirectiveLike reports whether a comment starts with the ###line
+// keyword, as opposed to an ordinary comment such as ###lines of code.
+func isLineDirectiveLike(comment string) bool {
+	rest, ok := strings.CutPrefix(comment, "###line")
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// LineRemap records that, from a line of the input on, lines are reported as
+// numbered from another line, and perhaps of another file, as a ###line
+// directive asks.
+type LineRemap struct {
+	InputLine int    `json:"input_line"`     // The first line of the input it applies to
+	Line      int    `json:"line"`           // The line that the first line is reported as
+	File      string `json:"file,omitempty"` // The file reported, or "" for the input itself
+}
+
+// LineRemaps is the table of the lines of an input that were renumbered, in
+// the order of the input. It translates between the positions of the tokens
+// and the lines of the input they came from, so that a diagnostic about a
+// token can point at the input as it was given to the tokenizer.
+type LineRemaps []LineRemap
+
+// LineRemaps returns the line remapping table for the input tokenized so far.
+// The first entry maps the first line of the input to itself, and each
+// ###line directive adds another.
+func (t *Tokenizer) LineRemaps() LineRemaps {
+	return append(LineRemaps{{InputLine: 1, Line: 1}}, t.remaps...)
+}
+
+// ReportedLine returns the line and file, "" for the input itself, that a
+// line of the input is reported as.
+func (r LineRemaps) ReportedLine(inputLine int) (int, string) {
+	line, file := inputLine, ""
+	for _, remap := range r {
+		if remap.InputLine > inputLine {
+			break
+		}
+		line, file = remap.Line+inputLine-remap.InputLine, remap.File
+	}
+	return line, file
+}
+
+// InputLine returns the line of the input that is reported as the given line
+// of the file, "" for the input itself, or 0 if there is none. If several
+// are, as when two directives name the same lines, the first is returned.
+func (r LineRemaps) InputLine(line int, file string) int {
+	for i, remap := range r {
+		if remap.File != file || line < remap.Line {
+			continue
+		}
+		inputLine := remap.InputLine + line - remap.Line
+		if i+1 == len(r) || inputLine < r[i+1].InputLine {
+			return inputLine
+		}
+	}
+	return 0
+}
+
+// attachFile records on the token the file named by the last ###line
+// directive, if it is not the input itself.
+func (t *Tokenizer) attachFile(token *Token) {
+	if t.file != "" && t.file != t.filename {
+		token.File = t.file
+	}
+}
@@ -0,0 +1,89 @@
+package tokenizer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLineDirectives(t *testing.T) {
+	input := "a\n###line 10 \"page.tmpl\"\nb\nc\n###line 3\nd\n###line 7 \"main.nutmeg\"\ne\n###lines of code\nf"
+	tokens, err := New(input, &Options{Filename: "main.nutmeg"}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		text string
+		line int
+		file string
+	}{
+		{"a", 1, ""},
+		{"b", 10, "page.tmpl"},
+		{"c", 11, "page.tmpl"},
+		{"d", 3, "page.tmpl"},
+		// A directive naming the input itself needs no file field.
+		{"e", 7, ""},
+		// An ordinary comment is not a directive.
+		{"f", 9, ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, want := range expected {
+		got := tokens[i]
+		if got.Text != want.text || got.Span.Start.Line != want.line || got.File != want.file {
+			t.Errorf("token %d: expected %q on line %d of %q, got %q on line %d of %q",
+				i, want.text, want.line, want.file, got.Text, got.Span.Start.Line, got.File)
+		}
+	}
+}
+
+func TestLineRemaps(t *testing.T) {
+	input := "a\n###line 10 \"page.tmpl\"\nb\nc\n###line 3\nd\n###line 7 \"main.nutmeg\"\ne\n###lines of code\nf"
+	tokenizer := New(input, &Options{Filename: "main.nutmeg"})
+	tokens, err := tokenizer.Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	remaps := tokenizer.LineRemaps()
+	expected := LineRemaps{{1, 1, ""}, {3, 10, "page.tmpl"}, {6, 3, "page.tmpl"}, {8, 7, ""}}
+	if !reflect.DeepEqual(remaps, expected) {
+		t.Fatalf("expected the remaps %v, got %v", expected, remaps)
+	}
+
+	// Each token's position leads back to the line of the input it is on.
+	inputLines := []int{1, 3, 4, 6, 8, 10}
+	for i, token := range tokens {
+		if got := remaps.InputLine(token.Span.Start.Line, token.File); got != inputLines[i] {
+			t.Errorf("%q: expected input line %d, got %d", token.Text, inputLines[i], got)
+		}
+		line, file := remaps.ReportedLine(inputLines[i])
+		if line != token.Span.Start.Line || file != token.File {
+			t.Errorf("%q: expected line %d of %q, got line %d of %q", token.Text, token.Span.Start.Line, token.File, line, file)
+		}
+	}
+	if got := remaps.InputLine(5, "other.tmpl"); got != 0 {
+		t.Errorf("expected no input line for a file never named, got %d", got)
+	}
+
+	// Without directives there is only the identity entry.
+	plain := New("x\ny", nil)
+	if _, err := plain.Tokenize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := plain.LineRemaps().InputLine(2, ""); got != 2 {
+		t.Errorf("expected line 2 to be itself, got %d", got)
+	}
+}
+
+func TestLineDirectiveErrors(t *testing.T) {
+	_, err := New("###line 20 \"gen.tmpl\"\nx = \"unterminated", nil).Tokenize()
+	var tokErr *Error
+	if !errors.As(err, &tokErr) {
+		t.Fatalf("expected a tokenizer error, got %v", err)
+	}
+	if tokErr.Span.Start.Line != 20 {
+		t.Errorf("expected the error on line 20, got %v", tokErr.Span)
+	}
+}
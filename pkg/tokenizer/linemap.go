@@ -0,0 +1,126 @@
+package tokenizer
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// LineIndex translates between 1-based line and column positions and byte
+// offsets into the input. Its columns count what its column policy says, which
+// is characters for an index made by NewLineIndex. An index read back from
+// JSON, such as that of a source map, has no input to count characters in, so
+// its columns count bytes.
+type LineIndex struct {
+	LineStarts []int `json:"line_starts"` // Byte offset at which each line starts
+	Length     int   `json:"length"`      // Length of the input in bytes
+
+	input  string       // The input, for columns that do not count bytes
+	policy ColumnPolicy // What the columns of positions count
+}
+
+// NewLineIndex builds the line index for the input, with columns that count
+// characters.
+func NewLineIndex(input string) *LineIndex {
+	return NewLineIndexWithPolicy(input, ColumnRunes)
+}
+
+// NewLineIndexWithPolicy builds the line index for the input, with columns
+// that count what the policy says, as those of the tokens of a tokenizer
+// with that policy do.
+func NewLineIndexWithPolicy(input string, policy ColumnPolicy) *LineIndex {
+	starts := []int{0}
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &LineIndex{LineStarts: starts, Length: len(input), input: input, policy: policy}
+}
+
+// LineIndex returns the line index for the tokenizer's input, with columns
+// that count what the tokenizer's column policy says.
+func (t *Tokenizer) LineIndex() *LineIndex {
+	return NewLineIndexWithPolicy(t.input, t.columnPolicy)
+}
+
+// Offset returns the byte offset of the position, or -1 if the position does
+// not lie within the input. The position just past the end of a line is
+// within the input, but a column within a character, such as between the
+// two UTF-16 code units of an emoji, is not.
+func (li *LineIndex) Offset(p Position) int {
+	if p.Line < 1 || p.Line > len(li.LineStarts) || p.Col < 1 {
+		return -1
+	}
+	lineEnd := li.Length
+	if p.Line < len(li.LineStarts) {
+		lineEnd = li.LineStarts[p.Line] - 1 // The offset of the newline
+	}
+	offset := li.LineStarts[p.Line-1]
+	if li.countsBytes() {
+		offset += p.Col - 1
+		if offset > lineEnd {
+			return -1
+		}
+		return offset
+	}
+	for col := 1; col < p.Col; {
+		if offset >= lineEnd {
+			return -1
+		}
+		_, size := utf8.DecodeRuneInString(li.input[offset:lineEnd])
+		col += li.policy.width(li.input[offset : offset+size])
+		offset += size
+		if col > p.Col {
+			return -1
+		}
+	}
+	return offset
+}
+
+// countsBytes reports whether the columns of the index count bytes, as they
+// do under ColumnBytes or when the index has no input to count anything else
+// in.
+func (li *LineIndex) countsBytes() bool {
+	return li.policy == ColumnBytes || len(li.input) != li.Length
+}
+
+// Position returns the position of the byte offset. Offsets outside the input
+// are clamped to its start or end.
+func (li *LineIndex) Position(offset int) Position {
+	offset = max(0, min(offset, li.Length))
+	// Find the last line that starts at or before the offset.
+	line := sort.Search(len(li.LineStarts), func(i int) bool {
+		return li.LineStarts[i] > offset
+	})
+	lineStart := li.LineStarts[line-1]
+	if li.countsBytes() {
+		return Position{Line: line, Col: offset - lineStart + 1}
+	}
+	return Position{Line: line, Col: li.policy.width(li.input[lineStart:offset]) + 1}
+}
+
+// SourceMap is a compact sidecar for a token stream, letting downstream tools
+// translate token positions into byte offsets without re-reading the source.
+type SourceMap struct {
+	LineIndex
+	Tokens [][2]int `json:"tokens"` // Start and end byte offsets of each token, by index
+}
+
+// NewSourceMap builds the source map for tokens taken from input, whose
+// columns count characters.
+func NewSourceMap(input string, tokens []*Token) *SourceMap {
+	return NewSourceMapWithPolicy(input, tokens, ColumnRunes)
+}
+
+// NewSourceMapWithPolicy builds the source map for tokens taken from input,
+// whose columns count what the policy says. The offsets of the map are byte
+// offsets whatever the policy.
+func NewSourceMapWithPolicy(input string, tokens []*Token, policy ColumnPolicy) *SourceMap {
+	index := NewLineIndexWithPolicy(input, policy)
+	sm := &SourceMap{LineIndex: LineIndex{LineStarts: index.LineStarts, Length: index.Length}}
+	sm.Tokens = make([][2]int, len(tokens))
+	for i, token := range tokens {
+		sm.Tokens[i] = [2]int{index.Offset(token.Span.Start), index.Offset(token.Span.End)}
+	}
+	return sm
+}
@@ -0,0 +1,60 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineIndex(t *testing.T) {
+	input := "ab\n\ncdé\n"
+	index := NewLineIndex(input)
+
+	if !reflect.DeepEqual(index.LineStarts, []int{0, 3, 4, 9}) {
+		t.Errorf("Unexpected line starts: %v", index.LineStarts)
+	}
+
+	tests := []struct {
+		position Position
+		offset   int
+	}{
+		{Position{1, 1}, 0},
+		{Position{1, 3}, 2}, // The newline ending line 1
+		{Position{2, 1}, 3}, // An empty line
+		{Position{3, 3}, 6},
+		{Position{3, 4}, 8}, // Columns count characters, and é takes one
+		{Position{4, 1}, 9}, // The end of the input
+	}
+	for _, tt := range tests {
+		if got := index.Offset(tt.position); got != tt.offset {
+			t.Errorf("Offset(%v): expected %d, got %d", tt.position, tt.offset, got)
+		}
+		if got := index.Position(tt.offset); got != tt.position {
+			t.Errorf("Position(%d): expected %v, got %v", tt.offset, tt.position, got)
+		}
+	}
+
+	for _, p := range []Position{{0, 1}, {1, 0}, {1, 4}, {5, 1}} {
+		if got := index.Offset(p); got != -1 {
+			t.Errorf("Offset(%v): expected -1 for a position outside the input, got %d", p, got)
+		}
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	input := "if x then\n  \"é\"\nendif"
+	tokens, err := New(input, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	sourceMap := NewSourceMap(input, tokens)
+	if len(sourceMap.Tokens) != len(tokens) {
+		t.Fatalf("Expected %d token offsets, got %d", len(tokens), len(sourceMap.Tokens))
+	}
+	for i, token := range tokens {
+		offsets := sourceMap.Tokens[i]
+		if got := input[offsets[0]:offsets[1]]; got != token.Text {
+			t.Errorf("Token %d: offsets %v cover %q, expected %q", i, offsets, got, token.Text)
+		}
+	}
+}
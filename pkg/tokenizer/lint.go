@@ -0,0 +1,37 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// checkLineLength warns if the line that ends at the current position, at a
+// newline or the end of the input, is longer than the maximum length. Lines
+// are measured in characters, not counting the line ending.
+func (t *Tokenizer) checkLineLength() {
+	if t.position < t.lengthChecked {
+		// The scan has backtracked, as it does when looking ahead through a
+		// multi-line string, and this line has been checked already.
+		return
+	}
+	t.lengthChecked = t.position + 1
+	// Columns count bytes, characters or UTF-16 code units, which are never
+	// fewer than the characters.
+	if t.column-1 <= t.maxLineLength {
+		return
+	}
+	lineStart := strings.LastIndexByte(t.input[:t.position], '\n') + 1
+	line := strings.TrimSuffix(t.input[lineStart:t.position], "\r")
+	length := utf8.RuneCountInString(line)
+	if length <= t.maxLineLength {
+		return
+	}
+	offset := 0
+	for range t.maxLineLength {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	t.warn(Position{t.line + t.lineOffset, t.columnPolicy.width(line[:offset]) + 1}, line[offset:],
+		fmt.Sprintf("line is %d characters long, more than %d", length, t.maxLineLength))
+}
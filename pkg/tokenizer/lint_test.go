@@ -0,0 +1,68 @@
+package tokenizer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLineLengthAndTrailingWhitespace(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  Options
+		expected []string
+	}{
+		{"abcdefghij\nabcdefghijk\n", Options{MaxLineLength: 10}, []string{"2:11 line is 11 characters long, more than 10"}},
+		{"abcdefghijk", Options{MaxLineLength: 10}, []string{"1:11 line is 11 characters long, more than 10"}},
+		{"abcdéfghij\r\n", Options{MaxLineLength: 10}, nil},
+		{"x := \"\"\"\n  a long line inside\n  \"\"\"\n", Options{MaxLineLength: 10}, []string{"2:11 line is 20 characters long, more than 10"}},
+		{"x  \n\t\ny\r\nz  ", Options{WarnTrailingWhitespace: true}, []string{"1:2 trailing whitespace", "2:1 trailing whitespace"}},
+		{"x  \nabcdefghijk", Options{}, nil},
+	}
+	for _, test := range tests {
+		result, err := New(test.input, &test.options).TokenizeResult()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		var messages []string
+		for _, d := range result.Diagnostics {
+			messages = append(messages, fmt.Sprintf("%d:%d %s", d.Span.Start.Line, d.Span.Start.Col, d.Message))
+		}
+		if !reflect.DeepEqual(messages, test.expected) {
+			t.Errorf("For %q expected %v, got %v", test.input, test.expected, messages)
+		}
+	}
+
+	// In strict mode a long last line stops tokenizing.
+	if _, err := New("abcdefghijk", &Options{MaxLineLength: 10, Strict: true}).Tokenize(); err == nil {
+		t.Errorf("Expected a strict error for a long last line")
+	}
+}
+
+func TestNumberRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  Options
+		expected []string
+	}{
+		{"255 256", Options{MaxIntegerBits: 8}, []string{"1:5 integer literal 256 needs 9 bits, more than 8"}},
+		{"0xFF 0x100 2r100000000", Options{MaxIntegerBits: 8}, []string{"1:6 integer literal 256 needs 9 bits, more than 8", "1:12 integer literal 256 needs 9 bits, more than 8"}},
+		{"0t1TTTTT 0t11TTTT", Options{MaxIntegerBits: 8}, []string{"1:10 integer literal 284 needs 9 bits, more than 8"}},
+		{"256.0 256e0", Options{MaxIntegerBits: 8}, nil},
+		{"1e308 1e-309 0x1p400", Options{MaxExponent: 308}, []string{"1:7 exponent of 10^-309 is beyond the limit of 308", "1:14 exponent of 2^400 is beyond the limit of 308"}},
+		{"1e400 100000000000000000000", Options{}, nil},
+	}
+	for _, test := range tests {
+		result, err := New(test.input, &test.options).TokenizeResult()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		var messages []string
+		for _, d := range result.Diagnostics {
+			messages = append(messages, fmt.Sprintf("%d:%d %s", d.Span.Start.Line, d.Span.Start.Col, d.Message))
+		}
+		if !reflect.DeepEqual(messages, test.expected) {
+			t.Errorf("For %q expected %v, got %v", test.input, test.expected, messages)
+		}
+	}
+}
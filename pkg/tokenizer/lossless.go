@@ -0,0 +1,23 @@
+package tokenizer
+
+// keepTrivia records skipped whitespace or a comment as a trivia token, if
+// the tokenizer is in lossless mode.
+func (t *Tokenizer) keepTrivia(tokenType TokenType, text string, start Position) {
+	if t.lossless {
+		t.trivia = append(t.trivia, t.arena.alloc(NewToken(text, tokenType, t.spanFrom(start))))
+	}
+}
+
+// flushTrivia adds the trivia tokens recorded since the last token. Trivia
+// bypasses the expecting stack, and counts towards the token limit.
+func (t *Tokenizer) flushTrivia() error {
+	for _, token := range t.trivia {
+		if err := t.checkTokenCount(token); err != nil {
+			return err
+		}
+		t.tokens = append(t.tokens, token)
+	}
+	clear(t.trivia)
+	t.trivia = t.trivia[:0]
+	return nil
+}
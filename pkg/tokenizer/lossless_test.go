@@ -0,0 +1,53 @@
+package tokenizer
+
+import "testing"
+
+func TestLossless(t *testing.T) {
+	input := "#### Doc.\ndef f(x)  ### Comment.\r\n\tx\nend\n"
+	tokens, err := New(input, &Options{Lossless: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkLossless(t, input, tokens)
+
+	expected := []struct {
+		text      string
+		tokenType TokenType
+	}{
+		{"#### Doc.", CommentTokenType},
+		{"\n", WhitespaceTokenType},
+		{"def", StartTokenType},
+		{" ", WhitespaceTokenType},
+		{"f", VariableTokenType},
+		{"(", OpenDelimiterTokenType},
+		{"x", VariableTokenType},
+		{")", CloseDelimiterTokenType},
+		{"  ", WhitespaceTokenType},
+		{"### Comment.", CommentTokenType},
+		{"\r\n\t", WhitespaceTokenType},
+		{"x", VariableTokenType},
+		{"\n", WhitespaceTokenType},
+		{"end", EndTokenType},
+		{"\n", WhitespaceTokenType},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, want := range expected {
+		if tokens[i].Text != want.text || tokens[i].Type != want.tokenType {
+			t.Errorf("token %d: expected %q %s, got %q %s", i, want.text, want.tokenType, tokens[i].Text, tokens[i].Type)
+		}
+	}
+
+	// Trivia does not disturb the newline flags or doc comments of the other
+	// tokens.
+	if tokens[2].Doc == nil || *tokens[2].Doc != "Doc." {
+		t.Errorf("expected the doc comment to be attached to def")
+	}
+	if tokens[7].LnAfter == nil || !*tokens[7].LnAfter {
+		t.Errorf("expected ln_after on ) before the comment")
+	}
+	if tokens[11].LnBefore == nil || !*tokens[11].LnBefore {
+		t.Errorf("expected ln_before on x after the comment")
+	}
+}
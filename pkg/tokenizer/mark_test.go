@@ -0,0 +1,59 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarkRules(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Mark:     []MarkRule{{Text: ",", Role: MarkSeparator}, {Text: ";;", Role: MarkTerminator}, {Text: "|"}},
+		Operator: []OperatorRule{{Text: "||", Precedence: &[3]int{0, 500, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"f(a, b);; c", []string{"V f", "[ (", "V a", "M , separator", "V b", "] )", "M ;; terminator", "V c"}},
+		{"a +| b |- c", []string{"V a", "O +", "M |", "V b", "M |", "O -", "V c"}},
+		{"a || b ||| c", []string{"V a", "O ||", "V b", "O ||", "M |", "V c"}},
+		{"a ~| b", []string{"V a", "U ~", "M |", "V b"}},
+	}
+	for _, test := range tests {
+		tokens, err := New(test.input, &Options{Rules: rules}).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		var got []string
+		for _, token := range tokens {
+			s := string(token.Type) + " " + token.Text
+			if token.Role != "" {
+				s += " " + token.Role
+			}
+			got = append(got, s)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("For %q expected %v, got %v", test.input, test.expected, got)
+		}
+	}
+
+	// The default marks have no role.
+	tokens, err := New("a, b", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tokens[1].Role != "" {
+		t.Errorf("Expected no role for the default ',', got %q", tokens[1].Role)
+	}
+}
+
+func TestMarkRuleValidation(t *testing.T) {
+	for _, rule := range []MarkRule{{Text: ""}, {Text: ";", Role: "ending"}} {
+		if _, err := ApplyRulesToDefaults(&RulesFile{Mark: []MarkRule{rule}}); err == nil {
+			t.Errorf("Expected an error for the mark rule %+v", rule)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package tokenizer
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// MatcherFunc is a custom token matcher. It is given the unconsumed input and
+// returns the token found at its start together with the number of bytes the
+// token covers, or a nil token if it does not match. The tokenizer fills in
+// the token's span, and its text if that is empty. Returning an exception
+// token stops tokenizing, as for the built-in matchers.
+type MatcherFunc func(rest string) (token *Token, length int)
+
+// The priorities of the built-in matchers. A registered matcher runs before
+// the built-in ones with a higher priority and after those with the same or a
+// lower priority. Whatever no matcher claims becomes an unclassified token.
+const (
+	QuasiQuoteMatcherPriority = 50  // Quasi-quoted templates and their holes
+	StringMatcherPriority     = 100 // String literals
+	NumericMatcherPriority    = 200 // Numeric literals
+	RulesMatcherPriority      = 300 // Identifiers, operators and everything in the rules
+)
+
+// matcher is an entry in the ordered list of matchers that nextToken tries.
+type matcher struct {
+	priority int
+	name     string // Used in the trace
+	match    func(t *Tokenizer) (*Token, error)
+}
+
+// builtinMatchers are the matchers used by a tokenizer with none registered.
+var builtinMatchers []matcher
+
+// The built-in matchers are set up in init because the rules matcher can
+// create tokenizers of its own, to expand defines, which would otherwise make
+// builtinMatchers refer to itself.
+func init() {
+	builtinMatchers = []matcher{
+		{QuasiQuoteMatcherPriority, "quasi-quote", func(t *Tokenizer) (*Token, error) {
+			return t.matchQuasiQuote(), nil
+		}},
+		{StringMatcherPriority, "string", (*Tokenizer).matchString},
+		{NumericMatcherPriority, "numeric", func(t *Tokenizer) (*Token, error) {
+			return t.matchNumeric(), nil
+		}},
+		{RulesMatcherPriority, "rules", func(t *Tokenizer) (*Token, error) {
+			return t.matchCustomRules(), nil
+		}},
+	}
+}
+
+// RegisterMatcher adds a custom matcher that runs at the given priority
+// relative to the built-in matchers, for example before the string matcher
+// with a priority below StringMatcherPriority. Matchers registered with the
+// same priority run in the order they were registered.
+func (t *Tokenizer) RegisterMatcher(priority int, fn MatcherFunc) {
+	m := matcher{
+		priority: priority,
+		name:     fmt.Sprintf("matcher@%d", priority),
+		match: func(t *Tokenizer) (*Token, error) {
+			return t.runMatcherFunc(fn), nil
+		},
+	}
+	i := sort.Search(len(t.matchers), func(i int) bool {
+		return t.matchers[i].priority > priority
+	})
+	// The list is copied because it may still be shared with other
+	// tokenizers, as builtinMatchers is.
+	t.matchers = slices.Insert(slices.Clip(t.matchers), i, m)
+}
+
+// runMatcherFunc calls a custom matcher and completes the token it returns.
+func (t *Tokenizer) runMatcherFunc(fn MatcherFunc) *Token {
+	rest := t.input[t.position:]
+	token, length := fn(rest)
+	if token == nil {
+		return nil
+	}
+	// A match of nothing would be found again at the same position forever,
+	// so it is treated as no match at all.
+	if length <= 0 {
+		return nil
+	}
+	length = min(length, len(rest))
+	start := t.here()
+	t.advance(length)
+	if token.Text == "" {
+		token.Text = rest[:length]
+	}
+	token.Span = t.spanFrom(start)
+	return token
+}
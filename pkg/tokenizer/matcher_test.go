@@ -0,0 +1,103 @@
+package tokenizer
+
+import (
+	"regexp"
+	"testing"
+)
+
+var dateRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+// dateTokenType is the type given to date literals by matchDate.
+const dateTokenType TokenType = "d"
+
+// matchDate is a custom matcher for date literals such as 2024-01-31.
+func matchDate(rest string) (*Token, int) {
+	if match := dateRegex.FindString(rest); match != "" {
+		return &Token{Type: dateTokenType}, len(match)
+	}
+	return nil, 0
+}
+
+func TestRegisterMatcher(t *testing.T) {
+	tokenizer := New("x := 2024-01-31 - 1", nil)
+	tokenizer.RegisterMatcher(NumericMatcherPriority-1, matchDate)
+	tokens, err := tokenizer.Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		text      string
+		tokenType TokenType
+		span      Span
+	}{
+		{"x", VariableTokenType, Span{Position{1, 1}, Position{1, 2}}},
+		{":=", OperatorTokenType, Span{Position{1, 3}, Position{1, 5}}},
+		{"2024-01-31", dateTokenType, Span{Position{1, 6}, Position{1, 16}}},
+		{"-", OperatorTokenType, Span{Position{1, 17}, Position{1, 18}}},
+		{"1", NumericLiteralTokenType, Span{Position{1, 19}, Position{1, 20}}},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, want := range expected {
+		got := tokens[i]
+		if got.Text != want.text || got.Type != want.tokenType || got.Span != want.span {
+			t.Errorf("token %d: expected %q %s %v, got %q %s %v",
+				i, want.text, want.tokenType, want.span, got.Text, got.Type, got.Span)
+		}
+	}
+}
+
+func TestRegisterMatcherPriority(t *testing.T) {
+	// A matcher after the numeric matcher never sees digits.
+	late := New("2024-01-31", nil)
+	late.RegisterMatcher(NumericMatcherPriority, matchDate)
+	tokens, err := late.Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens[0].Type != NumericLiteralTokenType {
+		t.Errorf("expected the numeric matcher to win, got %s", tokens[0].Type)
+	}
+
+	// Matchers with the same priority run in the order registered.
+	first := func(rest string) (*Token, int) { return &Token{Type: MarkTokenType}, 1 }
+	second := func(rest string) (*Token, int) { return &Token{Type: PrefixTokenType}, 1 }
+	ordered := New("x", nil)
+	ordered.RegisterMatcher(0, first)
+	ordered.RegisterMatcher(0, second)
+	tokens, err = ordered.Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens[0].Type != MarkTokenType {
+		t.Errorf("expected the first matcher to win, got %s", tokens[0].Type)
+	}
+}
+
+func TestRegisterMatcherEmptyMatch(t *testing.T) {
+	empty := func(rest string) (*Token, int) { return &Token{Type: MarkTokenType}, 0 }
+	tokenizer := New("x", nil)
+	tokenizer.RegisterMatcher(0, empty)
+	tokens, err := tokenizer.Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].Type != VariableTokenType {
+		t.Errorf("expected an empty match to be ignored, got %v", tokens)
+	}
+}
+
+func TestRegisterMatcherDoesNotAffectOthers(t *testing.T) {
+	tokenizer := New("2024-01-31", nil)
+	tokenizer.RegisterMatcher(0, matchDate)
+	other := New("2024-01-31", nil)
+	tokens, err := other.Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens[0].Type != NumericLiteralTokenType {
+		t.Errorf("expected the other tokenizer to be unaffected, got %s", tokens[0].Type)
+	}
+}
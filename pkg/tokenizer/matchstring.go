@@ -1,7 +1,6 @@
 package tokenizer
 
 import (
-	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,21 +15,36 @@ func (t *Tokenizer) matchString() (*Token, error) {
 	}
 
 	r, ok := t.peek()
-	if !ok || !isOpeningQuoteChar(r) {
-		if r == '@' {
-			return t.matchRawString()
+	quote, isQuote := t.quoteData(r)
+	if !ok || !isQuote {
+		if r == '@' && t.startsRawString() {
+			return t.readSingleLineString(t.matchRawString)
 		}
+		// Any other '@' is not a string prefix, so it is left to the rules,
+		// which may read it as an operator or mark, or to the fallback.
 		return nil, nil
 	}
 
 	_, ok = t.tryPeekTripleOpeningQuotes()
 	if ok {
-		return t.readMultilineString(false)
+		token, err := t.readMultilineString(quote.Raw)
+		if err != nil {
+			return nil, err
+		}
+		t.tokenizeFence(token)
+		return token, nil
 	}
-	return t.readString(false, r)
+	return t.readSingleLineString(func() (*Token, error) {
+		if quote.Raw {
+			return t.readRawString(false, r)
+		}
+		return t.readString(false, r)
+	})
 }
 
 func (t *Tokenizer) matchRawString() (*Token, error) {
+	startPosition := t.position
+	start := t.here()
 	t.consume() // Consume the '@'
 	tagText := ""
 	r, ok := t.peek()
@@ -38,7 +52,7 @@ func (t *Tokenizer) matchRawString() (*Token, error) {
 		tagText = t.takeTagText()
 	}
 	r, ok = t.peek()
-	if ok && isOpeningQuoteChar(r) {
+	if ok && t.isOpeningQuote(r) {
 		_, is_triple := t.tryPeekTripleOpeningQuotes()
 		var token *Token
 		var terr error
@@ -50,18 +64,41 @@ func (t *Tokenizer) matchRawString() (*Token, error) {
 		if terr != nil {
 			return token, terr
 		}
-		if token.Specifier != nil && tagText != "" && *token.Specifier != tagText {
-			return nil, fmt.Errorf("tag specifier '%s' does not match existing specifier '%s' at line %d, column %d", tagText, *token.Specifier, t.line, t.column)
+		if token.Specifier != nil && *token.Specifier != "" && tagText != "" && *token.Specifier != tagText {
+			return nil, t.errorFrom(start, "tag specifier '%s' does not match existing specifier '%s'", tagText, *token.Specifier)
 		}
 		if tagText != "" {
 			token.Specifier = &tagText
 		}
+		if is_triple {
+			t.tokenizeFence(token)
+		}
+		// The string readers start after the '@' and tag, so widen the span
+		// to cover them.
+		token.Text = t.input[startPosition:t.position]
+		token.Span.Start = start
 		return token, nil
 	} else {
-		return nil, fmt.Errorf("expected string after @ at line %d, column %d", t.line, t.column)
+		return nil, t.errorFrom(start, "expected string after @")
 	}
 }
 
+// startsRawString reports whether the input at the '@' under the position
+// is the start of a raw string: the '@' and an optional tag followed by an
+// opening quote.
+func (t *Tokenizer) startsRawString() bool {
+	rest := t.input[t.position+1:]
+	for i, r := range rest {
+		if i == 0 && unicode.IsDigit(r) {
+			return false
+		}
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			return t.isOpeningQuote(r)
+		}
+	}
+	return false
+}
+
 // TODO: I think this is a repeat of readSpecifier
 func (t *Tokenizer) takeTagText() string {
 	var text strings.Builder
@@ -80,34 +117,42 @@ func (t *Tokenizer) takeTagText() string {
 
 func (t *Tokenizer) readString(unquoted bool, default_quote rune) (*Token, error) {
 	start_position := t.position
-	startLine, startCol := t.line, t.column
+	start := t.here()
 	currPosition := t.position
-	currSpan := Span{Position{startLine, startCol}, Position{-1, -1}}
-	quote := default_quote
+	currStart := start
+	openingQuote := default_quote
 	if !unquoted {
-		quote = getMatchingCloseQuote(t.consume()) // Consume the opening quote
+		openingQuote = t.consume() // Consume the opening quote
 	}
+	quote := getMatchingCloseQuote(openingQuote)
+	data, _ := t.quoteData(openingQuote)
 	var value strings.Builder
 	var interpolationTokens []*Token
+	depth := 0 // The number of nested guillemet strings that are open
 
 	for {
 		if !t.hasMoreInput() {
-			return nil, fmt.Errorf("unterminated string at line %d, column %d", startLine, startCol)
+			return nil, t.errorFrom(start, "unterminated string")
 		}
-		beforeBackSlash := Position{t.line, t.column}
+		beforeBackSlash := t.here()
+		beforeBackSlashPosition := t.position
 		r := t.consume()
 		if !unquoted && r == quote { // Closing quote found
-			break
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if !unquoted && isNestingQuote(r, quote) {
+			depth++
 		}
 		if r == '\\' && t.hasMoreInput() { // Handle escape or interpolation
 			next, _ := t.peek()
-			if next == '(' || next == '[' || next == '{' {
+			if data.Interpolation && (next == '(' || next == '[' || next == '{') {
 				// End the current StringToken and handle interpolation
 				if value.Len() > 0 {
-					textString := t.input[currPosition:t.position]
-					currSpan.End = beforeBackSlash
+					textString := t.input[currPosition:beforeBackSlashPosition]
 					valueString := value.String()
-					current := NewStringToken(textString, valueString, currSpan)
+					current := NewStringToken(textString, valueString, Span{currStart, beforeBackSlash})
 					current.SetQuote(quote)
 					interpolationTokens = append(interpolationTokens, current)
 					value.Reset()
@@ -118,7 +163,12 @@ func (t *Tokenizer) readString(unquoted bool, default_quote rune) (*Token, error
 				}
 				interpolationTokens = append(interpolationTokens, interpolatedToken)
 				currPosition = t.position
-				currSpan = Span{Position{t.line, t.column}, Position{-1, -1}}
+				currStart = t.here()
+			} else if !unquoted && t.continueLines && (next == '\n' || next == '\r') {
+				// The string goes on after the indentation of the next line,
+				// with neither the backslash nor the line break in its value.
+				t.tryConsumeNewline()
+				t.skipSpacesUpToNewline()
 			} else {
 				value.WriteString(handleEscapeSequence(t))
 			}
@@ -129,7 +179,7 @@ func (t *Tokenizer) readString(unquoted bool, default_quote rune) (*Token, error
 				}
 				break
 			}
-			return nil, fmt.Errorf("line break in string, at line %d, column %d", startLine, startCol)
+			return nil, errorAt(Span{start, beforeBackSlash}, "line break in string")
 		} else {
 			value.WriteRune(r)
 		}
@@ -138,8 +188,7 @@ func (t *Tokenizer) readString(unquoted bool, default_quote rune) (*Token, error
 	// Add the final StringToken if there's remaining text
 	if value.Len() > 0 {
 		textString := t.input[currPosition:t.position]
-		currSpan.End.Line, currSpan.End.Col = t.line, t.column
-		token := NewStringToken(textString, value.String(), currSpan)
+		token := NewStringToken(textString, value.String(), t.spanFrom(currStart))
 		token.SetQuote(quote)
 		interpolationTokens = append(interpolationTokens, token)
 	}
@@ -151,34 +200,55 @@ func (t *Tokenizer) readString(unquoted bool, default_quote rune) (*Token, error
 	// Is this just a literal string?
 	if len(interpolationTokens) == 1 && interpolationTokens[0].Type == StringLiteralTokenType {
 		interpolationTokens[0].Text = text
+		interpolationTokens[0].Span = t.spanFrom(start)
 		return interpolationTokens[0], nil
 	}
 
+	// An empty string has no parts at all.
+	if len(interpolationTokens) == 0 {
+		token := NewStringToken(text, "", t.spanFrom(start))
+		token.SetQuote(quote)
+		return token, nil
+	}
+
 	// Combine into a StringInterpolationToken if interpolation occurred
-	compoundToken := NewInterpolatedStringToken(text, interpolationTokens, Span{Position{startLine, startCol}, Position{t.line, t.column}})
+	compoundToken := NewInterpolatedStringToken(text, interpolationTokens, t.spanFrom(start))
 	compoundToken.SetQuote(quote)
 	compoundToken.Type = InterpolatedStringTokenType
 	return compoundToken, nil
 }
 
+// isNestingQuote reports whether r opens a string nested within a string
+// that is closed by quote. Only guillemet strings nest, so that «a «b» c» is
+// a single string.
+func isNestingQuote(r, quote rune) bool {
+	return r == '«' && quote == '»'
+}
+
 // Helper to check if brackets match
 func matches(open, close rune) bool {
 	return (open == '(' && close == ')') || (open == '[' && close == ']') || (open == '{' && close == '}')
 }
 
 func (t *Tokenizer) readStringInterpolation() (*Token, error) {
-	span := Span{Position{t.line, t.column}, Position{-1, -1}}
+	start := t.here()
 	state := 0       // State 0: inside expression, State 1: inside string
 	var stack []rune // Pushdown stack
+	raw := false     // Whether the string of state 1 is a raw one
 
 	t.markPosition()                   // Mark the position for the interpolation
 	openingRune := t.consume()         // Consume the opening bracket
 	stack = append(stack, openingRune) // Push opening bracket onto stack
+	if err := t.enterInterpolation(start); err != nil {
+		return nil, err
+	}
+	defer t.leaveInterpolation()
 
 	for {
 		if !t.hasMoreInput() {
-			return nil, fmt.Errorf("unterminated interpolation, at line %d, Column: %d", span.Start.Line, span.Start.Col)
+			return nil, t.errorFrom(start, "unterminated interpolation")
 		}
+		beforeChar := t.here()
 		r := t.consume()
 		switch state {
 		case 0: // Inside expression
@@ -192,22 +262,27 @@ func (t *Tokenizer) readStringInterpolation() (*Token, error) {
 					stack = stack[:len(stack)-1] // Pop stack
 					if len(stack) == 0 {         // End of interpolation
 						text := t.popMark() // Pop the marked position
-						span.End.Line, span.End.Col = t.line, t.column
-						token := NewExpressionToken(text, span)
+						token := NewExpressionToken(text, t.spanFrom(start))
 						return token, nil
 					}
 				} else {
-					return nil, fmt.Errorf("mismatched bracket, at line %d, Column: %d", span.Start.Line, span.Start.Col)
+					return nil, t.errorFrom(start, "mismatched bracket")
+				}
+			case '\r', '\n': // Line breaks are not allowed
+				return nil, errorAt(Span{start, beforeChar}, "line break in interpolation")
+			default:
+				if data, ok := t.quoteData(r); ok { // Enter string state
+					stack = append(stack, getMatchingCloseQuote(r))
+					state = 1
+					raw = data.Raw
 				}
-			case '"', '\'', '`', '«': // Enter string state
-				stack = append(stack, getMatchingCloseQuote(r))
-				state = 1
-			case 'r', '\n': // Line breaks are not allowed
-				return nil, fmt.Errorf("line break in interpolation, at line %d, Column: %d", t.line, t.column)
 			}
 		case 1: // Inside string
 			switch r {
-			case '\\': // Escape sequence
+			case '\\': // Escape sequence, unless the string is raw
+				if raw {
+					break
+				}
 				if t.hasMoreInput() {
 					next, _ := t.peek()
 					if next == '(' || next == '[' || next == '{' {
@@ -219,11 +294,19 @@ func (t *Tokenizer) readStringInterpolation() (*Token, error) {
 						handleEscapeSequence(t)
 					}
 				} else {
-					return nil, fmt.Errorf("unterminated escape sequence, at line %d, Column: %d", span.Start.Line, span.Start.Col)
+					return nil, t.errorFrom(start, "unterminated escape sequence")
+				}
+			case '«': // A nested guillemet string
+				if stack[len(stack)-1] == '»' {
+					stack = append(stack, '»')
 				}
 			case stack[len(stack)-1]: // Matching closing quote
 				stack = stack[:len(stack)-1] // Pop stack
-				state = 0
+				// Closing a nested guillemet string leaves us inside the
+				// string that encloses it.
+				if stack[len(stack)-1] != '»' {
+					state = 0
+				}
 			}
 		}
 	}
@@ -232,7 +315,8 @@ func (t *Tokenizer) readStringInterpolation() (*Token, error) {
 // Helper method to process escape sequences
 func handleEscapeSequence(t *Tokenizer) string {
 	var value strings.Builder
-	r := t.consume() // Consume the escape character
+	start := t.here() // Just after the backslash
+	r := t.consume()  // Consume the escape character
 	switch r {
 	case 'b':
 		value.WriteRune('\b')
@@ -244,7 +328,7 @@ func handleEscapeSequence(t *Tokenizer) string {
 		value.WriteRune('\r')
 	case 't':
 		value.WriteRune('\t')
-	case '\\', '/', '"', '\'', '`', '»': // Escaped backslash, slash, or matching quote
+	case '\\', '/', '"', '\'', '`', '«', '»': // Escaped backslash, slash, or matching quote
 		value.WriteRune(r)
 	case 'u': // Unicode escape sequence
 		value.WriteString(t.readUnicodeEscape())
@@ -254,7 +338,11 @@ func handleEscapeSequence(t *Tokenizer) string {
 		// of characters, making it easier to read. 2. It can be used to introduce
 		// a non-standard identifier.
 	default:
-		value.WriteRune('\\') // Keep invalid escape sequences as-is
+		// Any quote of the rules may be escaped, as well as the usual ones.
+		if !t.isClosingQuote(r) {
+			value.WriteRune('\\') // Keep invalid escape sequences as-is
+			t.warn(Position{start.Line, start.Col - 1}, "\\"+string(r), "unknown escape sequence")
+		}
 		value.WriteRune(r)
 	}
 
@@ -265,12 +353,12 @@ func (t *Tokenizer) readUnicodeEscape() string {
 	var code strings.Builder
 	for range 4 {
 		if t.hasMoreInput() {
-			r, size := utf8.DecodeRuneInString(t.input[t.position:])
+			r, _ := utf8.DecodeRuneInString(t.input[t.position:])
 			if r == utf8.RuneError {
 				break // Handle invalid UTF-8
 			}
-			code.WriteRune(r)
-			t.position += size // Advance by the size of the rune
+			// Consuming keeps the line and column in step with the position.
+			code.WriteRune(t.consume())
 		} else {
 			break // Stop if there are fewer than 4 runes remaining
 		}
@@ -295,7 +383,7 @@ func decodeUnicodeEscape(code string) (rune, error) {
 
 func (t *Tokenizer) readMultilineString(rawFlag bool) (*Token, error) {
 	startPosition := t.position
-	startLine, startCol := t.line, t.column
+	start := t.here()
 	var subTokens []*Token
 
 	openingQuote, closingIndent, specifier, nlines, terr := t.findClosingIndent()
@@ -325,8 +413,11 @@ func (t *Tokenizer) readMultilineString(rawFlag bool) (*Token, error) {
 				}
 			}
 		} else {
-			tok = NewStringToken("", "", Span{Position{t.line, t.column}, Position{t.line, t.column}})
+			// findClosingIndent only lets an empty line through here, but
+			// its line break must still be consumed.
+			tok = NewStringToken("", "", t.spanFrom(t.here()))
 			tok.SetQuote(openingQuote)
+			t.readRestOfLine()
 		}
 		subTokens = append(subTokens, tok)
 	}
@@ -338,11 +429,19 @@ func (t *Tokenizer) readMultilineString(rawFlag bool) (*Token, error) {
 	if terr != nil {
 		return nil, terr // Return error if closing quotes are malformed
 	}
+	if terr := t.checkAfterClosingQuotes(closingQuote); terr != nil {
+		return nil, terr
+	}
 
 	originalText := t.input[startPosition:t.position]
 
-	// Add the multiline string token
-	token := NewMultiLineStringToken(originalText, "", Span{Position{startLine, startCol}, Position{t.line, t.column}})
+	// Add the multiline string token. Its value is left empty unless asked
+	// for, as consumers have always assembled it from the subtokens.
+	value := ""
+	if t.multilineValues {
+		value, _ = joinMultilineValue(subTokens)
+	}
+	token := NewMultiLineStringToken(originalText, value, t.spanFrom(start))
 	token.Specifier = &specifier
 	token.SetQuote(openingQuote)
 	token.Subtokens = subTokens
@@ -350,13 +449,46 @@ func (t *Tokenizer) readMultilineString(rawFlag bool) (*Token, error) {
 	return token, nil
 }
 
+// checkAfterClosingQuotes checks what directly follows the closing triple
+// quotes of a multi-line string. The rest of their line is tokenized as
+// usual, as in f(""" ... """), but another quote cannot start a string that
+// was meant, and a word run together with the quotes is probably a mistake.
+func (t *Tokenizer) checkAfterClosingQuotes(quote rune) error {
+	r, ok := t.peek()
+	switch {
+	case !ok:
+	case r == quote:
+		start := t.here()
+		return errorAt(Span{start, Position{start.Line, start.Col + t.columnPolicy.width(string(r))}},
+			"another %c after the closing triple quotes", r)
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		t.warn(t.here(), string(r), "text directly after the closing triple quotes")
+	}
+	return nil
+}
+
+// joinMultilineValue joins the values of the lines of a multi-line string,
+// which are already dedented and have had their escapes processed. A line
+// with interpolations has no value of its own, so then it reports false.
+func joinMultilineValue(lines []*Token) (string, bool) {
+	values := make([]string, len(lines))
+	for i, line := range lines {
+		if line.Type != StringLiteralTokenType || line.Value == nil {
+			return "", false
+		}
+		values[i] = *line.Value
+	}
+	return strings.Join(values, "\n"), true
+}
+
 func (t *Tokenizer) findClosingIndent() (rune, string, string, int, error) {
+	start := t.here()
 	t.markPosition()
 
 	// Validate and consume the opening triple quotes
 	opening_quote, ok := t.tryReadTripleOpeningQuotes()
 	if !ok {
-		return 0, "", "", 0, fmt.Errorf("malformed opening triple quotes at line %d, column %d", t.line, t.column)
+		return 0, "", "", 0, t.errorFrom(start, "malformed opening triple quotes")
 	}
 	closing_quote := getMatchingCloseQuote(opening_quote) // Get the matching closing quote
 
@@ -367,7 +499,7 @@ func (t *Tokenizer) findClosingIndent() (rune, string, string, int, error) {
 	}
 
 	// Now read each line in order until we find the closing line.
-	startLine, startCol := t.line, t.column
+	startLine := t.line
 	lines := []string{}
 	var match bool
 	var closingIndent string
@@ -381,7 +513,7 @@ func (t *Tokenizer) findClosingIndent() (rune, string, string, int, error) {
 	}
 
 	if !match {
-		return 0, "", "", 0, fmt.Errorf("closing triple quote not found at line %d, column %d", t.line, t.column)
+		return 0, "", "", 0, t.errorFrom(start, "closing triple quote not found")
 	}
 
 	for i, line := range lines {
@@ -391,12 +523,13 @@ func (t *Tokenizer) findClosingIndent() (rune, string, string, int, error) {
 		}
 		// Check if the line starts with the closing indent
 		if !strings.HasPrefix(line, closingIndent) {
-			return 0, "", "", 0, fmt.Errorf("not indented consistently with the closing triple quote at line %d, column %d", startLine+i, startCol)
+			lineSpan := Span{Position{startLine + i, 1}, Position{startLine + i, t.columnPolicy.width(line) + 1}}
+			return 0, "", "", 0, errorAt(lineSpan, "not indented consistently with the closing triple quote")
 		}
 	}
 
 	t.resetPosition()
-	return closing_quote, closingIndent, specifier, len(lines), nil
+	return opening_quote, closingIndent, specifier, len(lines), nil
 }
 
 func getMatchingCloseQuote(openingQuote rune) rune {
@@ -407,9 +540,19 @@ func getMatchingCloseQuote(openingQuote rune) rune {
 	return openingQuote // For other quotes, return the same character
 }
 
+func getMatchingOpenQuote(closingQuote rune) rune {
+	// Return the matching opening quote for the given closing quote
+	if closingQuote == '»' {
+		return '«'
+	}
+	return closingQuote // For other quotes, return the same character
+}
+
 // Method to read the specifier of a multi-line string / code-fence.
 func (t *Tokenizer) readSpecifier() (string, error) {
 	// Read all the characters until a newline or end of input.
+	start := t.here()
+	end := start
 	var text strings.Builder
 	for t.hasMoreInput() {
 		r := t.consume()
@@ -420,17 +563,19 @@ func (t *Tokenizer) readSpecifier() (string, error) {
 			break // End of line
 		}
 		text.WriteRune(r)
+		end = t.here()
 	}
+	specifierSpan := Span{start, end}
 	strtext := strings.TrimSpace(text.String())
 	if strings.Contains(strtext, " ") {
-		return "", fmt.Errorf("spaces inside code-fence specifier at line %d, column %d", t.line, t.column)
+		return "", errorAt(specifierSpan, "spaces inside code-fence specifier")
 	}
 	//  Check the specifier matches the regex ^\w*$. This reserves wriggle room
 	//  for future expansion.
 	if len(strtext) > 0 {
 		m, e := regexp.MatchString(`^[a-zA-Z_]\w*$`, strtext)
 		if !m || e != nil {
-			return "", fmt.Errorf("invalid code-fence specifier at line %d, column %d", t.line, t.column)
+			return "", errorAt(specifierSpan, "invalid code-fence specifier")
 		}
 	}
 	return strtext, nil
@@ -438,20 +583,27 @@ func (t *Tokenizer) readSpecifier() (string, error) {
 
 func (t *Tokenizer) readRawString(unquoted bool, default_quote rune) (*Token, error) {
 	startPosition := t.position
-	startLine, startCol := t.line, t.column
+	start := t.here()
 	quote := default_quote
 	if !unquoted {
 		quote = getMatchingCloseQuote(t.consume()) // Consume the opening quote
 	}
 	var text strings.Builder
+	depth := 0 // The number of nested guillemet strings that are open
 
 	for {
 		if !t.hasMoreInput() {
-			return nil, fmt.Errorf("unterminated raw string at line %d, column %d", startLine, startCol)
+			return nil, t.errorFrom(start, "unterminated raw string")
 		}
+		beforeChar := t.here()
 		r := t.consume()
-		if r == quote { // Closing quote found
-			break
+		if !unquoted && r == quote { // Closing quote found
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if !unquoted && isNestingQuote(r, quote) {
+			depth++
 		} else if r == '\n' || r == '\r' { // Handle newlines
 			if unquoted {
 				if r == '\r' {
@@ -459,7 +611,7 @@ func (t *Tokenizer) readRawString(unquoted bool, default_quote rune) (*Token, er
 				}
 				break
 			}
-			return nil, fmt.Errorf("line break in raw string at line %d, column %d", startLine, startCol)
+			return nil, errorAt(Span{start, beforeChar}, "line break in raw string")
 		}
 		// Backslashes are treated as normal characters in raw strings
 		text.WriteRune(r)
@@ -467,7 +619,7 @@ func (t *Tokenizer) readRawString(unquoted bool, default_quote rune) (*Token, er
 
 	// Add the raw string token
 	originalText := t.input[startPosition:t.position]
-	token := NewStringToken(originalText, text.String(), Span{Position{startLine, startCol}, Position{t.line, t.column}})
+	token := NewStringToken(originalText, text.String(), t.spanFrom(start))
 	token.SetQuote(quote)
 	return token, nil
 }
@@ -0,0 +1,62 @@
+package tokenizer
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// checkNumberRange warns about a numeric literal that an integer of the
+// maximum width could not hold, or whose exponent is beyond the limit, so
+// that overflow is reported at the literal rather than by a later stage.
+func (t *Tokenizer) checkNumberRange(token *Token) {
+	// A number with an exponent has an exponent base, even if it is e0.
+	if t.maxIntegerBits > 0 && token.Fraction == nil && token.ExpBase == nil {
+		magnitude := numberMagnitude(token)
+		if bits := magnitude.BitLen(); bits > t.maxIntegerBits {
+			t.warn(token.Span.Start, token.Text, fmt.Sprintf("integer literal %s needs %d bits, more than %d",
+				abbreviateDigits(magnitude.String()), bits, t.maxIntegerBits))
+		}
+	}
+	if t.maxExponent > 0 && token.Exponent != nil {
+		if exponent := *token.Exponent; exponent > t.maxExponent || -exponent > t.maxExponent {
+			expBase := 10
+			if token.ExpBase != nil {
+				expBase = *token.ExpBase
+			}
+			t.warn(token.Span.Start, token.Text, fmt.Sprintf("exponent of %d^%d is beyond the limit of %d",
+				expBase, exponent, t.maxExponent))
+		}
+	}
+}
+
+// numberMagnitude returns the absolute value of the mantissa of a valid
+// numeric token.
+func numberMagnitude(token *Token) *big.Int {
+	if token.Balanced != nil && *token.Balanced {
+		value := new(big.Int)
+		three := big.NewInt(3)
+		for _, digit := range *token.Mantissa {
+			value.Mul(value, three)
+			switch digit {
+			case '1':
+				value.Add(value, big.NewInt(1))
+			case 'T':
+				value.Sub(value, big.NewInt(1))
+			}
+		}
+		return value.Abs(value)
+	}
+	// The digits have been checked against the base already.
+	value, _ := new(big.Int).SetString(*token.Mantissa, *token.Base)
+	return value
+}
+
+// abbreviateDigits shortens a long decimal number for a message, keeping its
+// leading digits and saying how many there are.
+func abbreviateDigits(digits string) string {
+	const keep = 20
+	if len(digits) <= 2*keep {
+		return digits
+	}
+	return fmt.Sprintf("%s... (%d digits)", digits[:keep], len(digits))
+}
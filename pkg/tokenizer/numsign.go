@@ -0,0 +1,83 @@
+package tokenizer
+
+import (
+	"fmt"
+	"slices"
+)
+
+// NumericSign says what to do with a + or - that is directly in front of a
+// number and in prefix position, as in -1 or f(-1) but not x-1.
+type NumericSign int
+
+const (
+	NumericSignSeparate NumericSign = iota // The sign is an operator token like any other
+	NumericSignFold                        // The sign is part of the number's token and recorded in its sign field
+	NumericSignFlag                        // The sign stays an operator token, and is recorded in the number's sign field
+)
+
+// numericSignNames are the names of the numeric sign policies, indexed by
+// policy.
+var numericSignNames = []string{"separate", "fold", "flag"}
+
+// String returns the name of the numeric sign policy.
+func (s NumericSign) String() string {
+	if s >= 0 && int(s) < len(numericSignNames) {
+		return numericSignNames[s]
+	}
+	return fmt.Sprintf("NumericSign(%d)", int(s))
+}
+
+// ParseNumericSign returns the numeric sign policy with the given name:
+// separate, fold or flag.
+func ParseNumericSign(name string) (NumericSign, error) {
+	if i := slices.Index(numericSignNames, name); i >= 0 {
+		return NumericSign(i), nil
+	}
+	return NumericSignSeparate, fmt.Errorf("unknown numeric sign '%s' (expected separate, fold or flag)", name)
+}
+
+// applyNumericSign records the sign in front of the number, if there is one
+// in prefix position, and in fold mode replaces the sign's token with the
+// number, which must not have been added yet.
+func (t *Tokenizer) applyNumericSign(number *Token) {
+	n := len(t.tokens)
+	if n == 0 {
+		return
+	}
+	sign := t.tokens[n-1]
+	if sign.Type != OperatorTokenType || (sign.Text != "-" && sign.Text != "+") ||
+		sign.Span.End != number.Span.Start || sign.ExpandedFrom != nil {
+		return
+	}
+	if !inPrefixPosition(t.tokens[:n-1]) {
+		return
+	}
+	text := sign.Text
+	number.Sign = &text
+	if t.numericSign != NumericSignFold {
+		return
+	}
+	number.Text = sign.Text + number.Text
+	number.Span.Start = sign.Span.Start
+	number.LnBefore = sign.LnBefore
+	number.Doc = sign.Doc
+	number.File = sign.File
+	t.tokens[n-1] = nil
+	t.tokens = t.tokens[:n-1]
+}
+
+// inPrefixPosition reports whether an operator after the tokens would be a
+// prefix operator: whether there are no tokens before it, apart from trivia,
+// or the last of them cannot end an operand.
+func inPrefixPosition(tokens []*Token) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch tokens[i].Type {
+		case WhitespaceTokenType, CommentTokenType:
+			continue
+		case OperatorTokenType, OpenDelimiterTokenType, MarkTokenType, StartTokenType, BridgeTokenType, PrefixTokenType:
+			return true
+		}
+		return false
+	}
+	return true
+}
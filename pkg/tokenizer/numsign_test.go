@@ -0,0 +1,58 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumericSign(t *testing.T) {
+	summarize := func(tokens []*Token) []string {
+		var summary []string
+		for _, token := range tokens {
+			s := string(token.Type) + " " + token.Text
+			if token.Sign != nil {
+				s += " sign=" + *token.Sign
+			}
+			summary = append(summary, s)
+		}
+		return summary
+	}
+	input := "-1 + f(+2, x-3, - 4) * -0x1F"
+	tests := []struct {
+		sign     NumericSign
+		expected []string
+	}{
+		{NumericSignSeparate, []string{"O -", "n 1", "O +", "V f", "[ (", "O +", "n 2", "M ,", "V x", "O -", "n 3", "M ,", "O -", "n 4", "] )", "O *", "O -", "n 0x1F"}},
+		{NumericSignFold, []string{"n -1 sign=-", "O +", "V f", "[ (", "n +2 sign=+", "M ,", "V x", "O -", "n 3", "M ,", "O -", "n 4", "] )", "O *", "n -0x1F sign=-"}},
+		{NumericSignFlag, []string{"O -", "n 1 sign=-", "O +", "V f", "[ (", "O +", "n 2 sign=+", "M ,", "V x", "O -", "n 3", "M ,", "O -", "n 4", "] )", "O *", "O -", "n 0x1F sign=-"}},
+	}
+	for _, test := range tests {
+		tokens, err := New(input, &Options{NumericSign: test.sign}).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := summarize(tokens); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("With %s expected %v, got %v", test.sign, test.expected, got)
+		}
+	}
+
+	// A folded number takes the span, newline flag and doc of its sign.
+	tokens, err := New("x;\n#### Doc\n-5", &Options{NumericSign: NumericSignFold}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	number := tokens[len(tokens)-1]
+	if number.Span != (Span{Position{3, 1}, Position{3, 3}}) || number.LnBefore == nil || number.Doc == nil || *number.Doc != "Doc" {
+		t.Errorf("Expected the folded number to take over its sign, got %+v", number)
+	}
+
+	for _, name := range []string{"separate", "fold", "flag"} {
+		sign, err := ParseNumericSign(name)
+		if err != nil || sign.String() != name {
+			t.Errorf("Expected %s to parse, got %v, %v", name, sign, err)
+		}
+	}
+	if _, err := ParseNumericSign("merge"); err == nil {
+		t.Errorf("Expected an error for an unknown numeric sign")
+	}
+}
@@ -0,0 +1,234 @@
+package tokenizer
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// valuePrecision is the precision, in bits, of the values compared by the
+// numeric property test. The literals generated need far fewer, so the two
+// evaluations agree to well within the tolerance.
+const valuePrecision = 512
+
+// numberLiteral is a numeric literal made by the property test, with the value
+// of each digit as generated, so that its value can be found without reading
+// its text.
+type numberLiteral struct {
+	text     string
+	radix    string
+	base     int
+	balanced bool
+	mantissa []int // The values of the digits before the point
+	fraction []int // The values of the digits after it, or nil for none
+	expBase  int   // What the exponent scales by, or 0 for no exponent
+	exponent int
+}
+
+// randomDigits returns between 1 and 8 digit values of the base, from -1 to 1
+// for balanced ternary.
+func randomDigits(r *rand.Rand, base int, balanced bool) []int {
+	digits := make([]int, 1+r.IntN(8))
+	for i := range digits {
+		if balanced {
+			digits[i] = r.IntN(3) - 1
+		} else {
+			digits[i] = r.IntN(base)
+		}
+	}
+	return digits
+}
+
+// writeDigits writes digit values as the digits of a literal, sometimes with
+// single underscores between them.
+func writeDigits(r *rand.Rand, digits []int) string {
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && r.IntN(4) == 0 {
+			b.WriteByte('_')
+		}
+		switch {
+		case digit < 0:
+			b.WriteByte('T')
+		case digit < 10:
+			b.WriteByte(byte('0' + digit))
+		default:
+			b.WriteByte(byte('A' + digit - 10))
+		}
+	}
+	return b.String()
+}
+
+// randomNumberLiteral makes a valid numeric literal in one of the notations:
+// decimal, 0x, 0o, 0b, a radix from 2r to 36r, or balanced ternary, with an
+// optional fraction and exponent and perhaps underscores.
+func randomNumberLiteral(r *rand.Rand) numberLiteral {
+	var n numberLiteral
+	switch r.IntN(6) {
+	case 0:
+		n.radix, n.base = "", 10
+	case 1:
+		n.radix, n.base = "0x", 16
+	case 2:
+		n.radix, n.base = "0o", 8
+	case 3:
+		n.radix, n.base = "0b", 2
+	case 4:
+		n.base = 2 + r.IntN(35)
+		n.radix = strconv.Itoa(n.base) + "r"
+	default:
+		n.radix, n.base, n.balanced = "0t", 3, true
+	}
+	n.mantissa = randomDigits(r, n.base, n.balanced)
+	text := n.radix + writeDigits(r, n.mantissa)
+	if r.IntN(2) == 0 {
+		n.fraction = randomDigits(r, n.base, n.balanced)
+		text += "." + writeDigits(r, n.fraction)
+	}
+	if r.IntN(2) == 0 {
+		marker := "e"
+		n.expBase = 10
+		if n.base == 16 && r.IntN(2) == 0 {
+			marker = "p"
+			n.expBase = 2
+		}
+		n.exponent = r.IntN(41) - 20
+		sign := ""
+		if n.exponent >= 0 && r.IntN(2) == 0 {
+			sign = "+"
+		}
+		text += marker + sign + strconv.Itoa(n.exponent)
+	}
+	n.text = text
+	return n
+}
+
+// value evaluates the literal from the digit values it was made from.
+func (n numberLiteral) value() *big.Float {
+	base := new(big.Float).SetPrec(valuePrecision).SetInt64(int64(n.base))
+	value := new(big.Float).SetPrec(valuePrecision)
+	for _, digit := range n.mantissa {
+		value.Mul(value, base)
+		value.Add(value, big.NewFloat(float64(digit)))
+	}
+	scale := new(big.Float).SetPrec(valuePrecision).SetInt64(1)
+	for _, digit := range n.fraction {
+		scale.Quo(scale, base)
+		value.Add(value, new(big.Float).SetPrec(valuePrecision).Mul(scale, big.NewFloat(float64(digit))))
+	}
+	if n.expBase != 0 {
+		value.Mul(value, floatPower(n.expBase, n.exponent))
+	}
+	return value
+}
+
+// floatPower returns base to the power of exponent.
+func floatPower(base, exponent int) *big.Float {
+	power := new(big.Int).Exp(big.NewInt(int64(base)), big.NewInt(int64(max(exponent, -exponent))), nil)
+	result := new(big.Float).SetPrec(valuePrecision).SetInt(power)
+	if exponent < 0 {
+		result.Quo(new(big.Float).SetPrec(valuePrecision).SetInt64(1), result)
+	}
+	return result
+}
+
+// tokenValue evaluates a numeric token from its fields alone, as a parser
+// would, returning false if a field it needs is missing or has a digit that
+// is not one of its base.
+func tokenValue(token *Token) (*big.Float, bool) {
+	if token.Base == nil || token.Mantissa == nil {
+		return nil, false
+	}
+	balanced := token.Balanced != nil && *token.Balanced
+	digits := func(text string) (*big.Rat, bool) {
+		value := new(big.Rat)
+		base := big.NewRat(int64(*token.Base), 1)
+		for _, c := range text {
+			var digit int
+			switch {
+			case balanced && c == 'T':
+				digit = -1
+			case '0' <= c && c <= '9':
+				digit = int(c - '0')
+			case 'A' <= c && c <= 'Z' && !balanced:
+				digit = int(c-'A') + 10
+			default:
+				return nil, false
+			}
+			if digit >= *token.Base || (balanced && digit > 1) {
+				return nil, false
+			}
+			value.Mul(value, base)
+			value.Add(value, big.NewRat(int64(digit), 1))
+		}
+		return value, true
+	}
+
+	value, ok := digits(*token.Mantissa)
+	if !ok {
+		return nil, false
+	}
+	if token.Fraction != nil {
+		fraction, ok := digits(*token.Fraction)
+		if !ok {
+			return nil, false
+		}
+		scale := new(big.Int).Exp(big.NewInt(int64(*token.Base)), big.NewInt(int64(len(*token.Fraction))), nil)
+		value.Add(value, fraction.Quo(fraction, new(big.Rat).SetInt(scale)))
+	}
+	result := new(big.Float).SetPrec(valuePrecision).SetRat(value)
+	if token.Exponent != nil {
+		if token.ExpBase == nil {
+			return nil, false
+		}
+		result.Mul(result, floatPower(*token.ExpBase, *token.Exponent))
+	}
+	return result, true
+}
+
+// closeEnough reports whether two values agree to within the rounding of
+// their evaluations.
+func closeEnough(a, b *big.Float) bool {
+	difference := new(big.Float).SetPrec(valuePrecision).Sub(a, b)
+	difference.Abs(difference)
+	bound := new(big.Float).SetPrec(valuePrecision).Abs(a)
+	bound.SetMantExp(bound, -(valuePrecision - 16))
+	// A tiny absolute bound covers values of zero.
+	tiny := new(big.Float).SetMantExp(big.NewFloat(1), -(valuePrecision - 16))
+	return difference.Cmp(bound) <= 0 || difference.Cmp(tiny) <= 0
+}
+
+func TestNumberLiteralValues(t *testing.T) {
+	count := 2000
+	if testing.Short() {
+		count = 200
+	}
+	r := rand.New(rand.NewPCG(7, 11))
+	for range count {
+		literal := randomNumberLiteral(r)
+		tokens, err := New(literal.text, nil).Tokenize()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", literal.text, err)
+		}
+		if len(tokens) != 1 || tokens[0].Type != NumericLiteralTokenType || tokens[0].Text != literal.text {
+			t.Fatalf("%s: expected a single number, got %v", literal.text, tokenTexts(tokens))
+		}
+		token := tokens[0]
+		if token.Radix == nil || *token.Radix != literal.radix || *token.Base != literal.base {
+			t.Errorf("%s: expected the radix %q and base %d, got %v and %v", literal.text, literal.radix, literal.base, token.Radix, token.Base)
+		}
+		if literal.balanced != (token.Balanced != nil && *token.Balanced) {
+			t.Errorf("%s: expected balanced to be %v", literal.text, literal.balanced)
+		}
+		got, ok := tokenValue(token)
+		if !ok {
+			t.Errorf("%s: could not evaluate the fields of %+v", literal.text, token)
+			continue
+		}
+		if want := literal.value(); !closeEnough(got, want) {
+			t.Errorf("%s: the fields give %s, expected %s", literal.text, got.Text('g', 30), want.Text('g', 30))
+		}
+	}
+}
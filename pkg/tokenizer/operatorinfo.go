@@ -0,0 +1,33 @@
+package tokenizer
+
+import "fmt"
+
+// OperatorInfo is the effective precedence of an operator, for checking what
+// a rules file has done to it.
+type OperatorInfo struct {
+	Text       string `json:"text"`
+	Precedence [3]int `json:"precedence"` // [prefix, infix, postfix]
+	Origin     string `json:"origin"`     // OriginDefault or OriginCustom
+}
+
+// ExplainOperator returns the effective precedence of the operator text under
+// the rules, or under the default rules if rules is nil. It is an error if
+// the text is not an operator, which names the kind of rule that it is if it
+// is classified by another one.
+func ExplainOperator(rules *TokenizerRules, text string) (*OperatorInfo, error) {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	entry, ok := rules.TokenLookup[text]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("'%s' is not an operator", text)
+	case entry.Type != CustomOperator:
+		return nil, fmt.Errorf("'%s' is not an operator; its rule type is %s", text, entry.Type)
+	}
+	info := &OperatorInfo{Text: text, Precedence: entry.Data.([3]int), Origin: OriginDefault}
+	if entry.Custom {
+		info.Origin = OriginCustom
+	}
+	return info, nil
+}
@@ -0,0 +1,53 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainOperator(t *testing.T) {
+	info, err := ExplainOperator(nil, "+")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *info != (OperatorInfo{"+", [3]int{80, 2080, 0}, OriginDefault}) {
+		t.Errorf("Unexpected default +: %+v", *info)
+	}
+
+	rules, err := ApplyRulesToDefaults(&RulesFile{Operator: []OperatorRule{{Text: "+", Precedence: &[3]int{0, 500, 0}}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	info, err = ExplainOperator(rules, "+")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *info != (OperatorInfo{"+", [3]int{0, 500, 0}, OriginCustom}) {
+		t.Errorf("Unexpected overridden +: %+v", *info)
+	}
+
+	for text, reason := range map[string]string{"**": "is not an operator", "=>>": "its rule type is bridge"} {
+		if _, err := ExplainOperator(rules, text); err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("%q: expected an error %q, got %v", text, reason, err)
+		}
+	}
+}
+
+func TestOperatorRuleValidation(t *testing.T) {
+	tests := []struct {
+		rule   OperatorRule
+		reason string
+	}{
+		{OperatorRule{Text: "", Precedence: &[3]int{0, 100, 0}}, "operator rule with no text"},
+		{OperatorRule{Text: "**", Precedence: &[3]int{0, -1, 0}}, "operator '**' has a negative precedence"},
+		{OperatorRule{Text: "**", Precedence: &[3]int{}}, "operator '**' has no prefix, infix or postfix precedence"},
+		{OperatorRule{Text: "@@"}, "operator '@@' has no precedence, and no base precedence to calculate one from"},
+		{OperatorRule{Text: "=>>", Precedence: &[3]int{0, 100, 0}}, "token '=>>' is defined in both bridge and operator rules"},
+	}
+	for _, test := range tests {
+		_, err := ApplyRulesToDefaults(&RulesFile{Operator: []OperatorRule{test.rule}})
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%+v: expected an error %q, got %v", test.rule, test.reason, err)
+		}
+	}
+}
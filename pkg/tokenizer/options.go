@@ -0,0 +1,151 @@
+package tokenizer
+
+import (
+	"io"
+	"log/slog"
+	"unsafe"
+)
+
+// Options configures a Tokenizer created by New. The zero value selects the
+// default rules with no optional behaviour, so new options can be added
+// without changing existing callers.
+type Options struct {
+	Rules           *TokenizerRules // Rules to tokenize with, or nil for DefaultRules(); frozen by New
+	AnnotateContext bool            // Attach the enclosing start tokens to each token
+	Limits          Limits          // Resource limits, where zero fields are unlimited
+	Trace           io.Writer       // Destination for a trace of the tokenizer's decisions, or nil
+	Logger          *slog.Logger    // Receiver of warnings about dubious input, or nil
+	DocMarker       string          // Prefix of doc comments, or "" for DefaultDocMarker; see CheckDocMarker
+	Lossless        bool            // Keep whitespace and comments as trivia tokens
+	Filename        string          // Name of the input, which ###line directives may refer to
+	BridgeCheck     BridgeCheck     // Whether bridge tokens are checked against their in lists
+	MultiLineValues bool            // Give multi-line strings the joined value of their lines
+	OnFence         FenceFunc       // Tokenizes the bodies of multi-line strings with a specifier, or nil
+	Strict          bool            // Stop with an error at the first warning
+	OnProgress      ProgressFunc    // Told of the bytes tokenized so far, for long inputs, or nil
+	MaxLineLength   int             // Warn about lines longer than this many characters, unless 0
+	NumericSign     NumericSign     // What to do with a sign in front of a number
+	MaxIntegerBits  int             // Warn about integer literals needing more bits than this, unless 0
+	MaxExponent     int             // Warn about exponents larger than this in size, unless 0
+
+	// AnnotateRuleSource records in each token classified by a rule which
+	// kind of rule it was and whether it came from the defaults or a rules
+	// file.
+	AnnotateRuleSource bool
+
+	// WarnAmbiguousWildcards logs a warning when a wildcard could stand for
+	// more than one expected label. It stands for the first of them.
+	WarnAmbiguousWildcards bool
+
+	// WarnTrailingWhitespace warns about spaces and tabs at the end of a
+	// line, outside strings and comments.
+	WarnTrailingWhitespace bool
+
+	// LineContinuation lets a backslash at the end of a line continue a
+	// single-line string on the next line, leaving the backslash, the line
+	// break and the next line's indentation out of its value.
+	LineContinuation bool
+
+	// QualifiedSeparator, if not "", joins names separated by it, such as
+	// foo::bar::baz for "::", into a single variable token that lists the
+	// names in its parts.
+	QualifiedSeparator string
+
+	// KeywordArguments reads a name followed directly by a wildcard, such
+	// as x: in f(x: 1), as a single keyword argument token when it is the
+	// first thing within parentheses or follows a comma there.
+	KeywordArguments bool
+
+	// ColumnPolicy says what the columns of positions count: characters,
+	// which is the default, bytes or UTF-16 code units.
+	ColumnPolicy ColumnPolicy
+
+	// KeepGoing goes on tokenizing after an exception token rather than
+	// stopping there. A single-line string with no closing quote becomes an
+	// exception token covering the rest of its line. The errors are
+	// diagnostics, and the first is returned once tokenizing is finished.
+	KeepGoing bool
+
+	// EndOfFileToken adds a token of type $ with no text at the end of the
+	// input, listing the start tokens and brackets still open, for parsers
+	// that expect one.
+	EndOfFileToken bool
+
+	// Interner, if not nil, gives the texts of the tokens a single shared
+	// copy each and sets their TextIDs. Interning is done when the tokens
+	// are returned, after any transforms.
+	Interner *Interner
+}
+
+// NewBytes creates a tokenizer for the input like New, but without copying
+// it into a string, which for a large input would double the memory needed.
+// The texts of the tokens share the input's memory, so it must not be changed
+// while the tokenizer or any of its tokens are in use.
+func NewBytes(input []byte, opts *Options) *Tokenizer {
+	return New(unsafe.String(unsafe.SliceData(input), len(input)), opts)
+}
+
+// TokenizeBytes tokenizes the input, configured by opts, without copying it.
+// As with NewBytes, the input must not be changed while the tokens are in
+// use.
+func TokenizeBytes(input []byte, opts *Options) ([]*Token, error) {
+	return NewBytes(input, opts).Tokenize()
+}
+
+// New creates a tokenizer for the input configured by opts. A nil opts is the
+// same as the zero Options. Tokenizing with invalid options, such as a doc
+// marker rejected by CheckDocMarker, returns the error before reading any
+// input.
+func New(input string, opts *Options) *Tokenizer {
+	if opts == nil {
+		opts = &Options{}
+	}
+	rules := opts.Rules
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	rules.Freeze()
+	docMarker := opts.DocMarker
+	if docMarker == "" {
+		docMarker = DefaultDocMarker
+	}
+	// New cannot return an error, so a bad option is kept and reported when
+	// tokenizing starts.
+	optionsErr := CheckDocMarker(docMarker)
+	return &Tokenizer{
+		input:           input,
+		line:            1,
+		column:          1,
+		tokens:          make([]*Token, 0),
+		expectingStack:  make([]expectingFrame, 0),
+		rules:           rules,
+		annotateContext: opts.AnnotateContext,
+		limits:          opts.Limits,
+		traceWriter:     opts.Trace,
+		logger:          opts.Logger,
+		matchers:        builtinMatchers,
+		docMarker:       docMarker,
+		optionsErr:      optionsErr,
+		lossless:        opts.Lossless,
+		filename:        opts.Filename,
+		bridgeCheck:     opts.BridgeCheck,
+		warnAmbiguous:   opts.WarnAmbiguousWildcards,
+		multilineValues: opts.MultiLineValues,
+		onFence:         opts.OnFence,
+		strict:          opts.Strict,
+		onProgress:      opts.OnProgress,
+		maxLineLength:   opts.MaxLineLength,
+		warnTrailing:    opts.WarnTrailingWhitespace,
+		annotateSource:  opts.AnnotateRuleSource,
+		numericSign:     opts.NumericSign,
+		maxIntegerBits:  opts.MaxIntegerBits,
+		maxExponent:     opts.MaxExponent,
+		continueLines:   opts.LineContinuation,
+		qualifiedSep:    opts.QualifiedSeparator,
+		keywordArgs:     opts.KeywordArguments,
+		columnPolicy:    opts.ColumnPolicy,
+		keepGoing:       opts.KeepGoing,
+		endOfFile:       opts.EndOfFileToken,
+		interner:        opts.Interner,
+	}
+}
@@ -0,0 +1,32 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestTokenizeBytes(t *testing.T) {
+	input := []byte("def f(x) =>> x + 1 enddef")
+	tokens, err := TokenizeBytes(input, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected, err := New(string(input), nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected the same tokens as for a string, got %v", tokenTexts(tokens))
+	}
+
+	// The texts of the tokens are in the input rather than a copy of it.
+	text := unsafe.StringData(tokens[1].Text)
+	if text != &input[4] {
+		t.Errorf("Expected the token text to share the input's memory")
+	}
+
+	if tokens, err := TokenizeBytes(nil, nil); err != nil || len(tokens) != 0 {
+		t.Errorf("Expected no tokens for no input, got %v (%v)", tokens, err)
+	}
+}
@@ -0,0 +1,101 @@
+package tokenizer
+
+import "sort"
+
+// MatchBrackets pairs up the openers and closers in the tokens, that is the
+// open and close brackets and the start and end tokens, setting the Pair
+// field of each to the index of the other. A closer that does not close the
+// innermost open opener closes the nearest enclosing one that it can, and
+// the openers in between are left unpaired; a closer that cannot close any
+// open opener is left unpaired.
+func MatchBrackets(tokens []*Token) {
+	matchPairs(tokens, func(i, j int) {
+		tokens[i].Pair = &j
+		tokens[j].Pair = &i
+	})
+}
+
+// matchPairs finds the pairs of openers and closers in the tokens, as
+// described for MatchBrackets, and calls found with the index of the opener
+// and the closer of each pair. Pairs are found in the order of their closers.
+func matchPairs(tokens []*Token, found func(opener, closer int)) {
+	var open []int // Indexes of the openers not yet closed
+	for i, token := range tokens {
+		switch {
+		case IsOpener(token):
+			open = append(open, i)
+		case IsCloser(token):
+			for j := len(open) - 1; j >= 0; j-- {
+				if MatchesClosedBy(tokens[open[j]], token) {
+					found(open[j], i)
+					open = open[:j]
+					break
+				}
+			}
+		}
+	}
+}
+
+// FoldingRanges returns the regions of the source that an editor can fold:
+// those from an opener to its matching closer, as paired by MatchBrackets,
+// that span more than one line. The regions are sorted by where they start,
+// so that enclosing regions come before those they contain.
+func FoldingRanges(tokens []*Token) []Span {
+	var ranges []Span
+	matchPairs(tokens, func(opener, closer int) {
+		start, end := tokens[opener].Span.Start, tokens[closer].Span.End
+		if end.Line > start.Line {
+			ranges = append(ranges, Span{Start: start, End: end})
+		}
+	})
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Start.Before(ranges[j].Start)
+	})
+	return ranges
+}
+
+// OutlineNode is a block of the source from a start token to its matching
+// end token, such as a def or an if, with the blocks directly within it.
+type OutlineNode struct {
+	Kind     string         `json:"kind"` // The start token, or what a wildcard stands for
+	Span     Span           `json:"span"`
+	Children []*OutlineNode `json:"children,omitempty"`
+}
+
+// Outline returns the blocks of the tokens as a tree, giving the structure of
+// the source without a parser. The blocks are those from a start token to
+// its matching end token, as paired by MatchBrackets, and are in source
+// order at each level. A start token that is never closed has no block.
+func Outline(tokens []*Token) []*OutlineNode {
+	closers := make(map[int]int)
+	matchPairs(tokens, func(opener, closer int) {
+		if tokens[opener].Type == StartTokenType {
+			closers[opener] = closer
+		}
+	})
+
+	var roots []*OutlineNode
+	type openBlock struct {
+		node   *OutlineNode
+		closer int
+	}
+	var stack []openBlock
+	for i, token := range tokens {
+		for len(stack) > 0 && stack[len(stack)-1].closer < i {
+			stack = stack[:len(stack)-1]
+		}
+		closer, ok := closers[i]
+		if !ok {
+			continue
+		}
+		node := &OutlineNode{Kind: token.CanonicalText(), Span: Span{Start: token.Span.Start, End: tokens[closer].Span.End}}
+		if len(stack) == 0 {
+			roots = append(roots, node)
+		} else {
+			parent := stack[len(stack)-1].node
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, openBlock{node, closer})
+	}
+	return roots
+}
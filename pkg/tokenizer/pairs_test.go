@@ -0,0 +1,94 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMatchBrackets(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[int]int // Pair by token index
+	}{
+		{
+			name:     "Nested",
+			input:    "f(a[1], {b})",
+			expected: map[int]int{1: 10, 3: 5, 5: 3, 7: 9, 9: 7, 10: 1},
+		},
+		{
+			name:     "Start and end tokens",
+			input:    "if x then (y) endif",
+			expected: map[int]int{0: 6, 3: 5, 5: 3, 6: 0},
+		},
+		{
+			name:     "Unclosed opener is skipped",
+			input:    "( [ )",
+			expected: map[int]int{0: 2, 2: 0},
+		},
+		{
+			name:     "Stray closer",
+			input:    "x ) (y)",
+			expected: map[int]int{2: 4, 4: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, _ := New(tt.input, nil).Tokenize()
+			MatchBrackets(tokens)
+			for i, token := range tokens {
+				want, paired := tt.expected[i]
+				switch {
+				case !paired && token.Pair != nil:
+					t.Errorf("token %d %q: expected no pair, got %d", i, token.Text, *token.Pair)
+				case paired && token.Pair == nil:
+					t.Errorf("token %d %q: expected pair %d, got none", i, token.Text, want)
+				case paired && *token.Pair != want:
+					t.Errorf("token %d %q: expected pair %d, got %d", i, token.Text, want, *token.Pair)
+				}
+			}
+		})
+	}
+}
+
+func TestFoldingRanges(t *testing.T) {
+	input := "def f(x)\n  if x then\n    [1,\n     2]\n  endif\nend\ng(1)"
+	tokens, err := New(input, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Span{
+		{Position{1, 1}, Position{6, 4}},
+		{Position{2, 3}, Position{5, 8}},
+		{Position{3, 5}, Position{4, 8}},
+	}
+	ranges := FoldingRanges(tokens)
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected %d ranges, got %v", len(expected), ranges)
+	}
+	for i, want := range expected {
+		if ranges[i] != want {
+			t.Errorf("range %d: expected %v, got %v", i, want, ranges[i])
+		}
+	}
+}
+
+func TestOutline(t *testing.T) {
+	input := "def f(x) =>>\n  if x then\n    for i in x do i endfor\n  endif\n  if y then [z] endif\nend\nif open then"
+	tokens, err := New(input, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	outline := Outline(tokens)
+	jsonBytes, err := json.Marshal(outline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The brackets are not blocks, and the unclosed if has none.
+	expected := `[{"kind":"def","span":[1,1,6,4],"children":[` +
+		`{"kind":"if","span":[2,3,4,8],"children":[{"kind":"for","span":[3,5,3,27]}]},` +
+		`{"kind":"if","span":[5,3,5,22]}]}]`
+	if string(jsonBytes) != expected {
+		t.Errorf("expected %s, got %s", expected, jsonBytes)
+	}
+}
@@ -0,0 +1,97 @@
+package tokenizer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pool keeps tokenizers that share a set of options so that their internal
+// buffers can be reused across inputs. It is safe for concurrent use.
+type Pool struct {
+	options Options
+	pool    sync.Pool
+
+	// Counters for Stats, updated by TokenizeValues.
+	inputs, bytes, tokens, errors, nanos atomic.Int64
+}
+
+// PoolStats reports the work done by a pool's TokenizeValues since the pool
+// was created, for an application to export as metrics.
+type PoolStats struct {
+	Inputs   int64         // The number of inputs tokenized
+	Bytes    int64         // The total size of the inputs
+	Tokens   int64         // The number of tokens returned
+	Errors   int64         // The number of inputs that failed to tokenize
+	Duration time.Duration // The total time spent tokenizing
+}
+
+// TokensPerSecond returns the average rate at which tokens were produced, or
+// 0 if no time has been spent tokenizing.
+func (s PoolStats) TokensPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Tokens) / s.Duration.Seconds()
+}
+
+// NewPool creates a pool of tokenizers configured by opts, as for New.
+func NewPool(opts *Options) *Pool {
+	p := &Pool{}
+	if opts != nil {
+		p.options = *opts
+	}
+	if p.options.Rules == nil {
+		// Resolve the default rules once, so that every tokenizer in the pool
+		// shares them rather than building its own.
+		p.options.Rules = DefaultRules()
+	}
+	return p
+}
+
+// Get returns a tokenizer for the given input, reusing a pooled one if
+// possible. Settings changed on a tokenizer are kept when it is returned to
+// the pool with Put.
+func (p *Pool) Get(input string) *Tokenizer {
+	if t, ok := p.pool.Get().(*Tokenizer); ok {
+		t.Reset(input)
+		return t
+	}
+	return New(input, &p.options)
+}
+
+// Put returns a tokenizer to the pool. The tokenizer must not be used again
+// by the caller.
+func (p *Pool) Put(t *Tokenizer) {
+	p.pool.Put(t)
+}
+
+// TokenizeValues tokenizes the input with a pooled tokenizer and returns the
+// tokens as values, as Tokenizer.TokenizeValues does.
+func (p *Pool) TokenizeValues(input string) ([]Token, error) {
+	t := p.Get(input)
+	defer p.Put(t)
+	started := time.Now()
+	tokens, err := t.TokenizeValues()
+	p.nanos.Add(int64(time.Since(started)))
+	p.inputs.Add(1)
+	p.bytes.Add(int64(len(input)))
+	p.tokens.Add(int64(len(tokens)))
+	if err != nil {
+		p.errors.Add(1)
+	}
+	return tokens, err
+}
+
+// Stats returns the work done by TokenizeValues so far. Each counter is read
+// atomically, but a snapshot taken while other goroutines are tokenizing may
+// count an input in some counters and not yet in others.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Inputs:   p.inputs.Load(),
+		Bytes:    p.bytes.Load(),
+		Tokens:   p.tokens.Load(),
+		Errors:   p.errors.Load(),
+		Duration: time.Duration(p.nanos.Load()),
+	}
+}
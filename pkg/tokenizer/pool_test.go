@@ -0,0 +1,184 @@
+package tokenizer
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const poolSampleInput = `def greet(name)
+    ### Say hello.
+    if name == "" then
+        "Hello, world!"
+    else
+        "Hello, \(name)!"
+    endif
+end
+x := 0x1F + 2r1010 * 3.14e2;
+`
+
+func TestTokenizeValuesMatchesTokenize(t *testing.T) {
+	pointers, err := NewTokenizer(poolSampleInput).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	values, err := NewTokenizer(poolSampleInput).TokenizeValues()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(values) != len(pointers) {
+		t.Fatalf("Expected %d tokens, got %d", len(pointers), len(values))
+	}
+	for i := range values {
+		if !reflect.DeepEqual(values[i], *pointers[i]) {
+			t.Errorf("Token %d: expected %+v, got %+v", i, *pointers[i], values[i])
+		}
+	}
+}
+
+func TestResetKeepsReturnedTokens(t *testing.T) {
+	tokenizer := NewTokenizer("alpha beta")
+	first, err := tokenizer.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tokenizer.Reset("gamma")
+	second, err := tokenizer.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := tokenTexts(first); !reflect.DeepEqual(got, []string{"alpha", "beta"}) {
+		t.Errorf("First tokens were changed by Reset: %v", got)
+	}
+	if got := tokenTexts(second); !reflect.DeepEqual(got, []string{"gamma"}) {
+		t.Errorf("Expected [gamma] after Reset, got %v", got)
+	}
+}
+
+func TestResetAfterTokenizeValues(t *testing.T) {
+	tokenizer := NewTokenizer("if x then y endif")
+	first, err := tokenizer.TokenizeValues()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tokenizer.Reset("z")
+	second, err := tokenizer.TokenizeValues()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(first) != 5 || first[0].Text != "if" || first[0].Type != StartTokenType {
+		t.Errorf("First tokens were changed by Reset: %+v", first)
+	}
+	if len(second) != 1 || second[0].Text != "z" || second[0].Span.Start != (Position{1, 1}) {
+		t.Errorf("Expected a single token z at 1:1 after Reset, got %+v", second)
+	}
+}
+
+func TestPoolConcurrentUse(t *testing.T) {
+	expected, err := NewTokenizer(poolSampleInput).TokenizeValues()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pool := NewPool(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				values, err := pool.TokenizeValues(poolSampleInput)
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if !reflect.DeepEqual(values, expected) {
+					t.Errorf("Pooled tokenizer produced different tokens")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPoolStats(t *testing.T) {
+	pool := NewPool(nil)
+	if stats := pool.Stats(); stats != (PoolStats{}) || stats.TokensPerSecond() != 0 {
+		t.Errorf("Expected no stats for a new pool, got %+v", stats)
+	}
+
+	if _, err := pool.TokenizeValues("alpha beta"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := pool.TokenizeValues("gamma \"unterminated"); err == nil {
+		t.Fatalf("Expected an error for an unterminated string")
+	}
+
+	stats := pool.Stats()
+	if stats.Inputs != 2 || stats.Bytes != 29 || stats.Errors != 1 {
+		t.Errorf("Expected 2 inputs of 29 bytes with 1 error, got %+v", stats)
+	}
+	// The failed input still returns the tokens before the error.
+	if stats.Tokens != 3 {
+		t.Errorf("Expected 3 tokens, got %d", stats.Tokens)
+	}
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewTokenizer(poolSampleInput).Tokenize(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPoolTokenizeValues(b *testing.B) {
+	pool := NewPool(nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := pool.TokenizeValues(poolSampleInput); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPoolKeepsSubtokensOfValues(t *testing.T) {
+	pool := NewPool(&Options{Rules: quasiQuoteRules(t)})
+	first, err := pool.TokenizeValues("$( f($x) )")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for range 3 {
+		if _, err := pool.TokenizeValues("yyy zzz $( yyy yyy yyy ) yyy"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if got := tokenTexts(first[0].Subtokens); !reflect.DeepEqual(got, []string{"f", "(", "$x", ")"}) {
+		t.Errorf("The subtokens of a kept value were changed: %v", got)
+	}
+	if got := tokenTexts(first[0].Subtokens[2].Subtokens); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("The subtokens of a kept hole were changed: %v", got)
+	}
+}
+
+func TestResetKeepsSubtokensOfValues(t *testing.T) {
+	tokenizer := New("$( f )", &Options{Rules: quasiQuoteRules(t)})
+	first, err := tokenizer.TokenizeValues()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tokenizer.Reset("yyy yyy")
+	if _, err := tokenizer.TokenizeValues(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokenTexts(first[0].Subtokens); !reflect.DeepEqual(got, []string{"f"}) {
+		t.Errorf("The subtokens of a kept value were changed by Reset: %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package tokenizer
+
+import (
+	"fmt"
+	"maps"
+	"unicode/utf8"
+)
+
+// PrecedenceRule gives what the precedences of operators whose rules do not
+// give one are calculated from, as described in docs/operators.md. Each field
+// that is given replaces that part of the defaults.
+type PrecedenceRule struct {
+	// The base precedence of each character that an operator may start with,
+	// where lower binds more tightly.
+	Base map[string]int `yaml:"base,omitempty"`
+
+	// The operators that can also be used as prefixes, such as - in -x.
+	Prefix []string `yaml:"prefix,omitempty"`
+}
+
+// compilePrecedenceRule checks the rule and sets the base precedences and
+// prefix operators that it gives.
+func compilePrecedenceRule(rule PrecedenceRule, rules *TokenizerRules) error {
+	if rule.Base != nil {
+		for _, text := range sortedKeys(rule.Base) {
+			if utf8.RuneCountInString(text) != 1 {
+				return fmt.Errorf("base precedence '%s' must be for a single character", text)
+			}
+			if rule.Base[text] <= 0 {
+				return fmt.Errorf("base precedence of '%s' must be positive, not %d", text, rule.Base[text])
+			}
+		}
+		rules.BasePrecedences = maps.Clone(rule.Base)
+	}
+	if rule.Prefix != nil {
+		rules.PrefixOperators = make(map[string]bool, len(rule.Prefix))
+		for _, text := range rule.Prefix {
+			rules.PrefixOperators[text] = true
+		}
+	}
+	return nil
+}
+
+// calculatePrecedence calculates the precedence of an operator from the base
+// precedence of its first character, less one if the character is repeated.
+// Every operator has an infix role, at 2000 more than its base precedence,
+// and the prefix operators also have a prefix role at their base precedence.
+// It reports false if the first character has no base precedence.
+func (rules *TokenizerRules) calculatePrecedence(operator string) ([3]int, bool) {
+	first, size := utf8.DecodeRuneInString(operator)
+	base, ok := rules.BasePrecedences[string(first)]
+	if !ok {
+		return [3]int{}, false
+	}
+	if second, _ := utf8.DecodeRuneInString(operator[size:]); second == first {
+		base--
+	}
+	prefix := 0
+	if rules.PrefixOperators[operator] {
+		prefix = base
+	}
+	return [3]int{prefix, base + 2000, 0}, true
+}
@@ -0,0 +1,58 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOperatorPrecedenceRule(t *testing.T) {
+	// A dialect with a prefix ! only needs to declare it.
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		OperatorPrecedence: &PrecedenceRule{Prefix: []string{"+", "-", "!"}},
+		Operator:           []OperatorRule{{Text: "!"}, {Text: "!!"}, {Text: "~>", Precedence: &[3]int{0, 500, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tokens, err := New("!x", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tokens[0].Type != OperatorTokenType || tokens[0].Precedence == nil || *tokens[0].Precedence != [3]int{130, 2130, 0} {
+		t.Errorf("Expected ! to be a prefix operator, got %s %v", tokens[0].Type, tokens[0].Precedence)
+	}
+	expected := map[string][3]int{"!!": {0, 2129, 0}, "~>": {0, 500, 0}, "==": {0, 2179, 0}}
+	for text, precedence := range expected {
+		if got := rules.OperatorPrecedences[text]; got != precedence {
+			t.Errorf("Expected %s to have the precedence %v, got %v", text, precedence, got)
+		}
+	}
+
+	// The base precedences can be replaced, for the operators of the same file.
+	rules, err = ApplyRulesToDefaults(&RulesFile{
+		OperatorPrecedence: &PrecedenceRule{Base: map[string]int{"@": 5}},
+		Operator:           []OperatorRule{{Text: "@@"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := rules.OperatorPrecedences["@@"]; got != [3]int{0, 2004, 0} {
+		t.Errorf("Expected @@ to have the precedence [0 2004 0], got %v", got)
+	}
+}
+
+func TestOperatorPrecedenceRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule   PrecedenceRule
+		reason string
+	}{
+		{PrecedenceRule{Base: map[string]int{"<=": 10}}, "base precedence '<=' must be for a single character"},
+		{PrecedenceRule{Base: map[string]int{"<": 0}}, "base precedence of '<' must be positive, not 0"},
+	}
+	for _, test := range tests {
+		_, err := ApplyRulesToDefaults(&RulesFile{OperatorPrecedence: &test.rule})
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%+v: expected an error %q, got %v", test.rule, test.reason, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package tokenizer
+
+// ProgressFunc is told how many bytes of the input have been tokenized, out
+// of the total length of the input.
+type ProgressFunc func(bytesProcessed, totalBytes int)
+
+// ProgressInterval is the number of bytes tokenized between calls of a
+// ProgressFunc, which is called less often than once per token so that it
+// costs little even for a slow callback.
+const ProgressInterval = 64 * 1024
+
+// SetProgress sets a function to be told of the progress of tokenizing, or
+// nil for none. It is called after every ProgressInterval bytes, and once
+// more when the whole input has been tokenized.
+func (t *Tokenizer) SetProgress(fn ProgressFunc) {
+	t.onProgress = fn
+}
+
+// reportProgress calls the progress function if another interval has been
+// tokenized since it was last called.
+func (t *Tokenizer) reportProgress() {
+	if t.onProgress == nil || t.position-t.progressReported < ProgressInterval {
+		return
+	}
+	t.progressReported = t.position
+	t.onProgress(t.position, len(t.input))
+}
@@ -0,0 +1,43 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOnProgress(t *testing.T) {
+	input := strings.Repeat("x := 1\n", 3*ProgressInterval/7+10)
+	var calls [][2]int
+	onProgress := func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	}
+	if _, err := New(input, &Options{OnProgress: onProgress}).Tokenize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 4 {
+		t.Fatalf("expected a call for each of 3 intervals and one at the end, got %v", calls)
+	}
+	for i, call := range calls {
+		if call[1] != len(input) {
+			t.Errorf("call %d: expected a total of %d, got %d", i, len(input), call[1])
+		}
+		if i > 0 && call[0] <= calls[i-1][0] {
+			t.Errorf("call %d: expected progress, got %v", i, calls)
+		}
+	}
+	if last := calls[len(calls)-1]; last[0] != len(input) {
+		t.Errorf("expected the last call to cover the input, got %v", last)
+	}
+}
+
+func TestNoProgressAfterError(t *testing.T) {
+	called := false
+	tokenizer := New(`"unterminated`, nil)
+	tokenizer.SetProgress(func(done, total int) { called = true })
+	if _, err := tokenizer.Tokenize(); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if called {
+		t.Errorf("expected no progress to be reported for a failed input")
+	}
+}
@@ -0,0 +1,33 @@
+package tokenizer
+
+import "strings"
+
+// qualifiedParts returns the names of the qualified identifier that starts
+// with name at the current position, such as foo, bar and baz for
+// foo::bar::baz, or nil if the name is not followed by the separator and
+// another name.
+func (t *Tokenizer) qualifiedParts(name string) []string {
+	if t.qualifiedSep == "" {
+		return nil
+	}
+	parts := []string{name}
+	rest := t.input[t.position+len(name):]
+	for {
+		after, ok := strings.CutPrefix(rest, t.qualifiedSep)
+		if !ok {
+			break
+		}
+		next := t.matchIdentifier(after)
+		if next == "" {
+			// A trailing separator, as in foo::, is left to be read as a
+			// token of its own.
+			break
+		}
+		parts = append(parts, next)
+		rest = after[len(next):]
+	}
+	if len(parts) == 1 {
+		return nil
+	}
+	return parts
+}
@@ -0,0 +1,42 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQualifiedIdentifiers(t *testing.T) {
+	tests := []struct {
+		separator string
+		input     string
+		texts     []string
+		parts     [][]string
+	}{
+		{"::", "foo::bar::baz + x", []string{"foo::bar::baz", "+", "x"}, [][]string{{"foo", "bar", "baz"}, nil, nil}},
+		{".", "m.n(1)", []string{"m.n", "(", "1", ")"}, [][]string{{"m", "n"}, nil, nil, nil}},
+		// A trailing separator is not part of the identifier.
+		{".", "a. b", []string{"a", ".", "b"}, [][]string{nil, nil, nil}},
+		// Without a separator, the names are separate tokens.
+		{"", "m.n", []string{"m", ".", "n"}, [][]string{nil, nil, nil}},
+	}
+	for _, test := range tests {
+		tokens, err := New(test.input, &Options{QualifiedSeparator: test.separator}).Tokenize()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", test.input, err)
+		}
+		if got := tokenTexts(tokens); !reflect.DeepEqual(got, test.texts) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.texts, got)
+			continue
+		}
+		for i, token := range tokens {
+			if !reflect.DeepEqual(token.Parts, test.parts[i]) {
+				t.Errorf("%q: token %d: expected parts %v, got %v", test.input, i, test.parts[i], token.Parts)
+			}
+		}
+	}
+
+	tokens, _ := New("foo::bar", &Options{QualifiedSeparator: "::"}).Tokenize()
+	if got := tokens[0]; got.Type != VariableTokenType || got.Span != (Span{Position{1, 1}, Position{1, 9}}) {
+		t.Errorf("Expected a variable spanning 1:1-1:9, got %s at %v", got.Type, got.Span)
+	}
+}
@@ -0,0 +1,171 @@
+package tokenizer
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuasiQuoteRule represents a form that quotes a region of code as a
+// template, such as $( ... ), with holes, such as $x or $( ... ), that are
+// filled in when the template is used.
+type QuasiQuoteRule struct {
+	Open string `yaml:"open"` // Opens the template, ending with a bracket that the template closes
+	Hole string `yaml:"hole"` // Marks a hole in the template, or "" for none
+}
+
+// quasiFrame is an open template or hole, whose tokens are gathered into the
+// Subtokens of its token when it is closed.
+type quasiFrame struct {
+	index  int    // The index in t.tokens of the template or hole token
+	offset int    // The byte offset of the start of its text
+	hole   string // The hole marker of a template, or "" for a hole
+	close  string // The closing bracket, or "" for a hole of one name
+	depth  int    // The number of brackets open within it
+}
+
+// compileQuasiQuoteRules checks the quasi-quote rules and returns the hole
+// markers by open text.
+func compileQuasiQuoteRules(rules []QuasiQuoteRule) (map[string]string, error) {
+	holes := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		if rule.Open == "" {
+			return nil, fmt.Errorf("quasi-quote rule with no open text")
+		}
+		last, _ := utf8.DecodeLastRuneInString(rule.Open)
+		if closingBracket(last) == "" {
+			return nil, fmt.Errorf("quasi-quote '%s' does not end with an opening bracket", rule.Open)
+		}
+		if hole, ok := holes[rule.Open]; ok && hole != rule.Hole {
+			return nil, fmt.Errorf("quasi-quote '%s' is given the holes '%s' and '%s'", rule.Open, hole, rule.Hole)
+		}
+		holes[rule.Open] = rule.Hole
+	}
+	return holes, nil
+}
+
+// quasiOpenTexts returns the open texts of the quasi-quotes, longest first so
+// that the longest match wins.
+func quasiOpenTexts(holes map[string]string) []string {
+	opens := sortedKeys(holes)
+	slices.SortStableFunc(opens, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	return opens
+}
+
+// closingBracket returns the bracket that closes an opening bracket, or "" if
+// r is not one.
+func closingBracket(r rune) string {
+	switch r {
+	case '(':
+		return ")"
+	case '[':
+		return "]"
+	case '{':
+		return "}"
+	}
+	return ""
+}
+
+// matchQuasiQuote matches the opening of a template, or of a hole directly
+// within a template. The hole marker is tried first, so that with $( as the
+// template and $ as the hole, $( within a template opens a hole.
+func (t *Tokenizer) matchQuasiQuote() *Token {
+	if len(t.rules.quasiOpens) == 0 {
+		return nil
+	}
+	rest := t.input[t.position:]
+	if n := len(t.quasiStack); n > 0 && t.quasiStack[n-1].hole != "" {
+		if after, ok := strings.CutPrefix(rest, t.quasiStack[n-1].hole); ok {
+			// A hole holds either a bracketed expression or a single name.
+			next, _ := utf8.DecodeRuneInString(after)
+			marker := len(rest) - len(after)
+			if closer := closingBracket(next); closer != "" {
+				return t.openQuasi(HoleTokenType, marker+1, "", closer)
+			}
+			if next == '_' || unicode.IsLetter(next) {
+				return t.openQuasi(HoleTokenType, marker, "", "")
+			}
+		}
+	}
+	for _, open := range t.rules.quasiOpens {
+		if strings.HasPrefix(rest, open) {
+			last, _ := utf8.DecodeLastRuneInString(open)
+			return t.openQuasi(QuasiQuoteTokenType, len(open), t.rules.QuasiQuotes[open], closingBracket(last))
+		}
+	}
+	return nil
+}
+
+// openQuasi creates the token of a template or hole whose opening text is the
+// next n bytes, and pushes a frame that gathers the tokens that follow into it.
+func (t *Tokenizer) openQuasi(tokenType TokenType, n int, hole, closer string) *Token {
+	start := t.here()
+	offset := t.position
+	t.advance(n)
+	token := t.arena.alloc(NewToken(t.input[offset:t.position], tokenType, t.spanFrom(start)))
+	// The token is the next one to be added.
+	t.quasiStack = append(t.quasiStack, quasiFrame{index: len(t.tokens), offset: offset, hole: hole, close: closer})
+	return token
+}
+
+// trackQuasi updates the open templates and holes for the token just added,
+// closing those that it ends.
+func (t *Tokenizer) trackQuasi() error {
+	for len(t.quasiStack) > 0 {
+		frame := &t.quasiStack[len(t.quasiStack)-1]
+		last := len(t.tokens) - 1
+		token := t.tokens[last]
+		switch {
+		case frame.index == last:
+			// The token is the one that opened the frame.
+			return nil
+		case frame.close == "":
+			// A hole of one name ends with it.
+			t.closeQuasi(len(t.tokens))
+		case token.Type == OpenDelimiterTokenType:
+			frame.depth++
+			return nil
+		case token.Type != CloseDelimiterTokenType:
+			return nil
+		case frame.depth > 0:
+			frame.depth--
+			return nil
+		case token.CanonicalText() != frame.close:
+			return errorAt(token.Span, "expected '%s' to close '%s'", frame.close, t.tokens[frame.index].Text)
+		default:
+			// The closing bracket becomes part of the text of the template
+			// or hole rather than one of its subtokens.
+			t.closeQuasi(last)
+		}
+	}
+	return nil
+}
+
+// closeQuasi ends the innermost template or hole with the last token added.
+// The tokens after its own, up to end, become its subtokens and the rest are
+// dropped.
+func (t *Tokenizer) closeQuasi(end int) {
+	frame := t.quasiStack[len(t.quasiStack)-1]
+	t.quasiStack = t.quasiStack[:len(t.quasiStack)-1]
+	token := t.tokens[frame.index]
+	token.Subtokens = slices.Clone(t.tokens[frame.index+1 : end])
+	token.Text = t.input[frame.offset:t.position]
+	token.Span.End = t.tokens[len(t.tokens)-1].Span.End
+	clear(t.tokens[frame.index+1:])
+	t.tokens = t.tokens[:frame.index+1]
+}
+
+// unclosedQuasi returns an error for the innermost template or hole that is
+// still open at the end of the input, or nil if there is none.
+func (t *Tokenizer) unclosedQuasi() error {
+	if len(t.quasiStack) == 0 {
+		return nil
+	}
+	token := t.tokens[t.quasiStack[len(t.quasiStack)-1].index]
+	return errorAt(Span{token.Span.Start, t.here()}, "unclosed '%s'", token.Text)
+}
@@ -0,0 +1,103 @@
+package tokenizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// quasiQuoteRules returns rules with $( ... ) templates whose holes are
+// marked by $.
+func quasiQuoteRules(t *testing.T) *TokenizerRules {
+	t.Helper()
+	rules, err := ApplyRulesToDefaults(&RulesFile{QuasiQuote: []QuasiQuoteRule{{Open: "$(", Hole: "$"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return rules
+}
+
+func TestQuasiQuote(t *testing.T) {
+	tokens, err := New("t := $( f($x, $(a + 1)) )\ny", &Options{Rules: quasiQuoteRules(t)}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, []string{"t", ":=", "$( f($x, $(a + 1)) )", "y"}) {
+		t.Fatalf("Expected the template as one token, got %v", got)
+	}
+
+	template := tokens[2]
+	if template.Type != QuasiQuoteTokenType || template.Span != (Span{Position{1, 6}, Position{1, 26}}) {
+		t.Errorf("Expected a template at 1:6-1:26, got %s at %v", template.Type, template.Span)
+	}
+	if template.LnAfter == nil || !*template.LnAfter {
+		t.Errorf("Expected the template to be followed by a newline")
+	}
+	if got := tokenTexts(template.Subtokens); !reflect.DeepEqual(got, []string{"f", "(", "$x", ",", "$(a + 1)", ")"}) {
+		t.Fatalf("Unexpected subtokens %v", got)
+	}
+
+	name, expression := template.Subtokens[2], template.Subtokens[4]
+	if name.Type != HoleTokenType || expression.Type != HoleTokenType {
+		t.Errorf("Expected two holes, got %s and %s", name.Type, expression.Type)
+	}
+	if got := tokenTexts(name.Subtokens); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("Expected the hole $x to hold [x], got %v", got)
+	}
+	if got := tokenTexts(expression.Subtokens); !reflect.DeepEqual(got, []string{"a", "+", "1"}) {
+		t.Errorf("Expected the hole $(a + 1) to hold [a + 1], got %v", got)
+	}
+}
+
+func TestQuasiQuoteStream(t *testing.T) {
+	var streamed []*Token
+	err := New("a $( [b] ) c", &Options{Rules: quasiQuoteRules(t)}).TokenizeStream(func(token *Token) error {
+		streamed = append(streamed, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokenTexts(streamed); !reflect.DeepEqual(got, []string{"a", "$( [b] )", "c"}) {
+		t.Errorf("Expected the template to be emitted whole, got %v", got)
+	}
+}
+
+func TestQuasiQuoteErrors(t *testing.T) {
+	rules := quasiQuoteRules(t)
+	tests := []struct {
+		input  string
+		reason string
+	}{
+		{"$( f(x)", "unclosed '$('"},
+		{"$( $(x )", "unclosed '$('"},
+		{"$( x ]", "expected ')' to close '$('"},
+	}
+	for _, test := range tests {
+		_, err := New(test.input, &Options{Rules: rules}).Tokenize()
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%q: expected an error %q, got %v", test.input, test.reason, err)
+		}
+	}
+
+	// Outside a template, the hole marker is an ordinary operator.
+	tokens, err := New("$x", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, []string{"$", "x"}) {
+		t.Errorf("Expected [$ x] outside a template, got %v", got)
+	}
+}
+
+func TestQuasiQuoteRules(t *testing.T) {
+	for _, rule := range []QuasiQuoteRule{{Open: ""}, {Open: "quote"}} {
+		if _, err := ApplyRulesToDefaults(&RulesFile{QuasiQuote: []QuasiQuoteRule{rule}}); err == nil {
+			t.Errorf("Expected an error for the open text %q", rule.Open)
+		}
+	}
+	_, err := ApplyRulesToDefaults(&RulesFile{QuasiQuote: []QuasiQuoteRule{{Open: "`(", Hole: ","}, {Open: "`(", Hole: "~"}}})
+	if err == nil {
+		t.Errorf("Expected an error for a template with two hole markers")
+	}
+}
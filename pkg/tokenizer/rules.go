@@ -1,13 +1,23 @@
 package tokenizer
 
 import (
+	_ "embed"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
 )
 
-// RulesFile represents the structure of a YAML rules file
+// RulesFile represents the structure of a YAML rules file. A token may only
+// be in one of the sections that classify tokens, unless the rules for it
+// have different priorities, when the one with the highest priority wins.
 type RulesFile struct {
 	Bracket  []BracketRule  `yaml:"bracket"`
 	Prefix   []PrefixRule   `yaml:"prefix"`
@@ -16,24 +26,59 @@ type RulesFile struct {
 	Wildcard []WildcardRule `yaml:"wildcard"`
 	Operator []OperatorRule `yaml:"operator"`
 	Mark     []MarkRule     `yaml:"mark"`
+	String   []StringRule   `yaml:"string,omitempty"`
+	Define   []DefineRule   `yaml:"define,omitempty"`
+	Style    *StyleRule     `yaml:"style,omitempty"`
+	Number   *NumberRule    `yaml:"number,omitempty"`
+
+	// Texts that stand for tokens of the other sections, such as the
+	// keywords of another language.
+	Translate []TranslateRule `yaml:"translate,omitempty"`
+
+	// Whether start, end, bridge and prefix keywords are matched in any
+	// case, for languages where IF and If mean if.
+	CaseInsensitiveKeywords bool `yaml:"case_insensitive_keywords,omitempty"`
+
+	// Forms that quote a region of code as a template with holes.
+	QuasiQuote []QuasiQuoteRule `yaml:"quasi_quote,omitempty"`
+
+	// What the precedences of operators without one are calculated from.
+	OperatorPrecedence *PrecedenceRule `yaml:"operator_precedence,omitempty"`
+
+	// Characters other than letters, digits and underscores that may be part
+	// of an identifier.
+	Identifier []IdentifierRule `yaml:"identifier,omitempty"`
+
+	// Characters such as $ and # that are given a role of their own.
+	Sigil []SigilRule `yaml:"sigil,omitempty"`
 }
 
 type MarkRule struct {
-	Text string `yaml:"text"`
+	Text     string `yaml:"text"`
+	Role     string `yaml:"role,omitempty"` // MarkSeparator, MarkTerminator or "" for none
+	Priority int    `yaml:"priority,omitempty"`
 }
 
+// The roles that a mark rule can give its mark, for the parser's benefit.
+const (
+	MarkSeparator  = "separator"  // Goes between items, such as , in f(x, y)
+	MarkTerminator = "terminator" // Goes after an item, such as ; after a statement
+)
+
 // BracketRule represents a bracket token rule
 type BracketRule struct {
 	Text      string   `yaml:"text"`
 	ClosedBy  []string `yaml:"closed_by"`
 	InfixPrec int      `yaml:"infix"`
 	Prefix    bool     `yaml:"prefix"`
+	Priority  int      `yaml:"priority,omitempty"`
 }
 
 // PrefixRule represents a prefix token rule
 type PrefixRule struct {
-	Text  string `yaml:"text"`
-	Arity Arity  `yaml:"arity,omitempty"` // Optional arity field
+	Text     string `yaml:"text"`
+	Arity    Arity  `yaml:"arity,omitempty"` // Optional arity field
+	Priority int    `yaml:"priority,omitempty"`
 }
 
 // StartRule represents a start token rule
@@ -42,6 +87,8 @@ type StartRule struct {
 	ClosedBy  []string `yaml:"closed_by"`
 	Expecting []string `yaml:"expecting"`
 	Single    bool     `yaml:"single"`
+	Arity     Arity    `yaml:"arity,omitempty"` // Optional arity field
+	Priority  int      `yaml:"priority,omitempty"`
 }
 
 // BridgeRule represents a bridge token rule
@@ -49,6 +96,8 @@ type BridgeRule struct {
 	Text      string   `yaml:"text"`
 	Expecting []string `yaml:"expecting"`
 	In        []string `yaml:"in"`
+	Arity     Arity    `yaml:"arity,omitempty"` // Optional arity field
+	Priority  int      `yaml:"priority,omitempty"`
 }
 
 // CompoundRule represents a compound token rule
@@ -60,13 +109,36 @@ type CompoundRule struct {
 
 // WildcardRule represents a wildcard token rule
 type WildcardRule struct {
+	Text     string `yaml:"text"`
+	Priority int    `yaml:"priority,omitempty"`
+}
+
+// StringRule declares a quote character that starts string literals. The
+// string is closed by the same character, except that « is closed by ».
+type StringRule struct {
+	Text          string `yaml:"text"`
+	Interpolation bool   `yaml:"interpolation"` // Whether \( \[ and \{ interpolate expressions
+	Raw           bool   `yaml:"raw"`           // Whether backslashes are ordinary characters
+}
+
+// DefineRule replaces a token with the tokens of some other source text
+type DefineRule struct {
+	Text     string `yaml:"text"`
+	As       string `yaml:"as"` // Source text whose tokens replace the token
+	Priority int    `yaml:"priority,omitempty"`
+}
+
+// TranslateRule represents a translation of a token into another language
+type TranslateRule struct {
 	Text string `yaml:"text"`
+	As   string `yaml:"as"` // The token of the rules that the text stands for
 }
 
 // OperatorRule represents an operator token rule
 type OperatorRule struct {
-	Text       string `yaml:"text"`
-	Precedence [3]int `yaml:"precedence"` // [prefix, infix, postfix]
+	Text       string  `yaml:"text"`
+	Precedence *[3]int `yaml:"precedence,omitempty"` // [prefix, infix, postfix], or nil to calculate it
+	Priority   int     `yaml:"priority,omitempty"`
 }
 
 // CustomRuleType represents the type of custom rule
@@ -82,12 +154,46 @@ const (
 	CustomOpenDelimiter
 	CustomCloseDelimiter
 	CustomMark
+	CustomDefine
+	CustomSigil
 )
 
+// String returns the name of the rule type. The end and close types are
+// derived from the closed_by lists of start and bracket rules.
+func (rt CustomRuleType) String() string {
+	switch rt {
+	case CustomWildcard:
+		return "wildcard"
+	case CustomStart:
+		return "start"
+	case CustomEnd:
+		return "end"
+	case CustomBridge:
+		return "bridge"
+	case CustomPrefix:
+		return "prefix"
+	case CustomOperator:
+		return "operator"
+	case CustomOpenDelimiter:
+		return "bracket"
+	case CustomCloseDelimiter:
+		return "close"
+	case CustomMark:
+		return "mark"
+	case CustomDefine:
+		return "define"
+	case CustomSigil:
+		return "sigil"
+	}
+	return fmt.Sprintf("CustomRuleType(%d)", int(rt))
+}
+
 // CustomRuleEntry holds the rule type and any associated data
 type CustomRuleEntry struct {
-	Type CustomRuleType
-	Data interface{} // Can be StartTokenData, BridgeTokenData, etc.
+	Type   CustomRuleType
+	Data   interface{} // Can be StartTokenData, BridgeTokenData, etc.
+	Custom bool        // Whether the rule came from a rules file rather than the defaults
+	Alias  string      // The token that the text translates, for a translate rule, or ""
 }
 
 // TokenizerRules holds all the rule maps that can be customized
@@ -100,33 +206,132 @@ type TokenizerRules struct {
 	WildcardTokens      map[string]bool
 	OperatorPrecedences map[string][3]int // [prefix, infix, postfix]
 	MarkTokens          map[string]bool
+	Defines             map[string]string `json:",omitempty"` // Replacement source text, by token
+
+	// The tokens that translated texts stand for, by translated text. A
+	// translation is classified as its token and has it as its alias.
+	Translations map[string]string `json:",omitempty"`
+
+	// Quote characters that start string literals. Rules made without any,
+	// as a literal, use the default ones.
+	Quotes map[string]QuoteData
+
+	// Style checks of identifiers, or nil for none.
+	Style *StyleData `json:",omitempty"`
+
+	// The letters that start the exponent of a number, for the bases whose
+	// markers are not the default ones.
+	ExponentMarkers map[int]string `json:",omitempty"`
+
+	// Whether start, end, bridge and prefix keywords are matched in any
+	// case. A keyword matched in another case has its text as its alias.
+	CaseInsensitiveKeywords bool `json:",omitempty"`
+
+	// The hole markers of the quasi-quotes, by the text that opens them.
+	QuasiQuotes map[string]string `json:",omitempty"`
+
+	// The base precedences of the characters that operators start with, and
+	// the operators with a prefix role, from which the precedences of
+	// operator rules without one are calculated.
+	BasePrecedences map[string]int  `json:",omitempty"`
+	PrefixOperators map[string]bool `json:",omitempty"`
+
+	// The roles of the marks that have one, MarkSeparator or MarkTerminator.
+	MarkRoles map[string]string `json:",omitempty"`
+
+	// Where each extra character of identifiers may appear, IdentifierInner,
+	// IdentifierTrailing or IdentifierSuffix, by character.
+	IdentifierChars map[string]string `json:",omitempty"`
+
+	// The roles of the sigils, SigilIdentifier or SigilOperator, by sigil.
+	SigilRoles map[string]string `json:",omitempty"`
+
+	// The token texts of each section that came from a rules file rather
+	// than the defaults, as recorded by ApplyRulesToDefaults. They do not
+	// change how input is tokenized, so they are left out of the
+	// fingerprint.
+	Custom map[string]map[string]bool `json:"-"`
+
+	// The priorities of the rules that have them, by section and token
+	// text. Of two rules for the same token, the one with the higher
+	// priority is used; a rule without one has priority 0.
+	Priorities map[string]map[string]int `json:",omitempty"`
+
+	// Precomputed lookup map for efficient matching. It is derived from the
+	// fields above, so it is left out of the fingerprint.
+	TokenLookup map[string]CustomRuleEntry `json:"-"`
+
+	// The texts of the lookup that would not otherwise be read as one token,
+	// longest first, such as operators with characters that are not sign
+	// characters. They are derived from the lookup by BuildTokenLookup.
+	symbols []string
+
+	// The marks that are runs of sign characters, longest first, at which
+	// an unknown run of sign characters is cut. They are derived from the
+	// lookup by BuildTokenLookup.
+	signMarks []string
+
+	// The length of the longest token of the lookup that is a run of sign
+	// characters, which bounds the search for the longest one that starts
+	// a run. It is derived from the lookup by BuildTokenLookup.
+	longestSignToken int
+
+	// The keywords that are matched in any case, by lower case form, when
+	// CaseInsensitiveKeywords is set. They are derived from the lookup by
+	// BuildTokenLookup.
+	keywordsByFold map[string]string
+
+	// The open texts of the quasi-quotes, longest first. They are derived
+	// from QuasiQuotes by BuildTokenLookup.
+	quasiOpens []string
+
+	frozen atomic.Bool // Set by Freeze, and atomic because New sets it
+}
+
+// defaultRulesYAML is the rules file that the default rules are read from.
+//
+//go:embed defaults.yaml
+var defaultRulesYAML []byte
+
+// defaultRules is read from defaultRulesYAML once, and copied by DefaultRules.
+// It is frozen because it is only ever read. It is set by init rather than
+// by its declaration because applying rules can tokenize define rules, which
+// would make the declaration depend on itself.
+var defaultRules *TokenizerRules
 
-	// Precomputed lookup map for efficient matching
-	TokenLookup map[string]CustomRuleEntry
+func init() {
+	defaultRules = loadDefaultRules()
+	defaultQuotes = defaultRules.Quotes
 }
 
-// DefaultRules returns the default tokenizer rules
-func DefaultRules() *TokenizerRules {
-	rules := &TokenizerRules{
-		StartTokens:         getDefaultStartTokens(),
-		BridgeTokens:        getDefaultBridgeTokens(),
-		PrefixTokens:        getDefaultPrefixTokens(),
-		DelimiterMappings:   getDefaultDelimiterMappings(),
-		DelimiterProperties: getDefaultDelimiterProperties(),
-		WildcardTokens:      getDefaultWildcardTokens(),
-		OperatorPrecedences: getDefaultOperatorPrecedences(),
-		MarkTokens:          map[string]bool{",": true, ";": true},
-	}
-
-	// Build the precomputed lookup map
-	// Note: Default rules should never have conflicts, so we panic if there's an error
-	if err := rules.BuildTokenLookup(); err != nil {
+// loadDefaultRules builds the default rules from the embedded rules file. The
+// file is part of the package, so an error in it is a bug and panics.
+func loadDefaultRules() *TokenizerRules {
+	file, err := ParseRulesFile(defaultRulesYAML)
+	if err != nil {
 		panic(fmt.Sprintf("Invalid default rules: %v", err))
 	}
-
+	rules, err := applyRules(&TokenizerRules{OperatorPrecedences: make(map[string][3]int)}, file, false)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid default rules: %v", err))
+	}
+	rules.Freeze()
 	return rules
 }
 
+// DefaultRules returns the default tokenizer rules, which are read from the
+// rules file given by DefaultRulesYAML.
+func DefaultRules() *TokenizerRules {
+	return defaultRules.Clone()
+}
+
+// DefaultRulesYAML returns the rules file that the default rules are read
+// from, so that they can be inspected, exported and compared with other rules
+// in the same form as a rules file.
+func DefaultRulesYAML() []byte {
+	return slices.Clone(defaultRulesYAML)
+}
+
 // LoadRulesFile loads and parses a YAML rules file
 func LoadRulesFile(filename string) (*RulesFile, error) {
 	data, err := os.ReadFile(filename)
@@ -134,18 +339,64 @@ func LoadRulesFile(filename string) (*RulesFile, error) {
 		return nil, fmt.Errorf("failed to read rules file '%s': %w", filename, err)
 	}
 
-	var rules RulesFile
-	if err := yaml.Unmarshal(data, &rules); err != nil {
+	rules, err := ParseRulesFile(data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse YAML in rules file '%s': %w", filename, err)
 	}
 
+	return rules, nil
+}
+
+// LoadRulesFS loads and parses a YAML rules file from a file system, such as
+// an embed.FS, so that an application can ship its rules inside its binary.
+// The path uses the slash-separated form of io/fs.
+func LoadRulesFS(fsys fs.FS, path string) (*RulesFile, error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file '%s': %w", path, err)
+	}
+
+	rules, err := ParseRulesFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML in rules file '%s': %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// ParseRulesFile parses the YAML contents of a rules file.
+func ParseRulesFile(data []byte) (*RulesFile, error) {
+	var rules RulesFile
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
 	return &rules, nil
 }
 
 // ApplyRulesToDefaults applies the rules from a RulesFile to create a new TokenizerRules.
 // Returns an error if there are conflicting token definitions.
 func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
-	tokenizerRules := DefaultRules()
+	return applyRules(DefaultRules(), rules, true)
+}
+
+// applyRules applies the rules from a RulesFile to tokenizerRules, replacing
+// each section that the file has, except for operators, which are added to.
+// If custom is set, the rules are recorded in Custom as not being defaults.
+func applyRules(tokenizerRules *TokenizerRules, rules *RulesFile, custom bool) (*TokenizerRules, error) {
+	if custom {
+		tokenizerRules.Custom = make(map[string]map[string]bool)
+	}
+	markCustom := func(section, text string, priority int) {
+		if custom {
+			if tokenizerRules.Custom[section] == nil {
+				tokenizerRules.Custom[section] = make(map[string]bool)
+			}
+			tokenizerRules.Custom[section][text] = true
+		}
+		if priority != 0 {
+			tokenizerRules.SetPriority(section, text, priority)
+		}
+	}
 
 	// Apply bracket rules
 	if len(rules.Bracket) > 0 {
@@ -153,6 +404,7 @@ func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
 		tokenizerRules.DelimiterProperties = make(map[string]DelimiterProp)
 
 		for _, rule := range rules.Bracket {
+			markCustom("bracket", rule.Text, rule.Priority)
 			tokenizerRules.DelimiterMappings[rule.Text] = rule.ClosedBy
 			tokenizerRules.DelimiterProperties[rule.Text] = DelimiterProp{rule.InfixPrec, rule.Prefix}
 		}
@@ -162,6 +414,7 @@ func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
 	if len(rules.Prefix) > 0 {
 		tokenizerRules.PrefixTokens = make(map[string]PrefixTokenData)
 		for _, rule := range rules.Prefix {
+			markCustom("prefix", rule.Text, rule.Priority)
 			tokenizerRules.PrefixTokens[rule.Text] = PrefixTokenData{rule.Arity}
 		}
 	}
@@ -169,8 +422,19 @@ func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
 	// Apply mark rules
 	if len(rules.Mark) > 0 {
 		tokenizerRules.MarkTokens = make(map[string]bool)
+		tokenizerRules.MarkRoles = nil
 		for _, rule := range rules.Mark {
+			if err := checkMarkRule(rule); err != nil {
+				return nil, err
+			}
+			markCustom("mark", rule.Text, rule.Priority)
 			tokenizerRules.MarkTokens[rule.Text] = true
+			if rule.Role != "" {
+				if tokenizerRules.MarkRoles == nil {
+					tokenizerRules.MarkRoles = make(map[string]string)
+				}
+				tokenizerRules.MarkRoles[rule.Text] = rule.Role
+			}
 		}
 	}
 
@@ -178,9 +442,11 @@ func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
 	if len(rules.Start) > 0 {
 		tokenizerRules.StartTokens = make(map[string]StartTokenData)
 		for _, rule := range rules.Start {
+			markCustom("start", rule.Text, rule.Priority)
 			tokenizerRules.StartTokens[rule.Text] = StartTokenData{
 				Expecting: rule.Expecting,
 				ClosedBy:  rule.ClosedBy,
+				Arity:     rule.Arity,
 			}
 		}
 	}
@@ -189,9 +455,11 @@ func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
 	if len(rules.Bridge) > 0 {
 		tokenizerRules.BridgeTokens = make(map[string]BridgeTokenData)
 		for _, rule := range rules.Bridge {
+			markCustom("bridge", rule.Text, rule.Priority)
 			tokenizerRules.BridgeTokens[rule.Text] = BridgeTokenData{
 				Expecting: rule.Expecting,
 				In:        rule.In,
+				Arity:     rule.Arity,
 			}
 		}
 	}
@@ -200,14 +468,118 @@ func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
 	if len(rules.Wildcard) > 0 {
 		tokenizerRules.WildcardTokens = make(map[string]bool)
 		for _, rule := range rules.Wildcard {
+			markCustom("wildcard", rule.Text, rule.Priority)
 			tokenizerRules.WildcardTokens[rule.Text] = true
 		}
 	}
 
+	// Apply operator precedence rules, which the operator rules that follow
+	// are calculated from
+	if rules.OperatorPrecedence != nil {
+		if err := compilePrecedenceRule(*rules.OperatorPrecedence, tokenizerRules); err != nil {
+			return nil, err
+		}
+	}
+
 	// Apply operator rules
 	if len(rules.Operator) > 0 {
 		for _, rule := range rules.Operator {
-			tokenizerRules.OperatorPrecedences[rule.Text] = rule.Precedence
+			if err := checkOperatorRule(rule); err != nil {
+				return nil, err
+			}
+			var precedence [3]int
+			if rule.Precedence != nil {
+				precedence = *rule.Precedence
+			} else {
+				// A rule without a precedence has one calculated for it.
+				calculated, ok := tokenizerRules.calculatePrecedence(rule.Text)
+				if !ok {
+					return nil, fmt.Errorf("operator '%s' has no precedence, and no base precedence to calculate one from", rule.Text)
+				}
+				precedence = calculated
+			}
+			markCustom("operator", rule.Text, rule.Priority)
+			tokenizerRules.OperatorPrecedences[rule.Text] = precedence
+		}
+	}
+
+	// Apply string rules
+	if len(rules.String) > 0 {
+		tokenizerRules.Quotes = make(map[string]QuoteData)
+		for _, rule := range rules.String {
+			if err := checkStringRule(rule); err != nil {
+				return nil, err
+			}
+			tokenizerRules.Quotes[rule.Text] = QuoteData{rule.Interpolation, rule.Raw}
+		}
+	}
+
+	// Apply define rules
+	if len(rules.Define) > 0 {
+		tokenizerRules.Defines = make(map[string]string)
+		for _, rule := range rules.Define {
+			markCustom("define", rule.Text, rule.Priority)
+			tokenizerRules.Defines[rule.Text] = rule.As
+		}
+	}
+
+	// Apply translate rules
+	if len(rules.Translate) > 0 {
+		tokenizerRules.Translations = make(map[string]string)
+		for _, rule := range rules.Translate {
+			if as, ok := tokenizerRules.Translations[rule.Text]; ok && as != rule.As {
+				return nil, fmt.Errorf("translation '%s' is given as both '%s' and '%s'", rule.Text, as, rule.As)
+			}
+			tokenizerRules.Translations[rule.Text] = rule.As
+		}
+	}
+
+	// Apply style rules
+	if rules.Style != nil {
+		style, err := compileStyleRule(*rules.Style)
+		if err != nil {
+			return nil, err
+		}
+		tokenizerRules.Style = style
+	}
+
+	// Apply number rules
+	if rules.Number != nil {
+		markers, err := compileNumberRule(*rules.Number)
+		if err != nil {
+			return nil, err
+		}
+		tokenizerRules.ExponentMarkers = markers
+	}
+	tokenizerRules.CaseInsensitiveKeywords = rules.CaseInsensitiveKeywords
+
+	// Apply quasi-quote rules
+	if len(rules.QuasiQuote) > 0 {
+		holes, err := compileQuasiQuoteRules(rules.QuasiQuote)
+		if err != nil {
+			return nil, err
+		}
+		tokenizerRules.QuasiQuotes = holes
+	}
+
+	// Apply identifier rules
+	if len(rules.Identifier) > 0 {
+		chars, err := compileIdentifierRules(rules.Identifier)
+		if err != nil {
+			return nil, err
+		}
+		tokenizerRules.IdentifierChars = chars
+	}
+
+	// Apply sigil rules
+	if len(rules.Sigil) > 0 {
+		tokenizerRules.SigilRoles = make(map[string]string)
+		for _, rule := range rules.Sigil {
+			if err := checkSigilRule(rule); err != nil {
+				return nil, err
+			}
+			markCustom("sigil", rule.Text, rule.Priority)
+			tokenizerRules.SigilRoles[rule.Text] = rule.Role
 		}
 	}
 
@@ -216,154 +588,11 @@ func ApplyRulesToDefaults(rules *RulesFile) (*TokenizerRules, error) {
 		return nil, err
 	}
 
-	return tokenizerRules, nil
-}
-
-// Helper functions to get default values (these will copy from the existing global variables)
-
-func getDefaultOperatorPrecedences() map[string][3]int {
-	m := make(map[string][3]int)
-	updateOperatorPrecedence(m, ".")
-	updateOperatorPrecedence(m, "*")
-	updateOperatorPrecedence(m, "/")
-	updateOperatorPrecedence(m, "+")
-	updateOperatorPrecedence(m, "-")
-	updateOperatorPrecedence(m, "<")
-	updateOperatorPrecedence(m, ">")
-	updateOperatorPrecedence(m, "<=")
-	updateOperatorPrecedence(m, ">=")
-	updateOperatorPrecedence(m, "==")
-	updateOperatorPrecedence(m, "..<")
-	updateOperatorPrecedence(m, "..=")
-	updateOperatorPrecedence(m, ":=")
-	updateOperatorPrecedence(m, "<-")
-	updateOperatorPrecedence(m, "<--")
-	m["in"] = [3]int{0, 3000, 0}
-	return m
-}
-
-func getDefaultStartTokens() map[string]StartTokenData {
-	return map[string]StartTokenData{
-		"def": {
-			Expecting: []string{"=>>"},
-			ClosedBy:  []string{"end", "enddef"},
-			Arity:     One,
-		},
-		"let": {
-			Expecting: []string{},
-			ClosedBy:  []string{"end", "endlet"},
-			Arity:     Many,
-		},
-		"switch": {
-			Expecting: []string{"case", "else"},
-			ClosedBy:  []string{"end", "endswitch"},
-			Arity:     One,
-		},
-		"if": {
-			Expecting: []string{"then"},
-			ClosedBy:  []string{"end", "endif"},
-			Arity:     One,
-		},
-		"ifnot": {
-			Expecting: []string{"then"},
-			ClosedBy:  []string{"end", "endifnot"},
-			Arity:     One,
-		},
-		"fn": {
-			Expecting: []string{"=>>"},
-			ClosedBy:  []string{"end", "endfn"},
-			Arity:     One,
-		},
-		"class": {
-			Expecting: []string{},
-			ClosedBy:  []string{"end", "endclass"},
-			Arity:     One,
-		},
-		"for": {
-			Expecting: []string{"do"},
-			ClosedBy:  []string{"end", "endfor"},
-			Arity:     One,
-		},
-		"try": {
-			Expecting: []string{"catch", "else"},
-			ClosedBy:  []string{"end", "endtry"},
-			Arity:     Many,
-		},
-		"transaction": {
-			Expecting: []string{"catch", "else"},
-			ClosedBy:  []string{"end", "endtransaction"},
-			Arity:     Many,
-		},
-	}
-}
-
-func getDefaultBridgeTokens() map[string]BridgeTokenData {
-	return map[string]BridgeTokenData{
-		"case": {
-			Expecting: []string{"then"},
-			In:        []string{"switch"},
-			Arity:     One,
-		},
-		"=>>": {
-			Expecting: []string{"end", "enddef", "endfn"},
-			In:        []string{"def"},
-			Arity:     Many,
-		},
-		"do": {
-			Expecting: []string{"end", "endfor"},
-			In:        []string{"def", "for"},
-			Arity:     Many,
-		},
-		"then": {
-			Expecting: []string{"case", "elseif", "else", "end", "endif", "endifnot", "endswitch", "endcase"},
-			In:        []string{"if", "ifnot", "switch"},
-			Arity:     Many,
-		},
-		"elseif": {
-			Expecting: []string{"then"},
-			In:        []string{"if", "ifnot"},
-			Arity:     One,
-		},
-		"elseifnot": {
-			Expecting: []string{"then"},
-			In:        []string{"if", "ifnot"},
-			Arity:     Many,
-		},
-		"else": {
-			Expecting: []string{"end", "endif", "endifnot", "endswitch", "endcase"},
-			In:        []string{"if", "ifnot", "switch"},
-			Arity:     Many,
-		},
-		"endcase": {
-			Expecting: []string{"end", "endswitch"},
-			In:        []string{"switch"},
-			Arity:     Zero,
-		},
-		"catch": {
-			Expecting: []string{},
-			In:        []string{"try"},
-			Arity:     One,
-		},
-	}
-}
-
-func getDefaultPrefixTokens() map[string]PrefixTokenData {
-	return map[string]PrefixTokenData{
-		"return": {One},
-		"yield":  {One},
-		"const":  {One},
-		"var":    {One},
-		"val":    {One},
-		"$$":     {Zero},
-	}
-}
-
-func getDefaultDelimiterMappings() map[string][]string {
-	return map[string][]string{
-		"(": {")"},
-		"[": {"]"},
-		"{": {"}"},
+	if err := checkDefines(tokenizerRules); err != nil {
+		return nil, err
 	}
+
+	return tokenizerRules, nil
 }
 
 type DelimiterProp struct {
@@ -371,86 +600,223 @@ type DelimiterProp struct {
 	Prefix    bool
 }
 
-func getDefaultDelimiterProperties() map[string]DelimiterProp {
-	_, a, _ := calculateOperatorPrecedence("(")
-	_, b, _ := calculateOperatorPrecedence("[")
-	_, c, _ := calculateOperatorPrecedence("{")
-	return map[string]DelimiterProp{
-		"(": {a, true}, // infix=true, prefix=true
-		"[": {b, true}, // infix=true, prefix=false
-		"{": {c, true}, // infix=false, prefix=true
+// quotes returns the quote characters of the rules, which are the default
+// ones for rules made without any.
+func (rules *TokenizerRules) quotes() map[string]QuoteData {
+	if rules.Quotes == nil {
+		return defaultQuotes
+	}
+	return rules.Quotes
+}
+
+// defaultQuotes is shared by all rules without quotes, which only read it.
+// It is set by init from the default rules.
+var defaultQuotes map[string]QuoteData
+
+// checkStringRule reports an error if the rule does not declare a usable
+// quote character.
+func checkStringRule(rule StringRule) error {
+	r, size := utf8.DecodeRuneInString(rule.Text)
+	if size == 0 || size != len(rule.Text) || r == utf8.RuneError {
+		return fmt.Errorf("string rule '%s' must be a single character", rule.Text)
+	}
+	// These characters already mean something else at the start of a token.
+	if r == '@' || r == '\\' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || r == '_' {
+		return fmt.Errorf("string rule '%s' cannot be a quote character", rule.Text)
 	}
+	if rule.Raw && rule.Interpolation {
+		return fmt.Errorf("string rule '%s' cannot be both raw and interpolated", rule.Text)
+	}
+	return nil
 }
 
-func getDefaultWildcardTokens() map[string]bool {
-	return map[string]bool{
-		":": true,
+// checkMarkRule reports an error if the rule has no text or an unknown role.
+func checkMarkRule(rule MarkRule) error {
+	if rule.Text == "" {
+		return fmt.Errorf("mark rule with no text")
+	}
+	if rule.Role != "" && rule.Role != MarkSeparator && rule.Role != MarkTerminator {
+		return fmt.Errorf("mark '%s' has the unknown role '%s' (expected %s or %s)", rule.Text, rule.Role, MarkSeparator, MarkTerminator)
+	}
+	return nil
+}
+
+// checkOperatorRule reports an error if the rule does not give the operator
+// a usable precedence. A precedence of 0 means the operator cannot be used in
+// that role, so a precedence that is given needs at least one role. A rule
+// that gives none has its precedence calculated instead.
+func checkOperatorRule(rule OperatorRule) error {
+	if rule.Text == "" {
+		return fmt.Errorf("operator rule with no text")
+	}
+	if rule.Precedence == nil {
+		return nil
+	}
+	if slices.ContainsFunc(rule.Precedence[:], func(p int) bool { return p < 0 }) {
+		return fmt.Errorf("operator '%s' has a negative precedence in %v", rule.Text, *rule.Precedence)
+	}
+	if *rule.Precedence == [3]int{} {
+		return fmt.Errorf("operator '%s' has no prefix, infix or postfix precedence", rule.Text)
+	}
+	return nil
+}
+
+// Freeze marks the rules as finished. Frozen rules are only ever read, so one
+// instance can be shared by any number of Tokenizers running concurrently.
+// New freezes the rules it is given, so rules must be complete, with their
+// lookup built, before they are first used. BuildTokenLookup refuses to run on
+// frozen rules; to change them, change a Clone instead. Freezing cannot stop
+// the exported maps from being written to directly, which callers must not do.
+func (rules *TokenizerRules) Freeze() {
+	rules.frozen.Store(true)
+}
+
+// Frozen reports whether Freeze has been called on the rules.
+func (rules *TokenizerRules) Frozen() bool {
+	return rules.frozen.Load()
+}
+
+// Clone returns a deep copy of the rules that is not frozen, so that it can
+// be changed without affecting Tokenizers that use the original.
+func (rules *TokenizerRules) Clone() *TokenizerRules {
+	clone := &TokenizerRules{
+		StartTokens:         make(map[string]StartTokenData, len(rules.StartTokens)),
+		BridgeTokens:        make(map[string]BridgeTokenData, len(rules.BridgeTokens)),
+		PrefixTokens:        maps.Clone(rules.PrefixTokens),
+		DelimiterMappings:   make(map[string][]string, len(rules.DelimiterMappings)),
+		DelimiterProperties: maps.Clone(rules.DelimiterProperties),
+		WildcardTokens:      maps.Clone(rules.WildcardTokens),
+		OperatorPrecedences: maps.Clone(rules.OperatorPrecedences),
+		MarkTokens:          maps.Clone(rules.MarkTokens),
+		Quotes:              maps.Clone(rules.Quotes),
+		Defines:             maps.Clone(rules.Defines),
+		Translations:        maps.Clone(rules.Translations),
+		ExponentMarkers:     maps.Clone(rules.ExponentMarkers),
+		QuasiQuotes:         maps.Clone(rules.QuasiQuotes),
+		BasePrecedences:     maps.Clone(rules.BasePrecedences),
+		PrefixOperators:     maps.Clone(rules.PrefixOperators),
+		MarkRoles:           maps.Clone(rules.MarkRoles),
+		IdentifierChars:     maps.Clone(rules.IdentifierChars),
+		SigilRoles:          maps.Clone(rules.SigilRoles),
+	}
+	clone.CaseInsensitiveKeywords = rules.CaseInsensitiveKeywords
+	if rules.Priorities != nil {
+		clone.Priorities = make(map[string]map[string]int, len(rules.Priorities))
+		for section, priorities := range rules.Priorities {
+			clone.Priorities[section] = maps.Clone(priorities)
+		}
+	}
+	if rules.Custom != nil {
+		clone.Custom = make(map[string]map[string]bool, len(rules.Custom))
+		for section, texts := range rules.Custom {
+			clone.Custom[section] = maps.Clone(texts)
+		}
 	}
+	if rules.Style != nil {
+		style := *rules.Style
+		style.AllowTrailingMarks = maps.Clone(style.AllowTrailingMarks)
+		clone.Style = &style
+	}
+	for text, data := range rules.StartTokens {
+		clone.StartTokens[text] = StartTokenData{slices.Clone(data.Expecting), slices.Clone(data.ClosedBy), data.Arity}
+	}
+	for text, data := range rules.BridgeTokens {
+		clone.BridgeTokens[text] = BridgeTokenData{slices.Clone(data.Expecting), slices.Clone(data.In), data.Arity}
+	}
+	for text, closedBy := range rules.DelimiterMappings {
+		clone.DelimiterMappings[text] = slices.Clone(closedBy)
+	}
+	if rules.TokenLookup != nil {
+		// The lookup refers to the data of the original, so it is rebuilt
+		// from the copies. The same rules built successfully before, so this
+		// cannot fail.
+		if err := clone.BuildTokenLookup(); err != nil {
+			panic(fmt.Sprintf("cannot rebuild cloned rules: %v", err))
+		}
+	}
+	return clone
 }
 
 // BuildTokenLookup creates the precomputed lookup map for efficient token matching.
-// Returns an error if a token is defined in multiple rules.
+// Returns an error if a token is defined in multiple rules, or if the rules
+// are frozen.
 func (rules *TokenizerRules) BuildTokenLookup() error {
+	if rules.Frozen() {
+		return fmt.Errorf("rules are frozen because they are in use; change a Clone of them instead")
+	}
 	rules.TokenLookup = make(map[string]CustomRuleEntry)
 	tokenSources := make(map[string]string) // Track which rule type defined each token
 
 	// Helper function to add a token and check for duplicates
 	addToken := func(token string, ruleType CustomRuleType, ruleTypeName string, data interface{}) error {
 		if existingSource, exists := tokenSources[token]; exists {
-			return fmt.Errorf("token '%s' is defined in both %s and %s rules", token, existingSource, ruleTypeName)
+			existing, priority := rules.Priority(existingSource, token), rules.Priority(ruleTypeName, token)
+			switch {
+			case priority < existing:
+				return nil // The existing rule wins
+			case priority == existing && priority == 0:
+				return fmt.Errorf("token '%s' is defined in both %s and %s rules", token, existingSource, ruleTypeName)
+			case priority == existing:
+				return fmt.Errorf("token '%s' is defined in both %s and %s rules with the same priority %d", token, existingSource, ruleTypeName, priority)
+			}
 		}
 		tokenSources[token] = ruleTypeName
 		rules.TokenLookup[token] = CustomRuleEntry{
-			Type: ruleType,
-			Data: data,
+			Type:   ruleType,
+			Data:   data,
+			Custom: rules.Custom[ruleTypeName][token],
 		}
 		return nil
 	}
 
+	// The tokens are added in sorted order, so that the conflict reported is
+	// the same every time.
+
 	// Add wildcard tokens
-	for token := range rules.WildcardTokens {
+	for _, token := range sortedKeys(rules.WildcardTokens) {
 		if err := addToken(token, CustomWildcard, "wildcard", nil); err != nil {
 			return err
 		}
 	}
 
 	// Add start tokens
-	for token, data := range rules.StartTokens {
-		if err := addToken(token, CustomStart, "start", data); err != nil {
+	for _, token := range sortedKeys(rules.StartTokens) {
+		if err := addToken(token, CustomStart, "start", rules.StartTokens[token]); err != nil {
 			return err
 		}
 	}
 
 	// Add bridge tokens
-	for token, data := range rules.BridgeTokens {
-		if err := addToken(token, CustomBridge, "bridge", data); err != nil {
+	for _, token := range sortedKeys(rules.BridgeTokens) {
+		if err := addToken(token, CustomBridge, "bridge", rules.BridgeTokens[token]); err != nil {
 			return err
 		}
 	}
 
 	// Add prefix tokens
-	for token, data := range rules.PrefixTokens {
-		if err := addToken(token, CustomPrefix, "prefix", data); err != nil {
+	for _, token := range sortedKeys(rules.PrefixTokens) {
+		if err := addToken(token, CustomPrefix, "prefix", rules.PrefixTokens[token]); err != nil {
 			return err
 		}
 	}
 
 	// Add mark tokens
-	for token := range rules.MarkTokens {
-		if err := addToken(token, CustomMark, "mark", nil); err != nil {
+	for _, token := range sortedKeys(rules.MarkTokens) {
+		if err := addToken(token, CustomMark, "mark", rules.MarkRoles[token]); err != nil {
 			return err
 		}
 	}
 
 	// Add operator tokens
-	for token, precedence := range rules.OperatorPrecedences {
-		if err := addToken(token, CustomOperator, "operator", precedence); err != nil {
+	for _, token := range sortedKeys(rules.OperatorPrecedences) {
+		if err := addToken(token, CustomOperator, "operator", rules.OperatorPrecedences[token]); err != nil {
 			return err
 		}
 	}
 
 	// Add open delimiter tokens
-	for token, closedBy := range rules.DelimiterMappings {
+	for _, token := range sortedKeys(rules.DelimiterMappings) {
+		closedBy := rules.DelimiterMappings[token]
 		props := rules.DelimiterProperties[token]
 		delimiterData := struct {
 			ClosedBy  []string
@@ -466,80 +832,173 @@ func (rules *TokenizerRules) BuildTokenLookup() error {
 		}
 	}
 
-	// Add close delimiter tokens (derived from closed_by fields)
+	// Add sigil tokens
+	for _, token := range sortedKeys(rules.SigilRoles) {
+		if err := addToken(token, CustomSigil, "sigil", rules.SigilRoles[token]); err != nil {
+			return err
+		}
+	}
+
+	// Add define tokens
+	for _, token := range sortedKeys(rules.Defines) {
+		if err := addToken(token, CustomDefine, "define", rules.Defines[token]); err != nil {
+			return err
+		}
+	}
+
+	// Add close delimiter tokens (derived from closed_by fields), each with
+	// the sorted list of brackets that it closes
 	// Note: These can legitimately appear multiple times from different brackets
-	closeDelimiters := make(map[string]bool)
-	for _, closedByList := range rules.DelimiterMappings {
+	openedBy := make(map[string][]string)
+	for opener, closedByList := range rules.DelimiterMappings {
 		for _, closer := range closedByList {
-			if !closeDelimiters[closer] {
-				closeDelimiters[closer] = true
-				// Don't check for duplicates for close delimiters since they're derived
-				rules.TokenLookup[closer] = CustomRuleEntry{
-					Type: CustomCloseDelimiter,
-					Data: nil,
-				}
+			if !slices.Contains(openedBy[closer], opener) {
+				openedBy[closer] = append(openedBy[closer], opener)
 			}
 		}
 	}
+	for closer, openers := range openedBy {
+		slices.Sort(openers)
+		// Don't check for duplicates for close delimiters since they're derived
+		rules.TokenLookup[closer] = CustomRuleEntry{
+			Type:   CustomCloseDelimiter,
+			Data:   openers,
+			Custom: slices.ContainsFunc(openers, func(opener string) bool { return rules.Custom["bracket"][opener] }),
+		}
+	}
 
-	// Add end tokens (derived from start token closed_by fields)
+	// Add end tokens (derived from start token closed_by fields), each with
+	// the sorted list of start tokens that it closes
 	// Note: These can legitimately appear multiple times from different start tokens
-	endTokens := make(map[string]bool)
-	for _, startData := range rules.StartTokens {
+	closes := make(map[string][]string)
+	for start, startData := range rules.StartTokens {
 		for _, endToken := range startData.ClosedBy {
-			if !endTokens[endToken] {
-				endTokens[endToken] = true
-				// Don't check for duplicates for end tokens since they're derived
-				rules.TokenLookup[endToken] = CustomRuleEntry{
-					Type: CustomEnd,
-					Data: nil,
-				}
+			if !slices.Contains(closes[endToken], start) {
+				closes[endToken] = append(closes[endToken], start)
 			}
 		}
 	}
+	for endToken, starts := range closes {
+		slices.Sort(starts)
+		// Don't check for duplicates for end tokens since they're derived
+		rules.TokenLookup[endToken] = CustomRuleEntry{
+			Type:   CustomEnd,
+			Data:   starts,
+			Custom: slices.ContainsFunc(starts, func(start string) bool { return rules.Custom["start"][start] }),
+		}
+	}
+
+	// Add translations last, as copies of the entries of the tokens that
+	// they stand for.
+	for _, text := range sortedKeys(rules.Translations) {
+		as := rules.Translations[text]
+		entry, ok := rules.TokenLookup[as]
+		switch {
+		case !ok:
+			return fmt.Errorf("translation '%s' is for '%s', which is not a token of the rules", text, as)
+		case entry.Alias != "":
+			return fmt.Errorf("translation '%s' is for '%s', which is itself a translation", text, as)
+		case entry.Type == CustomDefine:
+			return fmt.Errorf("translation '%s' is for '%s', which is a define rule", text, as)
+		}
+		if existing, clash := rules.TokenLookup[text]; clash {
+			return fmt.Errorf("translation '%s' is already a %s token", text, existing.Type)
+		}
+		entry.Alias = as
+		entry.Custom = true
+		rules.TokenLookup[text] = entry
+	}
 
+	rules.symbols = symbolTexts(rules.TokenLookup)
+	rules.signMarks = signMarkTexts(rules.MarkTokens)
+	rules.longestSignToken = longestSignToken(rules.TokenLookup)
+	rules.quasiOpens = quasiOpenTexts(rules.QuasiQuotes)
+	rules.keywordsByFold = nil
+	if rules.CaseInsensitiveKeywords {
+		rules.keywordsByFold = keywordFolds(rules.TokenLookup)
+	}
 	return nil
 }
 
-func updateOperatorPrecedence(m map[string][3]int, operator string) {
-	prefix, infix, postfix := calculateOperatorPrecedence(operator)
-	m[operator] = [3]int{prefix, infix, postfix}
+// keywordFolds returns the start, end, bridge and prefix keywords of the
+// lookup by their lower case form. The keys are sorted so that, of two
+// keywords that differ only in case, the same one is always chosen.
+func keywordFolds(lookup map[string]CustomRuleEntry) map[string]string {
+	folds := make(map[string]string)
+	for _, text := range sortedKeys(lookup) {
+		switch lookup[text].Type {
+		case CustomStart, CustomEnd, CustomBridge, CustomPrefix:
+			fold := strings.ToLower(text)
+			if _, seen := folds[fold]; !seen {
+				folds[fold] = text
+			}
+		}
+	}
+	return folds
 }
 
-// calculateOperatorPrecedence calculates precedence based on rules in operators.md
-func calculateOperatorPrecedence(operator string) (prefix, infix, postfix int) {
-	if len(operator) == 0 {
-		return 0, 0, 0
+// symbolTexts returns the texts of the lookup that the scanner would not read
+// as one token, as they are neither an identifier, nor a run of sign
+// characters, nor a single character. They are sorted longest first, so that
+// the longest one that matches can be taken.
+func symbolTexts(lookup map[string]CustomRuleEntry) []string {
+	var symbols []string
+	for text := range lookup {
+		if utf8.RuneCountInString(text) > 1 && identifierRegex.FindString(text) != text && operatorRegex.FindString(text) != text {
+			symbols = append(symbols, text)
+		}
 	}
+	slices.SortFunc(symbols, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	return symbols
+}
 
-	firstChar := rune(operator[0])
-	basePrecedence, exists := baseOperatorPrecedence[firstChar]
-	if !exists {
-		// Fallback for unknown operators
-		basePrecedence = 1000
+// signMarkTexts returns the marks that are runs of sign characters, longest
+// first.
+func signMarkTexts(marks map[string]bool) []string {
+	var signMarks []string
+	for _, text := range sortedKeys(marks) {
+		if operatorRegex.FindString(text) == text {
+			signMarks = append(signMarks, text)
+		}
 	}
+	slices.SortStableFunc(signMarks, func(a, b string) int {
+		return len(b) - len(a)
+	})
+	return signMarks
+}
 
-	// If the first character is repeated, subtract 1
-	if len(operator) > 1 && rune(operator[1]) == firstChar {
-		basePrecedence--
+// longestSignToken returns the length of the longest text of the lookup that
+// is a run of sign characters, or 0 if there is none.
+func longestSignToken(lookup map[string]CustomRuleEntry) int {
+	longest := 0
+	for text := range lookup {
+		if len(text) > longest && operatorRegex.FindString(text) == text {
+			longest = len(text)
+		}
 	}
+	return longest
+}
 
-	// Role adjustments as per updated operators.md:
-	// - Only minus ("-") has prefix capability enabled (unary negation)
-	// - All operators have infix capability (add 2000 to base precedence)
-	// - No operators have postfix capability (set to 0)
+// Priority returns the priority of the rule for text in the section, which is
+// 0 unless it was given one.
+func (rules *TokenizerRules) Priority(section, text string) int {
+	return rules.Priorities[section][text]
+}
 
-	if operator == "-" || operator == "+" {
-		// Unary minus: enabled for both prefix and infix
-		prefix = basePrecedence
-		infix = basePrecedence + 2000
-		postfix = 0
-	} else {
-		// All other operators: only infix enabled
-		prefix = 0
-		infix = basePrecedence + 2000
-		postfix = 0
+// SetPriority sets the priority of the rule for text in the section, which
+// settles a conflict with a rule for the same text in another section. Call
+// BuildTokenLookup afterwards for it to take effect.
+func (rules *TokenizerRules) SetPriority(section, text string, priority int) {
+	if rules.Priorities == nil {
+		rules.Priorities = make(map[string]map[string]int)
 	}
-
-	return prefix, infix, postfix
+	if rules.Priorities[section] == nil {
+		rules.Priorities[section] = make(map[string]int)
+	}
+	rules.Priorities[section][text] = priority
 }
@@ -0,0 +1,265 @@
+package tokenizer
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewFreezesRules(t *testing.T) {
+	rules := DefaultRules()
+	if rules.Frozen() {
+		t.Fatalf("expected new rules not to be frozen")
+	}
+	New("x", &Options{Rules: rules})
+	if !rules.Frozen() {
+		t.Fatalf("expected New to freeze the rules")
+	}
+	if err := rules.BuildTokenLookup(); err == nil {
+		t.Errorf("expected an error rebuilding frozen rules")
+	}
+}
+
+func TestCloneRules(t *testing.T) {
+	rules := DefaultRules()
+	rules.Freeze()
+	clone := rules.Clone()
+	if clone.Frozen() {
+		t.Fatalf("expected the clone not to be frozen")
+	}
+	if RulesFingerprint(clone) != RulesFingerprint(rules) {
+		t.Errorf("expected the clone to have the same fingerprint")
+	}
+	if !reflect.DeepEqual(clone.TokenLookup, rules.TokenLookup) {
+		t.Errorf("expected the clone to have the same lookup")
+	}
+
+	// Changing the clone leaves the original alone.
+	clone.StartTokens["unless"] = StartTokenData{ClosedBy: []string{"endunless"}}
+	ifData := clone.StartTokens["if"]
+	ifData.ClosedBy[0] = "fi"
+	if err := clone.BuildTokenLookup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := rules.TokenLookup["unless"]; ok {
+		t.Errorf("expected the original lookup to be unchanged")
+	}
+	if slices.Contains(rules.StartTokens["if"].ClosedBy, "fi") {
+		t.Errorf("expected the original closers to be unchanged, got %v", rules.StartTokens["if"].ClosedBy)
+	}
+}
+
+func TestDefaultRulesYAML(t *testing.T) {
+	rules := DefaultRules()
+	if rules.Custom != nil {
+		t.Errorf("expected the default rules not to be custom, got %v", rules.Custom)
+	}
+	if rules.OperatorPrecedences["in"] != [3]int{0, 3000, 0} || !rules.MarkTokens[","] {
+		t.Errorf("expected the defaults to include the operator in and the mark ,")
+	}
+
+	// Applying the defaults to themselves changes nothing.
+	file, err := ParseRulesFile(DefaultRulesYAML())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	applied, err := ApplyRulesToDefaults(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if RulesFingerprint(applied) != RulesFingerprint(rules) {
+		t.Errorf("expected the rules file to give the default rules")
+	}
+
+	// The caller gets its own copy of the file.
+	DefaultRulesYAML()[0] = 'x'
+	if DefaultRulesYAML()[0] == 'x' {
+		t.Errorf("expected the embedded rules file to be unchanged")
+	}
+}
+
+func TestSharedRulesConcurrentUse(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Define: []DefineRule{{Text: "unless", As: "if not"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	input := "unless x then f(y) endif"
+	expected, err := New(input, &Options{Rules: rules}).TokenizeValues()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				values, err := New(input, &Options{Rules: rules}).TokenizeValues()
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				if !reflect.DeepEqual(values, expected) {
+					t.Errorf("tokenizers sharing rules produced different tokens")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStringRules(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		String: []StringRule{
+			{Text: `"`, Interpolation: true},
+			{Text: "`", Raw: true},
+			{Text: "|"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		input     string
+		tokenType TokenType
+		value     string
+	}{
+		{`"a\(b)"`, InterpolatedStringTokenType, ""},
+		{"`C:\\dir`", StringLiteralTokenType, `C:\dir`},
+		{`|a\(b)\||`, StringLiteralTokenType, `a\(b)|`},
+		{`'`, UnclassifiedTokenType, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := New(tt.input, &Options{Rules: rules}).Tokenize()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tokens[0].Type != tt.tokenType {
+				t.Fatalf("expected type %s, got %s", tt.tokenType, tokens[0].Type)
+			}
+			if tt.value != "" && *tokens[0].Value != tt.value {
+				t.Errorf("expected value %q, got %q", tt.value, *tokens[0].Value)
+			}
+		})
+	}
+
+	for _, rule := range []StringRule{{Text: "ab"}, {Text: "@"}, {Text: "x"}, {Text: "|", Interpolation: true, Raw: true}} {
+		if _, err := ApplyRulesToDefaults(&RulesFile{String: []StringRule{rule}}); err == nil {
+			t.Errorf("expected an error for string rule %+v", rule)
+		}
+	}
+}
+
+func TestRulesWithoutQuotesUseDefaults(t *testing.T) {
+	rules := DefaultRules()
+	rules.Quotes = nil
+	tokens, err := New(`"a"`, &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens[0].Type != StringLiteralTokenType {
+		t.Errorf("expected a string, got %s", tokens[0].Type)
+	}
+}
+
+func TestRulePriorities(t *testing.T) {
+	colon := func(operatorPriority, wildcardPriority int) (*TokenizerRules, error) {
+		return ApplyRulesToDefaults(&RulesFile{
+			Operator: []OperatorRule{{Text: ":", Precedence: &[3]int{0, 900, 0}, Priority: operatorPriority}},
+			Wildcard: []WildcardRule{{Text: ":", Priority: wildcardPriority}},
+		})
+	}
+
+	for _, test := range []struct {
+		operatorPriority, wildcardPriority int
+		expected                           CustomRuleType
+	}{
+		{1, 0, CustomOperator},
+		{0, 1, CustomWildcard},
+		{-1, 0, CustomWildcard},
+		{5, 2, CustomOperator},
+	} {
+		rules, err := colon(test.operatorPriority, test.wildcardPriority)
+		if err != nil {
+			t.Fatalf("unexpected error for priorities %d and %d: %v", test.operatorPriority, test.wildcardPriority, err)
+		}
+		if got := rules.TokenLookup[":"].Type; got != test.expected {
+			t.Errorf("expected %s to win with priorities %d and %d, got %s", test.expected, test.operatorPriority, test.wildcardPriority, got)
+		}
+		// The winner survives cloning, which rebuilds the lookup.
+		if got := rules.Clone().TokenLookup[":"].Type; got != test.expected {
+			t.Errorf("expected the clone to keep %s, got %s", test.expected, got)
+		}
+	}
+
+	// Ties are still errors, naming the priority when there is one.
+	if _, err := colon(0, 0); err == nil || err.Error() != "token ':' is defined in both wildcard and operator rules" {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+	if _, err := colon(3, 3); err == nil || !strings.Contains(err.Error(), "with the same priority 3") {
+		t.Errorf("expected a same priority error, got %v", err)
+	}
+}
+
+func TestOperatorsOutsideSignCharacters(t *testing.T) {
+	var operators []OperatorRule
+	for _, text := range []string{"<#", "→→", "@@", "@", "#>", "a#b"} {
+		operators = append(operators, OperatorRule{Text: text, Precedence: &[3]int{0, 500, 0}})
+	}
+	rules, err := ApplyRulesToDefaults(&RulesFile{Operator: operators})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, err := New(`x <# y →→ z @@ w @ v #> u a#b @tag"s" @"r" <= q`, &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, token := range tokens {
+		got = append(got, string(token.Type)+" "+token.Text)
+	}
+	expected := []string{
+		"V x", "O <#", "V y", "O →→", "V z", "O @@", "V w", "O @", "V v", "O #>", "V u",
+		"O a#b", `s @tag"s"`, `s @"r"`, "O <=", "V q",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// Without a rule for it, an '@' that starts no string is unclassified.
+	tokens, err = New("@x @tag", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got = got[:0]
+	for _, token := range tokens {
+		got = append(got, string(token.Type)+" "+token.Text)
+	}
+	if expected := []string{"U @", "V x", "U @", "V tag"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// A rule can make it a mark instead, while @ and a quote is still a string.
+	rules, err = ApplyRulesToDefaults(&RulesFile{Mark: []MarkRule{{Text: "@"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, err = New(`f(x @ y) @"s"`, &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got = got[:0]
+	for _, token := range tokens {
+		got = append(got, string(token.Type)+" "+token.Text)
+	}
+	if expected := []string{"V f", "[ (", "V x", "M @", "V y", "] )", `s @"s"`}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
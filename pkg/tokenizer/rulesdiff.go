@@ -0,0 +1,224 @@
+package tokenizer
+
+import (
+	"reflect"
+	"sort"
+)
+
+// RuleChange describes how one token's rule differs between two rule sets.
+type RuleChange struct {
+	Section string      `json:"section"`       // The rules file section, e.g. "start"
+	Token   string      `json:"token"`         // The token text
+	Change  string      `json:"change"`        // "added", "removed" or "changed"
+	Old     interface{} `json:"old,omitempty"` // The old rule, unless added
+	New     interface{} `json:"new,omitempty"` // The new rule, unless removed
+}
+
+// The views below give each kind of rule a stable JSON form for reporting.
+
+type bracketView struct {
+	ClosedBy []string `json:"closed_by"`
+	Infix    int      `json:"infix"`
+	Prefix   bool     `json:"prefix"`
+	Priority int      `json:"priority,omitempty"`
+}
+
+type prefixView struct {
+	Arity    Arity `json:"arity"`
+	Priority int   `json:"priority,omitempty"`
+}
+
+type startView struct {
+	Expecting []string `json:"expecting"`
+	ClosedBy  []string `json:"closed_by"`
+	Arity     Arity    `json:"arity"`
+	Priority  int      `json:"priority,omitempty"`
+}
+
+type bridgeView struct {
+	Expecting []string `json:"expecting"`
+	In        []string `json:"in"`
+	Arity     Arity    `json:"arity"`
+	Priority  int      `json:"priority,omitempty"`
+}
+
+type operatorView struct {
+	Precedence [3]int `json:"precedence"`
+	Priority   int    `json:"priority,omitempty"`
+}
+
+type stringView struct {
+	Interpolation bool `json:"interpolation"`
+	Raw           bool `json:"raw"`
+}
+
+type defineView struct {
+	As       string `json:"as"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+type translateView struct {
+	As string `json:"as"`
+}
+
+type markView struct {
+	Role     string `json:"role,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+type sigilView struct {
+	Role     string `json:"role"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+type quasiQuoteView struct {
+	Hole string `json:"hole"`
+}
+
+type identifierView struct {
+	Where string `json:"where"`
+}
+
+// presentView is used for the sections where a rule has no attributes other
+// than its priority.
+type presentView struct {
+	Priority int `json:"priority,omitempty"`
+}
+
+// ruleSection holds the rules of one section, keyed by token text.
+type ruleSection struct {
+	name  string
+	rules map[string]interface{}
+}
+
+// DiffRules compares two sets of effective rules and reports the tokens that
+// were added, removed or changed in each section. Because whole rule sets are
+// compared, reordering a rules file produces no changes. The changes are
+// sorted by section, in rules file order, and then by token.
+func DiffRules(old, new *TokenizerRules) []RuleChange {
+	oldSections := ruleSections(old)
+	newSections := ruleSections(new)
+
+	var changes []RuleChange
+	for i, oldSection := range oldSections {
+		newSection := newSections[i]
+		for _, token := range unionKeys(oldSection.rules, newSection.rules) {
+			oldRule, inOld := oldSection.rules[token]
+			newRule, inNew := newSection.rules[token]
+			change := RuleChange{Section: oldSection.name, Token: token}
+			switch {
+			case !inOld:
+				change.Change = "added"
+				change.New = newRule
+			case !inNew:
+				change.Change = "removed"
+				change.Old = oldRule
+			case !reflect.DeepEqual(oldRule, newRule):
+				change.Change = "changed"
+				change.Old = oldRule
+				change.New = newRule
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// ruleSections breaks the rules into sections, in rules file order.
+func ruleSections(rules *TokenizerRules) []ruleSection {
+	bracket := map[string]interface{}{}
+	for text, closedBy := range rules.DelimiterMappings {
+		props := rules.DelimiterProperties[text]
+		bracket[text] = bracketView{nonNil(closedBy), props.InfixPrec, props.Prefix, rules.Priority("bracket", text)}
+	}
+	prefix := map[string]interface{}{}
+	for text, data := range rules.PrefixTokens {
+		prefix[text] = prefixView{data.Arity, rules.Priority("prefix", text)}
+	}
+	start := map[string]interface{}{}
+	for text, data := range rules.StartTokens {
+		start[text] = startView{nonNil(data.Expecting), nonNil(data.ClosedBy), data.Arity, rules.Priority("start", text)}
+	}
+	bridge := map[string]interface{}{}
+	for text, data := range rules.BridgeTokens {
+		bridge[text] = bridgeView{nonNil(data.Expecting), nonNil(data.In), data.Arity, rules.Priority("bridge", text)}
+	}
+	wildcard := map[string]interface{}{}
+	for text := range rules.WildcardTokens {
+		wildcard[text] = presentView{rules.Priority("wildcard", text)}
+	}
+	operator := map[string]interface{}{}
+	for text, precedence := range rules.OperatorPrecedences {
+		operator[text] = operatorView{precedence, rules.Priority("operator", text)}
+	}
+	mark := map[string]interface{}{}
+	for text := range rules.MarkTokens {
+		mark[text] = markView{rules.MarkRoles[text], rules.Priority("mark", text)}
+	}
+	str := map[string]interface{}{}
+	for text, data := range rules.quotes() {
+		str[text] = stringView{data.Interpolation, data.Raw}
+	}
+	define := map[string]interface{}{}
+	for text, replacement := range rules.Defines {
+		define[text] = defineView{replacement, rules.Priority("define", text)}
+	}
+	translate := map[string]interface{}{}
+	for text, as := range rules.Translations {
+		translate[text] = translateView{as}
+	}
+	quasiQuote := map[string]interface{}{}
+	for open, hole := range rules.QuasiQuotes {
+		quasiQuote[open] = quasiQuoteView{hole}
+	}
+	identifier := map[string]interface{}{}
+	for char, where := range rules.IdentifierChars {
+		identifier[char] = identifierView{where}
+	}
+	sigil := map[string]interface{}{}
+	for text, role := range rules.SigilRoles {
+		sigil[text] = sigilView{role, rules.Priority("sigil", text)}
+	}
+	return []ruleSection{
+		{"bracket", bracket},
+		{"prefix", prefix},
+		{"start", start},
+		{"bridge", bridge},
+		{"wildcard", wildcard},
+		{"operator", operator},
+		{"mark", mark},
+		{"string", str},
+		{"define", define},
+		{"translate", translate},
+		{"quasi_quote", quasiQuote},
+		{"identifier", identifier},
+		{"sigil", sigil},
+	}
+}
+
+// nonNil returns an empty slice in place of nil, so that an omitted list and
+// an empty one compare and print the same.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// unionKeys returns the keys of both maps in sorted order.
+func unionKeys(a, b map[string]interface{}) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []map[string]interface{}{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRules(t *testing.T) {
+	apply := func(rulesFile *RulesFile) *TokenizerRules {
+		rules, err := ApplyRulesToDefaults(rulesFile)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return rules
+	}
+
+	old := apply(&RulesFile{
+		Mark:  []MarkRule{{Text: ";"}, {Text: ","}},
+		Start: []StartRule{{Text: "loop", ClosedBy: []string{"endloop"}}, {Text: "gone", ClosedBy: []string{"endgone"}}},
+	})
+	new := apply(&RulesFile{
+		Mark:  []MarkRule{{Text: ","}, {Text: ";"}},
+		Start: []StartRule{{Text: "loop", ClosedBy: []string{"endloop", "end"}}, {Text: "while", ClosedBy: []string{"endwhile"}}},
+	})
+
+	changes := DiffRules(old, new)
+	var summary [][3]string
+	for _, change := range changes {
+		summary = append(summary, [3]string{change.Section, change.Token, change.Change})
+	}
+	expected := [][3]string{
+		{"start", "gone", "removed"},
+		{"start", "loop", "changed"},
+		{"start", "while", "added"},
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Expected changes %v, got %v", expected, summary)
+	}
+
+	loop := changes[1]
+	if got := loop.New.(startView).ClosedBy; !reflect.DeepEqual(got, []string{"endloop", "end"}) {
+		t.Errorf("Expected the new closed_by list, got %v", got)
+	}
+
+	if changes := DiffRules(old, old); len(changes) != 0 {
+		t.Errorf("Expected no changes between identical rules, got %v", changes)
+	}
+}
+
+func TestDiffRulesReportsPriorities(t *testing.T) {
+	old := DefaultRules()
+	new := DefaultRules().Clone()
+	new.SetPriority("mark", ";", 2)
+	changes := DiffRules(old, new)
+	if len(changes) != 1 || changes[0].Token != ";" || changes[0].New.(markView).Priority != 2 {
+		t.Errorf("Expected the priority of ';' to change, got %+v", changes)
+	}
+}
@@ -0,0 +1,15 @@
+package tokenizer
+
+// The origins of a rule.
+const (
+	OriginDefault = "default" // The rule is one of the default rules
+	OriginCustom  = "custom"  // The rule came from a rules file
+)
+
+// RuleSource says which rule classified a token, so that the authors of a
+// rules file can see which of two conflicting rules won. It is only attached
+// when Options.AnnotateRuleSource is set.
+type RuleSource struct {
+	Section string `json:"section"` // The kind of rule, as named in the rules file, or "end" or "close" for closed_by entries
+	Origin  string `json:"origin"`  // OriginDefault or OriginCustom
+}
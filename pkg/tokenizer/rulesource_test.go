@@ -0,0 +1,61 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnnotateRuleSource(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Start:    []StartRule{{Text: "loop", ClosedBy: []string{"endloop"}}},
+		Operator: []OperatorRule{{Text: "<>", Precedence: &[3]int{0, 500, 0}}},
+		Define:   []DefineRule{{Text: "forever", As: "loop"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tokens, err := New("loop x <> y + z; forever (w) endloop", &Options{Rules: rules, AnnotateRuleSource: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var got []string
+	for _, token := range tokens {
+		source := "-"
+		if token.RuleSource != nil {
+			source = token.RuleSource.Section + "/" + token.RuleSource.Origin
+		}
+		got = append(got, token.Text+" "+source)
+	}
+	expected := []string{
+		"loop start/custom",
+		"x -",
+		"<> operator/custom",
+		"y -",
+		"+ operator/default",
+		"z -",
+		"; mark/default",
+		"loop define/custom",
+		"( bracket/default",
+		"w -",
+		") close/default",
+		"endloop end/custom",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected rule sources %v, got %v", expected, got)
+	}
+
+	// Without the option no source is recorded.
+	tokens, err = New("x + y", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tokens[1].RuleSource != nil {
+		t.Errorf("Expected no rule source without AnnotateRuleSource, got %v", tokens[1].RuleSource)
+	}
+
+	// The origins survive cloning.
+	clone := rules.Clone()
+	if !clone.TokenLookup["<>"].Custom || clone.TokenLookup["+"].Custom {
+		t.Errorf("Expected the clone to keep the origins of its rules")
+	}
+}
@@ -0,0 +1,154 @@
+package tokenizer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// SemanticLegend describes how tokens are reported as LSP semantic tokens.
+// The token types and modifiers are the legend a language server announces
+// to its client, and Mapping gives the semantic class of each kind of token
+// as a type optionally followed by dot-separated modifiers, for example
+// "keyword" or "variable.readonly". Tokens of kinds that are not mapped are
+// left out.
+type SemanticLegend struct {
+	TokenTypes     []string             `json:"token_types"`
+	TokenModifiers []string             `json:"token_modifiers"`
+	Mapping        map[TokenType]string `json:"mapping"`
+}
+
+// DefaultSemanticLegend returns a legend that uses only standard LSP token
+// types.
+func DefaultSemanticLegend() *SemanticLegend {
+	return &SemanticLegend{
+		TokenTypes:     []string{"keyword", "variable", "number", "string", "operator", "comment"},
+		TokenModifiers: []string{},
+		Mapping: map[TokenType]string{
+			StartTokenType:              "keyword",
+			EndTokenType:                "keyword",
+			BridgeTokenType:             "keyword",
+			PrefixTokenType:             "keyword",
+			VariableTokenType:           "variable",
+			NumericLiteralTokenType:     "number",
+			StringLiteralTokenType:      "string",
+			MultiLineStringTokenType:    "string",
+			InterpolatedStringTokenType: "string",
+			OperatorTokenType:           "operator",
+			CommentTokenType:            "comment",
+		},
+	}
+}
+
+// Check reports an error if the mapping refers to a type or modifier that is
+// not in the legend.
+func (legend *SemanticLegend) Check() error {
+	_, err := legend.resolve()
+	return err
+}
+
+// semanticClass is a resolved entry of a legend's mapping.
+type semanticClass struct {
+	tokenType uint32
+	modifiers uint32 // Bit set of modifier indexes
+}
+
+// resolve checks the legend's mapping against its types and modifiers, and
+// returns it as indexes.
+func (legend *SemanticLegend) resolve() (map[TokenType]semanticClass, error) {
+	classes := make(map[TokenType]semanticClass, len(legend.Mapping))
+	for _, tokenType := range sortedKeys(legend.Mapping) {
+		parts := strings.Split(legend.Mapping[tokenType], ".")
+		typeIndex := slices.Index(legend.TokenTypes, parts[0])
+		if typeIndex < 0 {
+			return nil, fmt.Errorf("token type '%s' for '%s' is not in the legend", parts[0], tokenType)
+		}
+		resolved := semanticClass{tokenType: uint32(typeIndex)}
+		for _, modifier := range parts[1:] {
+			modifierIndex := slices.Index(legend.TokenModifiers, modifier)
+			if modifierIndex < 0 {
+				return nil, fmt.Errorf("token modifier '%s' for '%s' is not in the legend", modifier, tokenType)
+			}
+			if modifierIndex >= 32 {
+				return nil, fmt.Errorf("token modifier '%s' is beyond the 32 that can be encoded", modifier)
+			}
+			resolved.modifiers |= 1 << modifierIndex
+		}
+		classes[tokenType] = resolved
+	}
+	return classes, nil
+}
+
+// SemanticTokens encodes the tokens taken from input as the LSP semantic
+// tokens integer array: five integers per token giving the change of line,
+// the change of start character, the length, the type and the modifiers.
+// Lines and characters count from 0 and characters are UTF-16 code units, as
+// LSP expects. A token that spans several lines, such as a multi-line string,
+// is split into one entry per line. A nil legend selects the default one. The
+// columns of the tokens' spans must count characters, as they do by default.
+func SemanticTokens(input string, tokens []*Token, legend *SemanticLegend) ([]uint32, error) {
+	return SemanticTokensWithPolicy(input, tokens, legend, ColumnRunes)
+}
+
+// SemanticTokensWithPolicy encodes the tokens taken from input, whose columns
+// count what the policy says, as SemanticTokens does.
+func SemanticTokensWithPolicy(input string, tokens []*Token, legend *SemanticLegend, policy ColumnPolicy) ([]uint32, error) {
+	if legend == nil {
+		legend = DefaultSemanticLegend()
+	}
+	classes, err := legend.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := NewLineIndexWithPolicy(input, policy)
+	var data []uint32
+	previousLine, previousChar := 0, 0
+	emit := func(line, char, length int, class semanticClass) {
+		deltaChar := char
+		if line == previousLine {
+			deltaChar = char - previousChar
+		}
+		data = append(data, uint32(line-previousLine), uint32(deltaChar), uint32(length), class.tokenType, class.modifiers)
+		previousLine, previousChar = line, char
+	}
+
+	for _, token := range tokens {
+		class, ok := classes[token.Type]
+		if !ok {
+			continue
+		}
+		start, end := lines.Offset(token.Span.Start), lines.Offset(token.Span.End)
+		if start < 0 || end < start {
+			// The span does not describe the input, for example after a
+			// ###line directive, so the token cannot be placed.
+			continue
+		}
+		for line := token.Span.Start.Line; line <= token.Span.End.Line; line++ {
+			lineStart := lines.LineStarts[line-1]
+			lineEnd := len(input)
+			if line < len(lines.LineStarts) {
+				lineEnd = lines.LineStarts[line] - 1 // The offset of the newline
+			}
+			from, to := max(start, lineStart), min(end, lineEnd)
+			if to > from {
+				emit(line-1, utf16Length(input[lineStart:from]), utf16Length(input[from:to]), class)
+			}
+		}
+	}
+	return data, nil
+}
+
+// utf16Length returns the number of UTF-16 code units needed for text.
+func utf16Length(text string) int {
+	n := 0
+	for _, r := range text {
+		if utf8.RuneLen(r) == 4 {
+			n += 2 // A surrogate pair
+		} else {
+			n++
+		}
+	}
+	return n
+}
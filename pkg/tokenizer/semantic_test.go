@@ -0,0 +1,73 @@
+package tokenizer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	input := "if x then\n  \"é😀\" + 12\nendif"
+	tokens, err := New(input, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := SemanticTokens(input, tokens, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// keyword=0, variable=1, number=2, string=3, operator=4
+	expected := []uint32{
+		0, 0, 2, 0, 0, // if
+		0, 3, 1, 1, 0, // x
+		0, 2, 4, 0, 0, // then
+		1, 2, 5, 3, 0, // "é😀" is 5 UTF-16 code units
+		0, 6, 1, 4, 0, // +
+		0, 2, 2, 2, 0, // 12
+		1, 0, 5, 0, 0, // endif
+	}
+	if !slices.Equal(data, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, data)
+	}
+}
+
+func TestSemanticTokensMultiline(t *testing.T) {
+	input := "x := \"\"\"\n  ab\n  \"\"\""
+	tokens, err := New(input, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	legend := &SemanticLegend{
+		TokenTypes:     []string{"string"},
+		TokenModifiers: []string{"readonly", "static"},
+		Mapping:        map[TokenType]string{MultiLineStringTokenType: "string.static"},
+	}
+	data, err := SemanticTokens(input, tokens, legend)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []uint32{
+		0, 5, 3, 0, 2, // The opening quotes
+		1, 0, 4, 0, 2,
+		1, 0, 5, 0, 2, // The closing quotes
+	}
+	if !slices.Equal(data, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, data)
+	}
+}
+
+func TestSemanticLegendErrors(t *testing.T) {
+	legend := &SemanticLegend{
+		TokenTypes: []string{"keyword"},
+		Mapping:    map[TokenType]string{VariableTokenType: "variable"},
+	}
+	_, err := SemanticTokens("x", nil, legend)
+	if err == nil || !strings.Contains(err.Error(), "token type 'variable'") {
+		t.Errorf("expected an error for an unknown token type, got %v", err)
+	}
+	legend.Mapping = map[TokenType]string{VariableTokenType: "keyword.readonly"}
+	_, err = SemanticTokens("x", nil, legend)
+	if err == nil || !strings.Contains(err.Error(), "token modifier 'readonly'") {
+		t.Errorf("expected an error for an unknown modifier, got %v", err)
+	}
+}
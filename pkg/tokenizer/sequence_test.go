@@ -0,0 +1,43 @@
+package tokenizer_test
+
+import (
+	"testing"
+
+	"github.com/spicery/nutmeg-tokenizer/pkg/tokenizer"
+	"github.com/spicery/nutmeg-tokenizer/pkg/tokentest"
+)
+
+// These tests use the sequences of the tokentest package, which cannot be
+// imported by the tests within the package itself.
+
+func TestSequences(t *testing.T) {
+	tests := []struct {
+		input    string
+		sequence string
+	}{
+		{"def foo(x) =>> x + 1 enddef", "S:def V:foo [:( V:x ]:) B:=>> V:x O:+ n:1 E:enddef"},
+		{"if a then b elseif c then d else e endif", "S:if V:a B:then V:b B:elseif V:c B:then V:d B:else V:e E:endif"},
+		{"for i in xs do f(i) endfor", "S:for V:i O:in V:xs B:do V:f [:( V:i ]:) E:endfor"},
+		{"let x := 1 endlet", "S:let V:x O::= n:1 E:endlet"},
+		{"x := [1, 2.5, 0x1F, 2r101]", "V:x O::= [:[ n:1 M:, n:2.5 M:, n:0x1F M:, n:2r101 ]:]"},
+		{"a.b[c]", "V:a O:. V:b [:[ V:c ]:]"},
+		{"f(-1, x)", "V:f [:( O:- n:1 M:, V:x ]:)"},
+		{`s := "a\(b)c"`, "V:s O::= i"},
+		{"x ### comment\ny", "V:x V:y"},
+		{`f("a b", 'c')`, "V:f [:( s M:, s:'c' ]:)"},
+	}
+	for _, test := range tests {
+		tokentest.ExpectTokens(t, test.input, test.sequence)
+	}
+}
+
+func TestSequenceOptions(t *testing.T) {
+	tokentest.ExpectTokensWith(t, "f(x: 1)", &tokenizer.Options{KeywordArguments: true}, "V:f [:( K:x: n:1 ]:)")
+	tokentest.ExpectTokensWith(t, "if x then", &tokenizer.Options{EndOfFileToken: true}, "S:if V:x B:then $:")
+	tokentest.ExpectTokensWith(t, "a::b", &tokenizer.Options{QualifiedSeparator: "::"}, "V:a::b")
+}
+
+func TestSequenceErrors(t *testing.T) {
+	tokentest.ExpectError(t, `x := "abc`, nil, "unterminated string")
+	tokentest.ExpectError(t, "x := \"a\nb\"", nil, "line break in string")
+}
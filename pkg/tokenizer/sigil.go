@@ -0,0 +1,60 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SigilRule is a rule of the sigil section, which gives a character such as
+// $ or # a role of its own rather than leaving it an unclassified token.
+type SigilRule struct {
+	Text     string `yaml:"text"`
+	Role     string `yaml:"role"` // SigilIdentifier or SigilOperator
+	Priority int    `yaml:"priority,omitempty"`
+}
+
+// The roles that a sigil rule can give its sigil.
+const (
+	SigilIdentifier = "identifier" // Read together with the name after it, as $x or #name
+	SigilOperator   = "operator"   // Read by itself, as # in #xs for a length
+)
+
+// checkSigilRule reports an error if the rule has no text, has text that
+// would be read as part of an identifier, or has an unknown role.
+func checkSigilRule(rule SigilRule) error {
+	if rule.Text == "" {
+		return fmt.Errorf("sigil rule with no text")
+	}
+	if strings.ContainsFunc(rule.Text, func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r)
+	}) {
+		return fmt.Errorf("sigil '%s' has a letter, digit, underscore or space", rule.Text)
+	}
+	if rule.Role != SigilIdentifier && rule.Role != SigilOperator {
+		return fmt.Errorf("sigil '%s' has the unknown role '%s' (expected %s or %s)", rule.Text, rule.Role, SigilIdentifier, SigilOperator)
+	}
+	return nil
+}
+
+// sigilToken makes the token of a sigil whose text has just been read. A
+// sigil with the identifier role is read together with the name that follows
+// it, which becomes the token's value. Without a name straight after it, it
+// is unclassified.
+func (t *Tokenizer) sigilToken(text, role string, start Position, span Span) *Token {
+	if role != SigilIdentifier {
+		token := t.arena.alloc(NewToken(text, SigilTokenType, span))
+		token.Role = role
+		return token
+	}
+	name := t.matchIdentifier(t.input[t.position:])
+	if name == "" {
+		t.warn(start, text, "sigil has no name after it")
+		return t.arena.alloc(NewToken(text, UnclassifiedTokenType, span))
+	}
+	t.advance(len(name))
+	token := t.arena.alloc(NewToken(text+name, SigilTokenType, t.spanFrom(start)))
+	token.Role = role
+	token.Value = &name
+	return token
+}
@@ -0,0 +1,88 @@
+package tokenizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sigilRules returns rules with $ prefixing names and # as an operator.
+func sigilRules(t *testing.T) *TokenizerRules {
+	t.Helper()
+	rules, err := ApplyRulesToDefaults(&RulesFile{Sigil: []SigilRule{
+		{Text: "$", Role: SigilIdentifier},
+		{Text: "#", Role: SigilOperator},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return rules
+}
+
+func TestSigils(t *testing.T) {
+	tokens, err := New("$count := #xs + $y-1", &Options{Rules: sigilRules(t)}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, []string{"$count", ":=", "#", "xs", "+", "$y", "-", "1"}) {
+		t.Fatalf("Unexpected tokens %v", got)
+	}
+
+	name := tokens[0]
+	if name.Type != SigilTokenType || name.Role != SigilIdentifier || name.Value == nil || *name.Value != "count" {
+		t.Errorf("Expected a sigil token for count, got %+v", name)
+	}
+	if name.Span != (Span{Position{1, 1}, Position{1, 7}}) {
+		t.Errorf("Expected $count at 1:1-1:7, got %v", name.Span)
+	}
+	length := tokens[2]
+	if length.Type != SigilTokenType || length.Role != SigilOperator || length.Value != nil {
+		t.Errorf("Expected # to be a sigil token by itself, got %+v", length)
+	}
+	if tokens[3].Type != VariableTokenType {
+		t.Errorf("Expected xs to be a variable, got %s", tokens[3].Type)
+	}
+}
+
+func TestSigilWithoutName(t *testing.T) {
+	rules := sigilRules(t)
+	result, err := New("a $ b", &Options{Rules: rules}).TokenizeResult()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if token := result.Tokens[1]; token.Text != "$" || token.Type != UnclassifiedTokenType {
+		t.Errorf("Expected $ to be unclassified, got %+v", token)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Message != "sigil has no name after it" {
+		t.Errorf("Expected a warning about the missing name, got %v", result.Diagnostics)
+	}
+
+	// Without sigil rules, $ and # are unclassified as before.
+	tokens, err := New("$x #", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, token := range []*Token{tokens[0], tokens[2]} {
+		if token.Type != UnclassifiedTokenType {
+			t.Errorf("Expected %q to be unclassified, got %s", token.Text, token.Type)
+		}
+	}
+}
+
+func TestSigilRuleErrors(t *testing.T) {
+	tests := []struct {
+		rules  []SigilRule
+		reason string
+	}{
+		{[]SigilRule{{Role: SigilIdentifier}}, "no text"},
+		{[]SigilRule{{Text: "@a", Role: SigilIdentifier}}, "has a letter"},
+		{[]SigilRule{{Text: "$", Role: "length"}}, "unknown role 'length'"},
+		{[]SigilRule{{Text: "+", Role: SigilOperator}}, "defined in both"},
+	}
+	for _, test := range tests {
+		_, err := ApplyRulesToDefaults(&RulesFile{Sigil: test.rules})
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%v: expected an error %q, got %v", test.rules, test.reason, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package tokenizer
+
+// Before reports whether p comes strictly before q in the source.
+func (p Position) Before(q Position) bool {
+	return p.Line < q.Line || (p.Line == q.Line && p.Col < q.Col)
+}
+
+// Contains reports whether the position lies within the span. Spans are
+// half-open, so the end position itself is not contained.
+func (s Span) Contains(p Position) bool {
+	return !p.Before(s.Start) && p.Before(s.End)
+}
+
+// Overlaps reports whether the two spans share at least one position.
+func (s Span) Overlaps(other Span) bool {
+	return s.Start.Before(other.End) && other.Start.Before(s.End)
+}
+
+// SourceText returns the slice of input covered by the token's span. It
+// returns the empty string if the span does not lie within the input. The
+// span's columns must count characters, as they do by default.
+func (t *Token) SourceText(input string) string {
+	return t.SourceTextWithPolicy(input, ColumnRunes)
+}
+
+// SourceTextWithPolicy returns the slice of input covered by the token's
+// span, whose columns count what the policy says, as SourceText does.
+func (t *Token) SourceTextWithPolicy(input string, policy ColumnPolicy) string {
+	index := NewLineIndexWithPolicy(input, policy)
+	start := index.Offset(t.Span.Start)
+	end := index.Offset(t.Span.End)
+	if start < 0 || end < start {
+		return ""
+	}
+	return input[start:end]
+}
+
+// TokensInRange returns the tokens whose spans overlap the given span, in
+// their original order. A zero-width span selects the token containing its
+// start position, which is what a cursor lookup needs.
+func TokensInRange(tokens []*Token, span Span) []*Token {
+	var found []*Token
+	for _, token := range tokens {
+		if span.Start == span.End {
+			if token.Span.Contains(span.Start) {
+				found = append(found, token)
+			}
+		} else if token.Span.Overlaps(span) {
+			found = append(found, token)
+		}
+	}
+	return found
+}
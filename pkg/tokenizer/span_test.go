@@ -0,0 +1,131 @@
+package tokenizer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSpanContains(t *testing.T) {
+	span := Span{Start: Position{1, 5}, End: Position{2, 3}}
+	tests := []struct {
+		pos      Position
+		expected bool
+	}{
+		{Position{1, 4}, false},
+		{Position{1, 5}, true},
+		{Position{1, 80}, true},
+		{Position{2, 2}, true},
+		{Position{2, 3}, false},
+		{Position{3, 1}, false},
+	}
+	for _, tt := range tests {
+		if got := span.Contains(tt.pos); got != tt.expected {
+			t.Errorf("Contains(%v): expected %v, got %v", tt.pos, tt.expected, got)
+		}
+	}
+}
+
+func TestSourceText(t *testing.T) {
+	input := "def f(x)\n    \"hi\" + x\nend"
+	tokens, err := NewTokenizer(input).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, token := range tokens {
+		if got := token.SourceText(input); got != token.Text {
+			t.Errorf("Token %d: expected source text %q, got %q", i, token.Text, got)
+		}
+	}
+
+	outside := NewToken("x", VariableTokenType, Span{Position{9, 1}, Position{9, 2}})
+	if got := outside.SourceText(input); got != "" {
+		t.Errorf("Expected empty source text for out of range span, got %q", got)
+	}
+}
+
+func TestTokensInRange(t *testing.T) {
+	input := "a + bb\ncc"
+	tokens, err := NewTokenizer(input).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A cursor in the middle of "bb".
+	cursor := Position{1, 6}
+	found := TokensInRange(tokens, Span{cursor, cursor})
+	if len(found) != 1 || found[0].Text != "bb" {
+		t.Errorf("Expected [bb] at cursor, got %v", tokenTexts(found))
+	}
+
+	// A selection from "+" to the start of "cc".
+	found = TokensInRange(tokens, Span{Position{1, 3}, Position{2, 2}})
+	if len(found) != 3 || found[0].Text != "+" || found[2].Text != "cc" {
+		t.Errorf("Expected [+ bb cc], got %v", tokenTexts(found))
+	}
+}
+
+func TestSpansOverMultilineInput(t *testing.T) {
+	input := "x := \"a\\(b)c\"\n@sql\"raw\"\n  42 0x1F\ns := \"\"\"\n  hi\n  \"\"\"\nend"
+	tokens, err := NewTokenizer(input).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []Span{
+		{Position{1, 1}, Position{1, 2}},  // x
+		{Position{1, 3}, Position{1, 5}},  // :=
+		{Position{1, 6}, Position{1, 14}}, // "a\(b)c"
+		{Position{2, 1}, Position{2, 10}}, // @sql"raw"
+		{Position{3, 3}, Position{3, 5}},  // 42
+		{Position{3, 6}, Position{3, 10}}, // 0x1F
+		{Position{4, 1}, Position{4, 2}},  // s
+		{Position{4, 3}, Position{4, 5}},  // :=
+		{Position{4, 6}, Position{6, 6}},  // """ ... """
+		{Position{7, 1}, Position{7, 4}},  // end
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(expected), len(tokens), tokenTexts(tokens))
+	}
+	for i, span := range expected {
+		if tokens[i].Span != span {
+			t.Errorf("Token %d (%q): expected span %v, got %v", i, tokens[i].Text, span, tokens[i].Span)
+		}
+		if got := tokens[i].SourceText(input); got != tokens[i].Text {
+			t.Errorf("Token %d: span covers %q but text is %q", i, got, tokens[i].Text)
+		}
+	}
+
+	// The parts of an interpolated string also carry complete spans.
+	for i, sub := range tokens[2].Subtokens {
+		if sub.Span.Start.Line != 1 || sub.Span.End.Line != 1 || !sub.Span.Start.Before(sub.Span.End) {
+			t.Errorf("Subtoken %d (%q): incomplete span %v", i, sub.Text, sub.Span)
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Span
+	}{
+		{"Line break in string", "x\n  \"abc\ny", Span{Position{2, 3}, Position{2, 7}}},
+		{"Unterminated string", "x\n  \"abc", Span{Position{2, 3}, Position{2, 7}}},
+		{"Unterminated raw string", "x\n@\"abc", Span{Position{2, 2}, Position{2, 6}}},
+		{"Invalid numeric literal", "x\n  9rZ", Span{Position{2, 3}, Position{2, 6}}},
+		{"Unindented multiline string", "\"\"\"\nab\n  \"\"\"", Span{Position{2, 1}, Position{2, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTokenizer(tt.input).Tokenize()
+			var tokErr *Error
+			if !errors.As(err, &tokErr) {
+				t.Fatalf("Expected a tokenizer Error, got %v", err)
+			}
+			if tokErr.Span != tt.expected {
+				t.Errorf("Expected error span %v, got %v", tt.expected, tokErr.Span)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package tokenizer
+
+// TokenizeStream processes the input like Tokenize, but passes each token to
+// emit as soon as it is final rather than returning them all at the end. A
+// token is final once the next one has been found, as only then is it known
+// whether a newline follows it, and the tokens of a quasi-quoted template are
+// only final once it is closed. The tokens found before an error, including
+// any exception token, are emitted before the error is returned. An error
+// from emit stops tokenizing and is returned as it is. Transforms are not
+// applied, as they need the whole stream.
+func (t *Tokenizer) TokenizeStream(emit func(*Token) error) error {
+	t.handedOut = true
+	emitted := 0
+	flush := func(upTo int) error {
+		t.internTokens(t.tokens[emitted:max(upTo, emitted)])
+		for ; emitted < upTo; emitted++ {
+			if err := emit(t.tokens[emitted]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var emitErr error
+	err := t.run(func() error {
+		upTo := len(t.tokens) - 1
+		if len(t.quasiStack) > 0 {
+			upTo = min(upTo, t.quasiStack[0].index)
+		}
+		emitErr = flush(upTo)
+		return emitErr
+	})
+	if emitErr != nil {
+		return emitErr
+	}
+	if emitErr = flush(len(t.tokens)); emitErr != nil {
+		return emitErr
+	}
+	return err
+}
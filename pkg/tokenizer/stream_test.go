@@ -0,0 +1,55 @@
+package tokenizer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeStreamMatchesTokenize(t *testing.T) {
+	input := "if a then\n  f(x)\nend\n"
+	expected, err := New(input, nil).TokenizeValues()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var streamed []Token
+	err = New(input, nil).TokenizeStream(func(token *Token) error {
+		streamed = append(streamed, *token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(streamed, expected) {
+		t.Errorf("expected the streamed tokens to match\n%v\n%v", streamed, expected)
+	}
+}
+
+func TestTokenizeStreamEmitsBeforeError(t *testing.T) {
+	var texts []string
+	err := New(`x := "abc`, nil).TokenizeStream(func(token *Token) error {
+		texts = append(texts, token.Text)
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !reflect.DeepEqual(texts, []string{"x", ":="}) {
+		t.Errorf("expected the tokens before the error, got %q", texts)
+	}
+}
+
+func TestTokenizeStreamStopsOnEmitError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := New("a b c d", nil).TokenizeStream(func(token *Token) error {
+		calls++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("expected the emit error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected emit to be called once, got %d", calls)
+	}
+}
@@ -0,0 +1,178 @@
+package tokenizer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StyleRule is the style section of a rules file. It turns on checks of the
+// identifiers of the input, whose failures are reported as warnings, so that
+// the tokenizer can serve as the front end of a simple linter.
+type StyleRule struct {
+	KeywordCase     bool   `yaml:"keyword_case,omitempty"`     // Flag variables that are keywords in a different case
+	VariablePattern string `yaml:"variable_pattern,omitempty"` // Regular expression every variable must match
+	MixedScript     bool   `yaml:"mixed_script,omitempty"`     // Flag identifiers that mix letters of several scripts
+
+	// RepeatedMarks flags a mark directly after another, such as the second
+	// , in f(a,, b). TrailingMarks flags a mark directly before a closing
+	// bracket or end token, such as the , in f(a, b,), unless it is one of
+	// AllowTrailingMarks.
+	RepeatedMarks      bool     `yaml:"repeated_marks,omitempty"`
+	TrailingMarks      bool     `yaml:"trailing_marks,omitempty"`
+	AllowTrailingMarks []string `yaml:"allow_trailing_marks,omitempty"`
+}
+
+// StyleData holds the style checks of a set of rules.
+type StyleData struct {
+	KeywordCase     bool
+	VariablePattern *regexp.Regexp // Matched against the whole name, or nil
+	MixedScript     bool
+
+	RepeatedMarks      bool
+	TrailingMarks      bool
+	AllowTrailingMarks map[string]bool `json:",omitempty"` // The marks that TrailingMarks allows
+}
+
+// compileStyleRule checks a style rule and converts it to StyleData.
+func compileStyleRule(rule StyleRule) (*StyleData, error) {
+	style := &StyleData{KeywordCase: rule.KeywordCase, MixedScript: rule.MixedScript}
+	style.RepeatedMarks = rule.RepeatedMarks
+	style.TrailingMarks = rule.TrailingMarks
+	if len(rule.AllowTrailingMarks) > 0 {
+		if !rule.TrailingMarks {
+			return nil, fmt.Errorf("allow_trailing_marks in style rules needs trailing_marks")
+		}
+		style.AllowTrailingMarks = make(map[string]bool, len(rule.AllowTrailingMarks))
+		for _, mark := range rule.AllowTrailingMarks {
+			style.AllowTrailingMarks[mark] = true
+		}
+	}
+	if rule.VariablePattern != "" {
+		pattern, err := regexp.Compile(`^(?:` + rule.VariablePattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid variable_pattern in style rules: %w", err)
+		}
+		style.VariablePattern = pattern
+	}
+	return style, nil
+}
+
+// checkStyle warns about the ways in which the token breaks the style rules.
+// It is called with each token before it is added.
+func (t *Tokenizer) checkStyle(token *Token) {
+	style := t.rules.Style
+	if token.Type == VariableTokenType {
+		if style.KeywordCase {
+			if keyword, ok := t.keywordFolds()[strings.ToLower(token.Text)]; ok {
+				t.warn(token.Span.Start, token.Text, fmt.Sprintf("'%s' is the keyword '%s' in a different case", token.Text, keyword))
+			}
+		}
+		if style.VariablePattern != nil && !style.VariablePattern.MatchString(token.Text) {
+			t.warn(token.Span.Start, token.Text, fmt.Sprintf("variable '%s' does not match the variable pattern", token.Text))
+		}
+	}
+	if style.MixedScript {
+		t.checkScript(token)
+	}
+	if style.RepeatedMarks || style.TrailingMarks {
+		t.checkMarks(token)
+	}
+}
+
+// checkMarks warns about a mark directly after another mark, or a mark
+// directly before a closing bracket or end token, as the style rules ask.
+// Trivia between the tokens does not separate them, so ;\n; is a repeated
+// mark too.
+func (t *Tokenizer) checkMarks(token *Token) {
+	style := t.rules.Style
+	last := t.lastToken()
+	if last == nil || last.Type != MarkTokenType {
+		return
+	}
+	switch {
+	case token.Type == MarkTokenType && style.RepeatedMarks:
+		t.warn(token.Span.Start, token.Text, fmt.Sprintf("mark '%s' directly after the mark '%s'", token.Text, last.Text))
+	case (token.Type == CloseDelimiterTokenType || token.Type == EndTokenType) && style.TrailingMarks && !style.AllowTrailingMarks[last.Text]:
+		t.warn(last.Span.Start, last.Text, fmt.Sprintf("trailing mark '%s' before '%s'", last.Text, token.Text))
+	}
+}
+
+// keywordFolds returns the keywords of the rules that look like identifiers,
+// keyed by their lower case form. It is built when first needed.
+func (t *Tokenizer) keywordFolds() map[string]string {
+	if t.foldedKeywords == nil {
+		t.foldedKeywords = map[string]string{}
+		// The keys are sorted so that, of two keywords that differ only in
+		// case, the same one is always reported.
+		for _, text := range sortedKeys(t.rules.TokenLookup) {
+			fold := strings.ToLower(text)
+			if _, seen := t.foldedKeywords[fold]; !seen && identifierRegex.FindString(text) == text {
+				t.foldedKeywords[fold] = text
+			}
+		}
+	}
+	return t.foldedKeywords
+}
+
+// checkScript warns, once per word, when a word mixes letters of different
+// scripts. Identifiers only contain ASCII letters, so a name with, say, a
+// Cyrillic letter in it is read as identifiers and unclassified tokens with
+// nothing between them, which are taken together as one word.
+func (t *Tokenizer) checkScript(token *Token) {
+	if !isWordToken(token) {
+		return
+	}
+	joined := false
+	if n := len(t.tokens); n > 0 {
+		previous := t.tokens[n-1]
+		joined = previous.Span.End == token.Span.Start && isWordToken(previous)
+	}
+	if !joined {
+		t.wordScript, t.wordWarned = "", false
+	}
+	script := letterScript(token.Text)
+	switch {
+	case script == "":
+	case t.wordScript == "":
+		t.wordScript = script
+	case script != t.wordScript && !t.wordWarned:
+		t.warn(token.Span.Start, token.Text, fmt.Sprintf("identifier mixes %s and %s letters", t.wordScript, script))
+		t.wordWarned = true
+	}
+}
+
+// isWordToken reports whether the token can be part of a word: an
+// identifier, whatever it was classified as, or an unclassified letter.
+func isWordToken(token *Token) bool {
+	if token.Type == UnclassifiedTokenType {
+		r, _ := utf8.DecodeRuneInString(token.Text)
+		return unicode.IsLetter(r)
+	}
+	return token.Text != "" && identifierRegex.FindString(token.Text) == token.Text
+}
+
+// scriptNames are the names of the Unicode scripts, sorted so that lookups
+// are deterministic.
+var scriptNames = sortedKeys(unicode.Scripts)
+
+// letterScript returns the script of the first letter of the text that
+// belongs to a particular script, or "" if there is none.
+func letterScript(text string) string {
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if r < utf8.RuneSelf {
+			return "Latin"
+		}
+		for _, name := range scriptNames {
+			if name != "Common" && name != "Inherited" && unicode.Is(unicode.Scripts[name], r) {
+				return name
+			}
+		}
+	}
+	return ""
+}
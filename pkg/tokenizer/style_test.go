@@ -0,0 +1,107 @@
+package tokenizer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStyleRules(t *testing.T) {
+	rulesFile, err := ParseRulesFile([]byte(`
+style:
+  keyword_case: true
+  variable_pattern: "[a-z][a-zA-Z0-9]*"
+  mixed_script: true
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rules, err := ApplyRulesToDefaults(rulesFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"if x then y endif", nil},
+		{"IF x", []string{"1:1 'IF' is the keyword 'if' in a different case", "1:1 variable 'IF' does not match the variable pattern"}},
+		{"my_name", []string{"1:1 variable 'my_name' does not match the variable pattern"}},
+		{"pаypal", []string{"1:2 identifier mixes Latin and Cyrillic letters"}},
+		{"xπy πx", []string{"1:2 identifier mixes Latin and Greek letters", "1:6 identifier mixes Greek and Latin letters"}},
+		{"café x π", nil},
+	}
+	for _, test := range tests {
+		result, err := New(test.input, &Options{Rules: rules}).TokenizeResult()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		var messages []string
+		for _, d := range result.Diagnostics {
+			messages = append(messages, fmt.Sprintf("%d:%d %s", d.Span.Start.Line, d.Span.Start.Col, d.Message))
+		}
+		if !reflect.DeepEqual(messages, test.expected) {
+			t.Errorf("For %q expected %v, got %v", test.input, test.expected, messages)
+		}
+	}
+
+	// Without a style section nothing is checked.
+	if result, _ := New("IF my_name", nil).TokenizeResult(); len(result.Diagnostics) != 0 {
+		t.Errorf("Expected no style warnings without style rules, got %v", result.Diagnostics)
+	}
+}
+
+func TestMarkStyleRules(t *testing.T) {
+	rulesFile, err := ParseRulesFile([]byte(`
+style:
+  repeated_marks: true
+  trailing_marks: true
+  allow_trailing_marks: [","]
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rules, err := ApplyRulesToDefaults(rulesFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"f(a, b); g(c)", nil},
+		{"f(a,, b)", []string{"1:5-1:6 mark ',' directly after the mark ','"}},
+		{"x;\n### comment\n;", []string{"3:1-3:2 mark ';' directly after the mark ';'"}},
+		{"[1, 2,]", nil},
+		{"f(a;)", []string{"1:4-1:5 trailing mark ';' before ')'"}},
+		{"if x then y; endif", []string{"1:12-1:13 trailing mark ';' before 'endif'"}},
+	}
+	for _, test := range tests {
+		result, err := New(test.input, &Options{Rules: rules}).TokenizeResult()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		var messages []string
+		for _, d := range result.Diagnostics {
+			messages = append(messages, fmt.Sprintf("%d:%d-%d:%d %s", d.Span.Start.Line, d.Span.Start.Col, d.Span.End.Line, d.Span.End.Col, d.Message))
+		}
+		if !reflect.DeepEqual(messages, test.expected) {
+			t.Errorf("For %q expected %v, got %v", test.input, test.expected, messages)
+		}
+	}
+
+	// Allowed marks still need trailing_marks to be turned on.
+	if _, err := ApplyRulesToDefaults(&RulesFile{Style: &StyleRule{AllowTrailingMarks: []string{","}}}); err == nil {
+		t.Errorf("Expected an error for allow_trailing_marks without trailing_marks")
+	}
+}
+
+func TestStyleRuleErrors(t *testing.T) {
+	_, err := ApplyRulesToDefaults(&RulesFile{Style: &StyleRule{VariablePattern: "[a-z"}})
+	if err == nil || !strings.Contains(err.Error(), "variable_pattern") {
+		t.Errorf("Expected an invalid variable_pattern error, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package tokenizer
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 )
 
@@ -15,6 +16,8 @@ const (
 	MultiLineStringTokenType    TokenType = "m" // String literals with quotes and escapes
 	InterpolatedStringTokenType TokenType = "i" // Interpolated string literals e.g. `Hello, \(name)!`
 	ExpressionTokenType         TokenType = "e" // Expression tokens (e.g., (1 + 2))
+	QuasiQuoteTokenType         TokenType = "q" // Quasi-quoted templates e.g. $( f($x) )
+	HoleTokenType               TokenType = "h" // Holes in quasi-quoted templates e.g. $x
 
 	// Identifier tokens
 	StartTokenType    TokenType = "S" // Form start tokens (def, if, while)
@@ -23,6 +26,13 @@ const (
 	PrefixTokenType   TokenType = "P" // Prefix operators (return, yield)
 	VariableTokenType TokenType = "V" // Variable identifiers
 
+	// Label tokens
+	LabelNameTokenType  TokenType = "L" // Statement labels (outer: in outer: for)
+	KeywordArgTokenType TokenType = "K" // Keyword arguments (name: in f(name: 1))
+
+	// Sigil tokens, only emitted with sigil rules
+	SigilTokenType TokenType = "G" // Sigils and the names they prefix ($x, or # in #xs)
+
 	// Other tokens
 	OperatorTokenType       TokenType = "O" // Infix/postfix operators
 	OpenDelimiterTokenType  TokenType = "[" // Opening brackets/braces/parentheses
@@ -30,8 +40,91 @@ const (
 	MarkTokenType           TokenType = "M" // Marks (commas, semicolons)
 	UnclassifiedTokenType   TokenType = "U" // Unclassified tokens
 	ExceptionTokenType      TokenType = "X" // Exception tokens for invalid constructs
+
+	// Trivia tokens, only emitted in lossless mode
+	WhitespaceTokenType TokenType = "w" // Runs of whitespace between tokens
+	CommentTokenType    TokenType = "c" // Comments, including doc comments
+
+	// The token at the end of the input, only emitted with Options.EndOfFileToken
+	EndOfFileTokenType TokenType = "$"
 )
 
+// knownTokenTypes lists every token type the tokenizer can emit.
+var knownTokenTypes = []TokenType{
+	NumericLiteralTokenType,
+	StringLiteralTokenType,
+	MultiLineStringTokenType,
+	InterpolatedStringTokenType,
+	ExpressionTokenType,
+	QuasiQuoteTokenType,
+	HoleTokenType,
+	StartTokenType,
+	EndTokenType,
+	BridgeTokenType,
+	PrefixTokenType,
+	VariableTokenType,
+	LabelNameTokenType,
+	KeywordArgTokenType,
+	SigilTokenType,
+	OperatorTokenType,
+	OpenDelimiterTokenType,
+	CloseDelimiterTokenType,
+	MarkTokenType,
+	UnclassifiedTokenType,
+	ExceptionTokenType,
+	WhitespaceTokenType,
+	CommentTokenType,
+	EndOfFileTokenType,
+}
+
+// IsKnown reports whether the token type is one the tokenizer can emit.
+func (tt TokenType) IsKnown() bool {
+	for _, known := range knownTokenTypes {
+		if tt == known {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenTypeNames are the full names of the token types, for output that is
+// read by people rather than parsers.
+var tokenTypeNames = map[TokenType]string{
+	NumericLiteralTokenType:     "numeric",
+	StringLiteralTokenType:      "string",
+	MultiLineStringTokenType:    "multiline_string",
+	InterpolatedStringTokenType: "interpolated_string",
+	ExpressionTokenType:         "expression",
+	QuasiQuoteTokenType:         "quasi_quote",
+	HoleTokenType:               "hole",
+	StartTokenType:              "start",
+	EndTokenType:                "end",
+	BridgeTokenType:             "bridge",
+	PrefixTokenType:             "prefix",
+	VariableTokenType:           "variable",
+	LabelNameTokenType:          "label",
+	KeywordArgTokenType:         "keyword_arg",
+	SigilTokenType:              "sigil",
+	OperatorTokenType:           "operator",
+	OpenDelimiterTokenType:      "open_delimiter",
+	CloseDelimiterTokenType:     "close_delimiter",
+	MarkTokenType:               "mark",
+	UnclassifiedTokenType:       "unclassified",
+	ExceptionTokenType:          "exception",
+	WhitespaceTokenType:         "whitespace",
+	CommentTokenType:            "comment",
+	EndOfFileTokenType:          "end_of_file",
+}
+
+// Name returns the full name of the token type, such as "start" for S, or
+// the code itself for an unknown type.
+func (tt TokenType) Name() string {
+	if name, ok := tokenTypeNames[tt]; ok {
+		return name
+	}
+	return string(tt)
+}
+
 // Position represents a line and column position in the source file.
 type Position struct {
 	Line int `json:"line"`
@@ -61,6 +154,9 @@ func (s *Span) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Arity is the number of statement blocks that a start, bridge or prefix
+// token introduces. It is written as zero, one or many in rules files and in
+// the token JSON.
 type Arity int
 
 const (
@@ -69,6 +165,50 @@ const (
 	Many
 )
 
+// arityNames are the textual forms of the arities, indexed by arity.
+var arityNames = []string{"zero", "one", "many"}
+
+// String returns the name of the arity.
+func (a Arity) String() string {
+	if a >= 0 && int(a) < len(arityNames) {
+		return arityNames[a]
+	}
+	return fmt.Sprintf("Arity(%d)", int(a))
+}
+
+// MarshalText writes the arity as zero, one or many, which is used by both
+// the JSON and the YAML encodings.
+func (a Arity) MarshalText() ([]byte, error) {
+	if a < 0 || int(a) >= len(arityNames) {
+		return nil, fmt.Errorf("invalid arity %d", int(a))
+	}
+	return []byte(arityNames[a]), nil
+}
+
+// UnmarshalText reads an arity written as zero, one or many. The numbers 0, 1
+// and 2 are also accepted, as rules files used to give arities as numbers.
+func (a *Arity) UnmarshalText(text []byte) error {
+	for i, name := range arityNames {
+		if string(text) == name || string(text) == fmt.Sprint(i) {
+			*a = Arity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid arity '%s' (expected zero, one or many)", text)
+}
+
+// UnmarshalJSON reads an arity written as zero, one or many, or as a number,
+// as format version 1 wrote them, so that tokens of either version can be read
+// back.
+func (a *Arity) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		// It is not a string, so it can only be a valid arity as a number.
+		text = string(data)
+	}
+	return a.UnmarshalText([]byte(text))
+}
+
 // Token represents a single token from the Nutmeg source code.
 type Token struct {
 	// Common fields for all tokens
@@ -76,12 +216,14 @@ type Token struct {
 	Span  Span      `json:"span"`
 	Type  TokenType `json:"type"`
 	Alias *string   `json:"alias,omitempty"` // The node alias, if any
+	File  string    `json:"file,omitempty"`  // The file named by a ###line directive, if not the input
 
 	// String token fields
 	Quote     string   `json:"quote,omitempty"`
 	Value     *string  `json:"value,omitempty"`
 	Specifier *string  `json:"specifier,omitempty"`
 	Subtokens []*Token `json:"subtokens,omitempty"`
+	Embedded  []*Token `json:"embedded,omitempty"` // Tokens of the body of a code fence, from Options.OnFence
 
 	// Numeric token fields
 	Radix    *string `json:"radix,omitempty"` // Textual radix prefix (e.g., "0x", "2r", "0t", "" for decimal)
@@ -89,12 +231,24 @@ type Token struct {
 	Mantissa *string `json:"mantissa,omitempty"`
 	Fraction *string `json:"fraction,omitempty"`
 	Exponent *int    `json:"exponent,omitempty"`
+	ExpBase  *int    `json:"exp_base,omitempty"` // What the exponent scales by: 10, or 2 for a p exponent
 	Balanced *bool   `json:"balanced,omitempty"` // For balanced ternary numbers
+	Sign     *string `json:"sign,omitempty"`     // The sign in front of the number, with Options.NumericSign
+
+	// The mantissa and fraction as written, with their underscores, when they
+	// have any.
+	RawMantissa *string `json:"raw_mantissa,omitempty"`
+	RawFraction *string `json:"raw_fraction,omitempty"`
+
+	// Qualified identifier fields (only populated with Options.QualifiedSeparator)
+	Parts []string `json:"parts,omitempty"` // The names of the identifier, in order
 
 	// Start token, Bridge token, and Compound token fields
 	Expecting []string `json:"expecting,omitempty"` // For start tokens (immediate next tokens) and bridge tokens (what can follow them)
 	In        []string `json:"in,omitempty"`        // For bridge and compound tokens - what can contain them
 	ClosedBy  []string `json:"closed_by,omitempty"` // For start tokens and delimiter tokens - what can close them
+	OpenedBy  []string `json:"opened_by,omitempty"` // For close delimiter tokens - what they can close
+	Closes    []string `json:"closes,omitempty"`    // For end tokens - the start tokens they can close
 	Arity     *Arity   `json:"arity,omitempty"`     // For start tokens - whether they introduce a single statement block
 
 	// Operator token fields
@@ -104,12 +258,45 @@ type Token struct {
 	InfixPrecedence *int  `json:"infix,omitempty"`  // For delimiter infix usage
 	Prefix          *bool `json:"prefix,omitempty"` // For delimiter prefix usage
 
+	// Mark token fields
+	Role string `json:"role,omitempty"` // For mark tokens - separator or terminator, if the rule gives one - and sigil tokens - identifier or operator
+
 	// Exception token fields
 	Reason *string `json:"reason,omitempty"` // For exception tokens - explanation of the error
 
 	// Newline tracking fields
 	LnBefore *bool `json:"ln_before,omitempty"` // True if token was preceded by a newline
 	LnAfter  *bool `json:"ln_after,omitempty"`  // True if token was followed by a newline
+
+	// Context fields (only populated when context annotation is enabled)
+	Context []string `json:"context,omitempty"` // Enclosing start tokens, outermost first
+
+	// Interning fields (only populated with Options.Interner)
+	TextID int `json:"text_id,omitempty"` // The ID of the text in the interner
+
+	// End of file fields (only populated for the token of Options.EndOfFileToken)
+	Unclosed []string `json:"unclosed,omitempty"` // The start tokens and brackets still open, outermost first
+
+	// Rule source fields (only populated when rule source annotation is enabled)
+	RuleSource *RuleSource `json:"rule_source,omitempty"` // The rule that classified the token
+
+	// Bracket pairing fields (only populated by MatchBrackets)
+	Pair *int `json:"pair,omitempty"` // Index of the matching opener or closer
+
+	// Documentation comment fields
+	Doc *string `json:"doc,omitempty"` // Text of the doc comments just before this token
+
+	// Define fields (only populated for tokens produced by a define rule)
+	ExpandedFrom *Span `json:"expanded_from,omitempty"` // Span of the defined token that was replaced
+}
+
+// CanonicalText returns the text of the token that the token stands for: its
+// alias, if it has one, such as the { of a (: digraph, or else its own text.
+func (t *Token) CanonicalText() string {
+	if t.Alias != nil {
+		return *t.Alias
+	}
+	return t.Text
 }
 
 func (t *Token) SetQuote(r rune) {
@@ -120,6 +307,8 @@ func (t *Token) SetQuote(r rune) {
 		t.Quote = "double"
 	case '`':
 		t.Quote = "backtick"
+	case '«', '»':
+		t.Quote = "guillemet"
 	default:
 		t.Quote = string(r)
 	}
@@ -301,6 +490,40 @@ func NewWildcardBridgeToken(text, expectedText string, expecting, in []string, a
 	}
 }
 
+// NewWildcardStartToken creates a wildcard start token with copied attributes.
+func NewWildcardStartToken(text, expectedText string, expecting, closedBy []string, arity Arity, span Span) *Token {
+	token := NewStartToken(text, expecting, closedBy, span, arity)
+	token.Alias = &expectedText
+	return token
+}
+
+// NewEndToken creates a new end token with the start tokens it can close.
+func NewEndToken(text string, closes []string, span Span) *Token {
+	return &Token{
+		Text:   text,
+		Type:   EndTokenType,
+		Span:   span,
+		Closes: closes,
+	}
+}
+
+// NewWildcardEndToken creates a wildcard end token with copied attributes.
+func NewWildcardEndToken(text, expectedText string, closes []string, span Span) *Token {
+	token := NewEndToken(text, closes, span)
+	token.Alias = &expectedText
+	return token
+}
+
+// NewCloseDelimiterToken creates a new close delimiter token.
+func NewCloseDelimiterToken(text string, openedBy []string, span Span) *Token {
+	return &Token{
+		Text:     text,
+		Type:     CloseDelimiterTokenType,
+		Span:     span,
+		OpenedBy: openedBy,
+	}
+}
+
 func NewUnclassifiedToken(text string, span Span) *Token {
 	return &Token{
 		Text: text,
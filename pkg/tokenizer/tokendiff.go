@@ -0,0 +1,129 @@
+package tokenizer
+
+// TokenChange describes how two token streams differ at one place. The
+// indexes are positions in the streams that were compared. An added token
+// has the old index of the old token it comes before, and a removed token
+// has the new index of the new token it comes before, so that every change
+// can be located in both streams.
+type TokenChange struct {
+	Change   string `json:"change"`        // "added", "removed" or "changed"
+	OldIndex int    `json:"old_index"`     // The index in the old tokens
+	NewIndex int    `json:"new_index"`     // The index in the new tokens
+	Old      *Token `json:"old,omitempty"` // The old token, unless added
+	New      *Token `json:"new,omitempty"` // The new token, unless removed
+}
+
+// DiffTokens compares two token streams and returns their differences in
+// order, so the first change is the first divergence. Differences of layout
+// are ignored: tokens are compared by type, text and alias only, and
+// whitespace tokens are skipped, so moving tokens to other lines or
+// re-indenting makes no changes. Comments are compared, when present.
+//
+// A run of removed tokens followed by a run of added tokens is reported as
+// changes, pairing them up in order, with any left over reported as removed
+// or added.
+func DiffTokens(a, b []*Token) []TokenChange {
+	oldIndexes, newIndexes := significantTokens(a), significantTokens(b)
+	same := func(i, j int) bool {
+		return sameToken(a[oldIndexes[i]], b[newIndexes[j]])
+	}
+
+	// Tokens common to the start and end are trimmed before the longest
+	// common subsequence is found, as they usually make up most of the
+	// streams and the table is quadratic in what is left.
+	n, m := len(oldIndexes), len(newIndexes)
+	prefix := 0
+	for prefix < n && prefix < m && same(prefix, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < n-prefix && suffix < m-prefix && same(n-1-suffix, m-1-suffix) {
+		suffix++
+	}
+	rows, cols := n-prefix-suffix, m-prefix-suffix
+
+	// common[i][j] is the length of the longest common subsequence of the
+	// old tokens from prefix+i and the new tokens from prefix+j.
+	common := make([][]int, rows+1)
+	for i := range common {
+		common[i] = make([]int, cols+1)
+	}
+	for i := rows - 1; i >= 0; i-- {
+		for j := cols - 1; j >= 0; j-- {
+			if same(prefix+i, prefix+j) {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	// position returns the index in the stream of its i-th significant
+	// token, or the length of the stream if there are no more.
+	position := func(indexes []int, tokens []*Token, i int) int {
+		if i < len(indexes) {
+			return indexes[i]
+		}
+		return len(tokens)
+	}
+
+	var changes []TokenChange
+	var removed, added []int
+	// flush reports the runs of removed and added tokens that come before
+	// the old and new significant tokens at nextOld and nextNew.
+	flush := func(nextOld, nextNew int) {
+		paired := min(len(removed), len(added))
+		for k := 0; k < paired; k++ {
+			i, j := oldIndexes[removed[k]], newIndexes[added[k]]
+			changes = append(changes, TokenChange{"changed", i, j, a[i], b[j]})
+		}
+		for _, i := range removed[paired:] {
+			changes = append(changes, TokenChange{Change: "removed", OldIndex: oldIndexes[i], NewIndex: position(newIndexes, b, nextNew), Old: a[oldIndexes[i]]})
+		}
+		for _, j := range added[paired:] {
+			changes = append(changes, TokenChange{Change: "added", OldIndex: position(oldIndexes, a, nextOld), NewIndex: newIndexes[j], New: b[newIndexes[j]]})
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	i, j := 0, 0
+	for i < rows || j < cols {
+		switch {
+		case i < rows && j < cols && same(prefix+i, prefix+j):
+			flush(prefix+i, prefix+j)
+			i++
+			j++
+		case j == cols || (i < rows && common[i+1][j] >= common[i][j+1]):
+			removed = append(removed, prefix+i)
+			i++
+		default:
+			added = append(added, prefix+j)
+			j++
+		}
+	}
+	flush(prefix+rows, prefix+cols)
+	return changes
+}
+
+// significantTokens returns the indexes of the tokens that DiffTokens
+// compares, which are all but the whitespace tokens.
+func significantTokens(tokens []*Token) []int {
+	var indexes []int
+	for i, token := range tokens {
+		if token.Type != WhitespaceTokenType {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// sameToken reports whether two tokens are the same apart from where they
+// are.
+func sameToken(x, y *Token) bool {
+	if x.Type != y.Type || x.Text != y.Text {
+		return false
+	}
+	if x.Alias == nil || y.Alias == nil {
+		return x.Alias == y.Alias
+	}
+	return *x.Alias == *y.Alias
+}
@@ -0,0 +1,55 @@
+package tokenizer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTokens(t *testing.T) {
+	tokenize := func(input string, options *Options) []*Token {
+		tokens, err := New(input, options).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return tokens
+	}
+	summarize := func(changes []TokenChange) []string {
+		var summary []string
+		for _, change := range changes {
+			text := func(token *Token) string {
+				if token == nil {
+					return "-"
+				}
+				return token.Text
+			}
+			summary = append(summary, fmt.Sprintf("%s %d:%s %d:%s", change.Change, change.OldIndex, text(change.Old), change.NewIndex, text(change.New)))
+		}
+		return summary
+	}
+
+	old := tokenize("f(x, y) + z", nil)
+	if changes := DiffTokens(old, tokenize("f( x,\n    y )\n+ z", nil)); len(changes) != 0 {
+		t.Errorf("Expected layout to make no changes, got %v", summarize(changes))
+	}
+	if changes := DiffTokens(old, tokenize("f(x, y) + z", &Options{Lossless: true})); len(changes) != 0 {
+		t.Errorf("Expected whitespace tokens to be ignored, got %v", summarize(changes))
+	}
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"f(x, y) - z", []string{"changed 6:+ 6:-"}},
+		{"f(x) + z", []string{"removed 3:, 3:-", "removed 4:y 3:-"}},
+		{"f(x, y, w) + z", []string{"added 5:- 5:,", "added 5:- 6:w"}},
+		{"f(x, y) + z + 1", []string{"added 8:- 8:+", "added 8:- 9:1"}},
+		{"g(x, y) + a", []string{"changed 0:f 0:g", "changed 7:z 7:a"}},
+	}
+	for _, test := range tests {
+		summary := summarize(DiffTokens(old, tokenize(test.input, nil)))
+		if !reflect.DeepEqual(summary, test.expected) {
+			t.Errorf("For %q expected %v, got %v", test.input, test.expected, summary)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package tokenizer
 
 import (
 	"fmt"
+	"io"
+	"log/slog"
 	"regexp"
 	"strconv"
 	"strings"
@@ -11,25 +13,89 @@ import (
 
 // Tokenizer represents the main tokenizer structure.
 type Tokenizer struct {
-	input          string
-	position       int
-	line           int
-	column         int
-	markStack      []int // Stack of position markers
-	lineNoStack    []int // Array to store line numbers for each token
-	lineColStack   []int // Array to store column numbers for each token
-	tokens         []*Token
-	expectingStack [][]string      // Stack of expecting arrays for context tracking
-	rules          *TokenizerRules // Custom rules for this tokenizer instance
+	input              string
+	position           int
+	line               int
+	column             int
+	markStack          []int // Stack of position markers
+	lineNoStack        []int // Array to store line numbers for each token
+	lineColStack       []int // Array to store column numbers for each token
+	tokens             []*Token
+	expectingStack     []expectingFrame // Stack of open start tokens for context tracking
+	rules              *TokenizerRules  // Custom rules for this tokenizer instance
+	annotateContext    bool             // Whether to attach the enclosing context to tokens
+	arena              tokenArena       // Storage for the tokens produced by the matchers
+	handedOut          bool             // Whether pointers to the tokens have been returned to the caller
+	operatorRunStart   int              // Start of the most recently matched run of sign characters
+	operatorRunEnd     int              // End of that run
+	limits             Limits           // Resource limits, where zero means unlimited
+	interpolationDepth int              // Nesting depth of the interpolation being read
+	traceWriter        io.Writer        // Destination of the trace, or nil if tracing is off
+	logger             *slog.Logger     // Receiver of warnings, or nil to discard them
+	matchers           []matcher        // Matchers to try at each position, in order
+	transforms         []Transform      // Transforms applied to the finished token stream
+	expansion          []*Token         // Tokens of a define expansion waiting to be added
+	expanding          bool             // Whether this tokenizer is expanding a define
+	docMarker          string           // Prefix that marks a comment as documentation
+	optionsErr         error            // Why the options given to New are invalid, or nil
+	doc                []string         // Doc comment lines waiting for the next token
+	lossless           bool             // Whether whitespace and comments are kept as trivia tokens
+	trivia             []*Token         // Trivia tokens waiting to be added
+	lineOffset         int              // Added to line numbers, as set by a ###line directive
+	file               string           // File named by the last ###line directive
+	remaps             []LineRemap      // The renumberings of ###line directives, in order
+	filename           string           // Name of the input, if known
+	bridgeCheck        BridgeCheck      // What to do with a bridge token outside its in list
+	warnAmbiguous      bool             // Whether to warn when a wildcard could stand for several labels
+	multilineValues    bool             // Whether multi-line strings get the joined value of their lines
+	onFence            FenceFunc        // Tokenizes the bodies of code fences, or nil
+	strict             bool             // Whether warnings stop tokenizing with an error
+	strictErr          error            // The first warning, in strict mode
+	diagnostics        []Diagnostic     // Warnings found so far
+	onProgress         ProgressFunc     // Told of the progress of tokenizing, or nil
+	progressReported   int              // Position at which progress was last reported
+	maxLineLength      int              // Longest line allowed without a warning, or 0 for any
+	lengthChecked      int              // Position after the last line whose length was checked
+	warnTrailing       bool             // Whether to warn about whitespace at the end of a line
+	annotateSource     bool             // Whether to record the rule that classified each token
+	numericSign        NumericSign      // What to do with a sign in front of a number
+	maxIntegerBits     int              // Most bits an integer literal may need without a warning, or 0 for any
+	maxExponent        int              // Largest exponent allowed without a warning, or 0 for any
+	continueLines      bool             // Whether a backslash at the end of a line continues a string
+	qualifiedSep       string           // Separator of the names of qualified identifiers, or "" for none
+	keywordArgs        bool             // Whether name: within parentheses is a keyword argument
+	brackets           []string         // The open brackets, innermost last, tracked for keywordArgs
+	columnPolicy       ColumnPolicy     // What columns count
+	keepGoing          bool             // Whether tokenizing goes on after an exception token
+	keepGoingErr       error            // The first error gone on past, in keep-going mode
+	endOfFile          bool             // Whether an end of file token is added
+	newlineAtEnd       bool             // Whether the input ends with a newline after the last token
+	interner           *Interner        // Shares the texts of the tokens, or nil
+
+	// The templates and holes of quasi-quotes that are open, innermost last.
+	quasiStack []quasiFrame
+
+	// State of the style checks of the rules.
+	foldedKeywords map[string]string // Keywords by lower case form, built when first needed
+	wordScript     string            // Script of the letters of the word being read
+	wordWarned     bool              // Whether that word mixes scripts and has been warned about
+}
+
+// expectingFrame records an open start token together with the tokens that
+// are currently expected inside it.
+type expectingFrame struct {
+	start     string
+	expecting []string
 }
 
 // Regular expressions for token matching
 var (
 	identifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
 	operatorRegex   = regexp.MustCompile(`^[.\*/%\+\-<>~!&^|?=:$]+`)
-	radixRegex      = regexp.MustCompile(`^(\d+[xobtr])([0-9A-Z]+(?:_[0-9A-Z]+)*)(\.[0-9A-Z]*(?:_[0-9A-Z]+)*)?(?:e([+-]?\d+))?`)
-	decimalRegex    = regexp.MustCompile(`^(\d+(?:_\d+)*)(\.\d*(?:_\d+)*)?(?:e([+-]?\d+))?`)
-	commentRegex    = regexp.MustCompile(`^###.*`)
+	radixRegex      = regexp.MustCompile(`^(\d+[xobtr])([0-9A-Z]+(?:_[0-9A-Z]+)*)(\.[0-9A-Z]*(?:_[0-9A-Z]+)*)?`)
+	decimalRegex    = regexp.MustCompile(`^(\d+(?:_\d+)*)(\.\d*(?:_\d+)*)?`)
+	exponentRegex   = regexp.MustCompile(`^[+-]?\d+`)
+	commentRegex    = regexp.MustCompile(`^###[^\r\n]*`)
 )
 
 // Start token mappings with expecting and closed_by information
@@ -50,52 +116,35 @@ type PrefixTokenData struct {
 	Arity Arity
 }
 
-// Base precedence values for operator characters (from operators.md)
-// Should follow this order: .([{*/%+-<>~!&^|?:=
-var baseOperatorPrecedence = map[rune]int{
-	'.': 10,
-	'(': 20,
-	'[': 30,
-	'{': 40,
-	'*': 50,
-	'/': 60,
-	'%': 70,
-	'+': 80,
-	'-': 90,
-	'<': 100,
-	'>': 110,
-	'~': 120,
-	'!': 130,
-	'&': 140,
-	'^': 150,
-	'|': 160,
-	'?': 170,
-	'=': 180,
-	':': 190,
+// Quote characters that start string literals, with their attributes
+type QuoteData struct {
+	Interpolation bool // Whether \( \[ and \{ interpolate expressions
+	Raw           bool // Whether backslashes are ordinary characters
 }
 
 // NewTokenizer creates a new tokenizer instance with default rules.
+//
+// Deprecated: Use New(input, nil) instead.
 func NewTokenizer(input string) *Tokenizer {
-	return NewTokenizerWithRules(input, DefaultRules())
+	return New(input, nil)
 }
 
 // NewTokenizerWithRules creates a new tokenizer instance with custom rules.
+//
+// Deprecated: Use New with Options.Rules instead.
 func NewTokenizerWithRules(input string, rules *TokenizerRules) *Tokenizer {
-	return &Tokenizer{
-		input:          input,
-		line:           1,
-		column:         1,
-		tokens:         make([]*Token, 0),
-		expectingStack: make([][]string, 0),
-		rules:          rules,
-	}
+	return New(input, &Options{Rules: rules})
 }
 
-// Helper methods to access rules with fallback to global variables
+// SetAnnotateContext controls whether each emitted token is annotated with
+// the start tokens that enclose it (the `context` field).
+func (t *Tokenizer) SetAnnotateContext(enabled bool) {
+	t.annotateContext = enabled
+}
 
-// pushExpecting pushes a new set of expected tokens onto the stack.
-func (t *Tokenizer) pushExpecting(expected []string) {
-	t.expectingStack = append(t.expectingStack, expected)
+// pushExpecting pushes a new start token and its expected tokens onto the stack.
+func (t *Tokenizer) pushExpecting(start string, expected []string) {
+	t.expectingStack = append(t.expectingStack, expectingFrame{start: start, expecting: expected})
 }
 
 // popExpecting removes the top set of expected tokens from the stack.
@@ -107,7 +156,7 @@ func (t *Tokenizer) popExpecting() {
 
 func (t *Tokenizer) replaceExpecting(expected []string) {
 	if len(t.expectingStack) > 0 {
-		t.expectingStack[len(t.expectingStack)-1] = expected
+		t.expectingStack[len(t.expectingStack)-1].expecting = expected
 	}
 }
 
@@ -116,7 +165,34 @@ func (t *Tokenizer) getCurrentlyExpected() []string {
 	if len(t.expectingStack) == 0 {
 		return nil
 	}
-	return t.expectingStack[len(t.expectingStack)-1]
+	return t.expectingStack[len(t.expectingStack)-1].expecting
+}
+
+// wildcardCandidates returns the currently expected tokens that a wildcard
+// can stand for, which are the start, end and bridge tokens, in order of
+// priority.
+func (t *Tokenizer) wildcardCandidates() []string {
+	var candidates []string
+	for _, expected := range t.getCurrentlyExpected() {
+		switch t.rules.TokenLookup[expected].Type {
+		case CustomStart, CustomEnd, CustomBridge:
+			candidates = append(candidates, expected)
+		}
+	}
+	return candidates
+}
+
+// currentContext returns the texts of the currently open start tokens,
+// outermost first, or nil if there are none.
+func (t *Tokenizer) currentContext() []string {
+	if len(t.expectingStack) == 0 {
+		return nil
+	}
+	context := make([]string, len(t.expectingStack))
+	for i, frame := range t.expectingStack {
+		context[i] = frame.start
+	}
+	return context
 }
 
 // addTokenAndManageStack adds a token to the tokens slice and manages the expecting stack.
@@ -125,43 +201,70 @@ func (t *Tokenizer) addTokenAndManageStack(token *Token) error {
 	if token.Type == NumericLiteralTokenType {
 		if valid, reason := token.isValidNumber(); !valid {
 			// Replace the token with an exception token
-			exceptionToken := NewExceptionToken(token.Text, "invalid numeric literal: "+reason, token.Span)
+			exceptionToken := t.arena.alloc(NewExceptionToken(token.Text, "invalid numeric literal: "+reason, token.Span))
 			t.tokens = append(t.tokens, exceptionToken)
-			return fmt.Errorf("tokenisation error at line %d, column %d: %s",
-				exceptionToken.Span.Start.Line, exceptionToken.Span.Start.Col, *exceptionToken.Reason)
+			return errorAt(exceptionToken.Span, "%s", *exceptionToken.Reason)
+		}
+		if t.numericSign != NumericSignSeparate {
+			t.applyNumericSign(token)
+		}
+		if t.maxIntegerBits > 0 || t.maxExponent > 0 {
+			t.checkNumberRange(token)
 		}
 	}
 
-	// Check for newlines after this token's position
-	savedPosition := t.position
-	savedLine := t.line
-	savedColumn := t.column
-	sawNewlineAfter := t.skipWhitespaceAndComments()
-	t.position = savedPosition // Restore position since we're just peeking ahead
-	t.line = savedLine
-	t.column = savedColumn
-	if sawNewlineAfter {
-		token.LnAfter = &sawNewlineAfter
+	if token.Type == BridgeTokenType && t.bridgeCheck != BridgeCheckOff {
+		if problem := t.misplacedBridge(token); problem != "" {
+			if t.bridgeCheck == BridgeCheckError {
+				// Replace the token with an exception token
+				exceptionToken := t.arena.alloc(NewExceptionToken(token.Text, problem, token.Span))
+				t.tokens = append(t.tokens, exceptionToken)
+				return errorAt(exceptionToken.Span, "%s", problem)
+			}
+			t.warn(token.Span.Start, token.Text, problem)
+		}
+	}
+
+	if t.rules.Style != nil {
+		t.checkStyle(token)
 	}
 
+	if err := t.checkTokenCount(token); err != nil {
+		return err
+	}
 	t.tokens = append(t.tokens, token)
+	t.attachDoc(token)
+	t.attachFile(token)
 
-	// If this is an exception token, stop processing
+	// If this is an exception token, stop processing, unless keeping going
 	if token.Type == ExceptionTokenType {
-		return fmt.Errorf("tokenisation error at line %d, column %d: %s",
-			token.Span.Start.Line, token.Span.Start.Col, *token.Reason)
+		if !t.keepGoing {
+			return errorAt(token.Span, "%s", *token.Reason)
+		}
+		t.keepGoingPast(token)
+		return nil
+	}
+
+	// The context is captured before the stack changes, so that a start token
+	// reports the context that encloses it rather than itself. End tokens are
+	// handled after the pop below so that they match their start token.
+	if t.annotateContext && token.Type != EndTokenType {
+		token.Context = t.currentContext()
 	}
 
-	// Manage the expecting stack based on token type and text
+	// Manage the expecting stack based on token type and text. Every start
+	// token is pushed, even one with nothing to expect, so that its end token
+	// does not pop the frame of an enclosing form.
 	switch token.Type {
 	case StartTokenType:
-		// Push expected tokens for this start token
-		if len(token.Expecting) > 0 {
-			t.pushExpecting(token.Expecting)
-		}
+		// A wildcard is known by the start token it stands for.
+		t.pushExpecting(token.CanonicalText(), token.Expecting)
 	case EndTokenType:
 		// Pop the expecting stack
 		t.popExpecting()
+		if t.annotateContext {
+			token.Context = t.currentContext()
+		}
 	case BridgeTokenType:
 		// Update expecting for bridge tokens based on their attributes
 		if token.Expecting != nil {
@@ -169,17 +272,138 @@ func (t *Tokenizer) addTokenAndManageStack(token *Token) error {
 			t.replaceExpecting(token.Expecting)
 		}
 	}
+	t.traceStack()
+	if t.keywordArgs {
+		t.trackBrackets(token)
+	}
+	if len(t.quasiStack) > 0 {
+		return t.trackQuasi()
+	}
 	return nil
 }
 
 // Tokenize processes the input and returns a slice of tokens.
 func (t *Tokenizer) Tokenize() ([]*Token, error) {
+	t.handedOut = true
+	err := t.run(nil)
+	t.applyTransforms()
+	t.internTokens(t.tokens)
+	return t.tokens, err
+}
+
+// TokenizeValues processes the input like Tokenize, but returns the tokens as
+// a single contiguous slice of values. Because no pointers into the
+// tokenizer's own storage are returned, that storage can be reused after a
+// Reset, which makes this the cheaper choice when tokenizing many inputs.
+func (t *Tokenizer) TokenizeValues() ([]Token, error) {
+	err := t.run(nil)
+	t.applyTransforms()
+	t.internTokens(t.tokens)
+	values := make([]Token, len(t.tokens))
+	for i, token := range t.tokens {
+		values[i] = *token
+		values[i].Subtokens = detachTokens(token.Subtokens)
+	}
+	return values, err
+}
+
+// detachTokens returns copies of tokens, and of their subtokens in turn, that
+// do not point into the arena, so that they survive it being recycled.
+func detachTokens(tokens []*Token) []*Token {
+	if tokens == nil {
+		return nil
+	}
+	copies := make([]Token, len(tokens))
+	detached := make([]*Token, len(tokens))
+	for i, token := range tokens {
+		copies[i] = *token
+		copies[i].Subtokens = detachTokens(token.Subtokens)
+		detached[i] = &copies[i]
+	}
+	return detached
+}
+
+// run tokenizes the remaining input, stopping at the first error. If step is
+// not nil it is called after each step, and an error from it also stops.
+func (t *Tokenizer) run(step func() error) error {
+	if t.optionsErr != nil {
+		return t.optionsErr
+	}
+	if err := t.checkInputSize(); err != nil {
+		return err
+	}
 	for t.position < len(t.input) {
 		if err := t.nextToken(); err != nil {
-			return t.tokens, err
+			return err
 		}
+		if t.strictErr != nil {
+			return t.strictErr
+		}
+		t.reportProgress()
+		if step != nil {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := t.unclosedQuasi(); err != nil {
+		return err
+	}
+	if t.maxLineLength > 0 {
+		// The last line has no newline to trigger the check.
+		t.checkLineLength()
+		if t.strictErr != nil {
+			return t.strictErr
+		}
+	}
+	if t.endOfFile {
+		t.addEndOfFile()
+	}
+	if t.onProgress != nil {
+		t.onProgress(len(t.input), len(t.input))
+	}
+	return t.keepGoingErr
+}
+
+// Reset prepares the tokenizer to process a new input with the same rules and
+// settings. Internal buffers are kept for reuse, except those that have been
+// returned to the caller by Tokenize.
+func (t *Tokenizer) Reset(input string) {
+	t.input = input
+	t.position = 0
+	t.line = 1
+	t.column = 1
+	t.operatorRunStart = 0
+	t.operatorRunEnd = 0
+	t.interpolationDepth = 0
+	t.lineOffset = 0
+	t.file = ""
+	t.remaps = nil
+	t.strictErr = nil
+	t.keepGoingErr = nil
+	t.newlineAtEnd = false
+	t.diagnostics = nil
+	t.progressReported = 0
+	t.lengthChecked = 0
+	t.markStack = t.markStack[:0]
+	t.lineNoStack = t.lineNoStack[:0]
+	t.lineColStack = t.lineColStack[:0]
+	t.expectingStack = t.expectingStack[:0]
+	t.quasiStack = t.quasiStack[:0]
+	t.brackets = t.brackets[:0]
+	t.doc = t.doc[:0]
+	t.trivia = t.trivia[:0]
+	if t.handedOut {
+		// The caller may still be holding the previous tokens, so neither the
+		// slice nor the arena behind it can be reused.
+		t.tokens = make([]*Token, 0)
+		t.arena.release()
+		t.handedOut = false
+	} else {
+		clear(t.tokens)
+		t.tokens = t.tokens[:0]
+		t.arena.recycle()
 	}
-	return t.tokens, nil
 }
 
 // nextToken processes the next token from the input.
@@ -187,83 +411,120 @@ func (t *Tokenizer) nextToken() error {
 	// Skip whitespace and comments, tracking if we saw a newline
 	sawNewlineBefore := t.skipWhitespaceAndComments()
 
+	// A newline before this token is also a newline after the previous one,
+	// which saves scanning the same whitespace twice. This is done before
+	// checking for the end of input so that a newline at the end of the
+	// input is recorded as one after the last token.
+	if sawNewlineBefore && len(t.tokens) > 0 {
+		sawNewlineAfter := true
+		t.tokens[len(t.tokens)-1].LnAfter = &sawNewlineAfter
+	}
+
+	// The trivia is added only now, so that the token above is the last
+	// one that was not trivia.
+	if err := t.flushTrivia(); err != nil {
+		return err
+	}
+
 	if t.position >= len(t.input) {
+		t.newlineAtEnd = sawNewlineBefore
 		return nil
 	}
 
-	start := Position{Line: t.line, Col: t.column}
+	start := t.here()
+	t.traceStart()
 
-	// Try to match different token types
-	{
-		token, err := t.matchString()
+	// Try each matcher in turn. Each matcher is responsible for giving the
+	// token it returns a complete span.
+	for _, m := range t.matchers {
+		token, err := m.match(t)
 		if err != nil {
+			t.tracef("  %s: %v", m.name, err)
 			return err
 		}
+		t.traceMatcher(m.name, token)
 		if token != nil {
-			token.Span.Start = start
 			if sawNewlineBefore {
 				token.LnBefore = &sawNewlineBefore
 			}
-			return t.addTokenAndManageStack(token)
-		}
-	}
-
-	if token := t.matchNumeric(); token != nil {
-		token.Span.Start = start
-		if sawNewlineBefore {
-			token.LnBefore = &sawNewlineBefore
-		}
-		return t.addTokenAndManageStack(token)
-	}
-
-	// Check custom rules first - they take precedence over defaults
-	if token := t.matchCustomRules(); token != nil {
-		token.Span.Start = start
-		if sawNewlineBefore {
-			token.LnBefore = &sawNewlineBefore
+			if err := t.addTokenAndManageStack(token); err != nil {
+				return err
+			}
+			return t.addExpansion()
 		}
-		return t.addTokenAndManageStack(token)
 	}
 
-	// If nothing matches, create an unclassified token
-	r, size := utf8.DecodeRuneInString(t.input[t.position:])
-	text := string(r)
-	end := Position{Line: t.line, Col: t.column + size}
-	span := Span{Start: start, End: end}
+	// If nothing matches, create an unclassified token. The text is taken
+	// from the input rather than the decoded rune so that an invalid byte is
+	// kept as it is.
+	_, size := utf8.DecodeRuneInString(t.input[t.position:])
+	text := t.input[t.position : t.position+size]
+	t.advance(size)
 
-	token := NewToken(text, UnclassifiedTokenType, span)
+	token := t.arena.alloc(NewToken(text, UnclassifiedTokenType, t.spanFrom(start)))
+	t.traceMatcher("fallback", token)
 	if sawNewlineBefore {
 		token.LnBefore = &sawNewlineBefore
 	}
-	t.advance(size)
 	return t.addTokenAndManageStack(token)
 }
 
 // skipWhitespaceAndComments advances past whitespace characters and comments.
 // Returns true if a newline (LF or CR) was encountered in the skipped content.
+// In lossless mode the skipped text is kept as trivia tokens.
 func (t *Tokenizer) skipWhitespaceAndComments() bool {
 	sawNewline := false
 
 	for t.position < len(t.input) {
-		// Check for comments first
+		start := t.here()
+		from := t.position
+
+		// Check for doc comments, which are kept for the next token
+		if strings.HasPrefix(t.input[t.position:], t.docMarker) {
+			line := t.input[t.position:]
+			if end := strings.IndexAny(line, "\r\n"); end >= 0 {
+				line = line[:end]
+			}
+			t.advance(len(line))
+			t.doc = append(t.doc, docCommentText(line, t.docMarker))
+			t.keepTrivia(CommentTokenType, line, start)
+			continue
+		}
+
+		// Check for comments
 		if match := commentRegex.FindString(t.input[t.position:]); match != "" {
 			t.advance(len(match))
-			sawNewline = true // End-of-line comments always include a newline conceptually
+			t.keepTrivia(CommentTokenType, match, start)
+			t.applyLineDirective(match, start)
+			// A comment runs up to the line break, if there is one, which
+			// is then found as whitespace. One that ends the input has none.
 			continue
 		}
 
-		// Check for whitespace
-		r, size := utf8.DecodeRuneInString(t.input[t.position:])
-		if !unicode.IsSpace(r) {
-			break
+		// Check for a run of whitespace, noting where any spaces at the end
+		// of a line begin
+		spacesFrom, spacesStart := -1, Position{}
+		for t.position < len(t.input) {
+			r, size := utf8.DecodeRuneInString(t.input[t.position:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			// Check if this whitespace character is a newline
+			if r == '\n' || r == '\r' {
+				sawNewline = true
+				if spacesFrom >= 0 && t.warnTrailing {
+					t.warn(spacesStart, t.input[spacesFrom:t.position], "trailing whitespace")
+				}
+				spacesFrom = -1
+			} else if spacesFrom < 0 {
+				spacesFrom, spacesStart = t.position, t.here()
+			}
+			t.advance(size)
 		}
-
-		// Check if this whitespace character is a newline
-		if r == '\n' || r == '\r' {
-			sawNewline = true
+		if t.position == from {
+			break
 		}
-
-		t.advance(size)
+		t.keepTrivia(WhitespaceTokenType, t.input[from:t.position], start)
 	}
 
 	return sawNewline
@@ -271,174 +532,156 @@ func (t *Tokenizer) skipWhitespaceAndComments() bool {
 
 // matchNumeric attempts to match a numeric literal.
 func (t *Tokenizer) matchNumeric() *Token {
+	start := t.here()
+
 	// First try to match radix-based numbers (must check before decimal)
 	if radixMatch := radixRegex.FindStringSubmatch(t.input[t.position:]); radixMatch != nil {
-		return t.parseRadixNumber(radixMatch)
+		if literal, reason := misplacedUnderscore(radixMatch[0], t.input[t.position+len(radixMatch[0]):]); reason != "" {
+			return t.createExceptionToken(start, literal, reason)
+		}
+		return t.parseRadixNumber(start, radixMatch)
 	}
 
 	// Then try to match decimal numbers
 	if decimalMatch := decimalRegex.FindStringSubmatch(t.input[t.position:]); decimalMatch != nil {
-		return t.parseDecimalNumber(decimalMatch)
+		if literal, reason := misplacedUnderscore(decimalMatch[0], t.input[t.position+len(decimalMatch[0]):]); reason != "" {
+			return t.createExceptionToken(start, literal, reason)
+		}
+		return t.parseDecimalNumber(start, decimalMatch)
 	}
 
 	return nil
 }
 
 // parseRadixNumber parses a number with radix notation (e.g., 0x, 0o, 0b, 0t, or nr).
-func (t *Tokenizer) parseRadixNumber(match []string) *Token {
+func (t *Tokenizer) parseRadixNumber(start Position, match []string) *Token {
 	fullMatch := match[0]
 	radixPart := match[1]
-	mantissa := match[2]
-	fraction := ""
-	exponent := ""
-
-	if len(match) > 3 && match[3] != "" {
-		fraction = match[3][1:] // Remove the leading dot
-	}
-	if len(match) > 4 && match[4] != "" {
-		exponent = match[4] // Already without the 'e'
-	}
 
 	// Extract radix prefix and determine base
 	lastChar := radixPart[len(radixPart)-1]
-	radixPrefix := ""
+	radixPrefix := radixPart
 	var base int
 
 	switch lastChar {
 	case 'x':
-		if radixPart == "0x" {
-			radixPrefix = "0x"
-			base = 16
-		} else {
+		if radixPart != "0x" {
 			// Invalid hex format - should be 0x
-			return t.createExceptionToken(fullMatch, "invalid literal")
+			return t.createExceptionToken(start, fullMatch, "invalid literal")
 		}
+		base = 16
 	case 'o':
-		if radixPart == "0o" {
-			radixPrefix = "0o"
-			base = 8
-		} else {
+		if radixPart != "0o" {
 			// Invalid octal format - should be 0o
-			return t.createExceptionToken(fullMatch, "invalid literal")
+			return t.createExceptionToken(start, fullMatch, "invalid literal")
 		}
+		base = 8
 	case 'b':
-		if radixPart == "0b" {
-			radixPrefix = "0b"
-			base = 2
-		} else {
+		if radixPart != "0b" {
 			// Invalid binary format - should be 0b
-			return t.createExceptionToken(fullMatch, "invalid literal")
+			return t.createExceptionToken(start, fullMatch, "invalid literal")
 		}
+		base = 2
 	case 't':
-		if radixPart == "0t" {
-			// Handle balanced ternary
-			mantissa = strings.ReplaceAll(mantissa, "_", "")
-			if fraction != "" {
-				fraction = strings.ReplaceAll(fraction, "_", "")
-			}
-
-			end := Position{Line: t.line, Col: t.column + len(fullMatch)}
-			span := Span{End: end}
-			t.advance(len(fullMatch))
-
-			exponentVal := 0
-			if exponent != "" {
-				var err error
-				exponentVal, err = strconv.Atoi(exponent)
-				if err != nil {
-					return t.createExceptionToken(fullMatch, fmt.Sprintf("invalid literal: %s", exponent))
-				}
-			}
-			return NewBalancedTernaryToken(fullMatch, mantissa, fraction, exponentVal, span)
-		} else {
+		if radixPart != "0t" {
 			// Invalid ternary format - should be 0t
-			return t.createExceptionToken(fullMatch, "invalid literal")
+			return t.createExceptionToken(start, fullMatch, "invalid literal")
 		}
+		// Balanced ternary is base 3 with the digits T, 0 and 1.
+		base = 3
 	case 'r':
 		// Parse the radix number (e.g., "2r", "16r", "36r")
 		radixStr := radixPart[:len(radixPart)-1]
-		radixPrefix = radixPart
 
 		parsedRadix := 0
 		for _, digit := range radixStr {
 			if digit >= '0' && digit <= '9' {
 				parsedRadix = parsedRadix*10 + int(digit-'0')
 			} else {
-				return t.createExceptionToken(fullMatch, "invalid literal")
+				return t.createExceptionToken(start, fullMatch, "invalid literal")
 			}
 		}
 
 		if parsedRadix < 2 || parsedRadix > 36 {
-			return t.createExceptionToken(fullMatch, "invalid literal")
+			return t.createExceptionToken(start, fullMatch, "invalid literal")
 		}
 
 		base = parsedRadix
 	default:
-		return t.createExceptionToken(fullMatch, "invalid literal")
-	}
-
-	// Remove underscores from mantissa and fraction
-	mantissa = strings.ReplaceAll(mantissa, "_", "")
-	if fraction != "" {
-		fraction = strings.ReplaceAll(fraction, "_", "")
+		return t.createExceptionToken(start, fullMatch, "invalid literal")
 	}
 
-	end := Position{Line: t.line, Col: t.column + len(fullMatch)}
-	span := Span{End: end}
-	t.advance(len(fullMatch))
-
-	exponentVal := 0
-	if exponent != "" {
-		var err error
-		exponentVal, err = strconv.Atoi(exponent)
-		if err != nil {
-			return t.createExceptionToken(fullMatch, "invalid literal")
-		}
-	}
-	return NewNumericToken(fullMatch, radixPrefix, base, mantissa, fraction, exponentVal, span)
+	return t.finishNumber(start, radixRegex, len(fullMatch), radixPrefix, base)
 }
 
 // parseDecimalNumber parses a decimal number.
-func (t *Tokenizer) parseDecimalNumber(match []string) *Token {
-	fullMatch := match[0]
-	mantissa := match[1]
-	fraction := ""
-	exponent := ""
+func (t *Tokenizer) parseDecimalNumber(start Position, match []string) *Token {
+	return t.finishNumber(start, decimalRegex, len(match[0]), "", 10)
+}
 
-	if len(match) > 2 && match[2] != "" {
-		fraction = match[2][1:] // Remove the leading dot
-	}
-	if len(match) > 3 && match[3] != "" {
-		exponent = match[3] // Already without the 'e'
+// finishNumber makes the token for a number whose digits, matched by the
+// pattern, are the first n bytes of the rest of the input, adding any
+// exponent that follows them.
+func (t *Tokenizer) finishNumber(start Position, pattern *regexp.Regexp, n int, radixPrefix string, base int) *Token {
+	n, marker, exponent := t.scanExponent(n, t.exponentMarkers(base))
+	digits := t.input[t.position : t.position+n]
+	fullMatch := digits + marker + exponent
+	if strings.HasSuffix(digits, "_") {
+		// The digits end before an upper case marker that the pattern took
+		// for a digit, as in 0x1_P3, and the underscore is left over.
+		return t.createExceptionToken(start, fullMatch, "invalid literal: underscore next to the exponent")
 	}
 
+	// The last two groups of either pattern are the mantissa and fraction.
+	match := pattern.FindStringSubmatch(digits)
+	mantissa := match[len(match)-2]
+	fraction := strings.TrimPrefix(match[len(match)-1], ".")
+
 	// Remove underscores from mantissa and fraction
+	rawMantissa, rawFraction := mantissa, fraction
 	mantissa = strings.ReplaceAll(mantissa, "_", "")
-	if fraction != "" {
-		fraction = strings.ReplaceAll(fraction, "_", "")
-	}
-
-	end := Position{Line: t.line, Col: t.column + len(fullMatch)}
-	span := Span{End: end}
-	t.advance(len(fullMatch))
+	fraction = strings.ReplaceAll(fraction, "_", "")
 
 	exponentVal := 0
 	if exponent != "" {
 		var err error
 		exponentVal, err = strconv.Atoi(exponent)
 		if err != nil {
-			return t.createExceptionToken(fullMatch, fmt.Sprintf("invalid literal: %s", err))
+			return t.createExceptionToken(start, fullMatch, fmt.Sprintf("invalid literal: %s", err))
 		}
 	}
-	return NewNumericToken(fullMatch, "", 10, mantissa, fraction, exponentVal, span)
+	t.advance(len(fullMatch))
+	var token *Token
+	if radixPrefix == "0t" {
+		token = t.arena.alloc(NewBalancedTernaryToken(fullMatch, mantissa, fraction, exponentVal, t.spanFrom(start)))
+	} else {
+		token = t.arena.alloc(NewNumericToken(fullMatch, radixPrefix, base, mantissa, fraction, exponentVal, t.spanFrom(start)))
+	}
+	if marker != "" {
+		expBase := exponentBase(marker)
+		token.ExpBase = &expBase
+	}
+	return withRawDigits(token, rawMantissa, rawFraction)
+}
+
+// withRawDigits records the mantissa and fraction of a numeric token as
+// written, if they have underscores in them, so that a formatter can keep the
+// grouping of the digits.
+func withRawDigits(token *Token, mantissa, fraction string) *Token {
+	if strings.Contains(mantissa, "_") {
+		token.RawMantissa = &mantissa
+	}
+	if strings.Contains(fraction, "_") {
+		token.RawFraction = &fraction
+	}
+	return token
 }
 
-// createExceptionToken creates an exception token for invalid numeric formats.
-func (t *Tokenizer) createExceptionToken(text, reason string) *Token {
-	end := Position{Line: t.line, Col: t.column + len(text)}
-	span := Span{End: end}
+// createExceptionToken creates an exception token for invalid numeric formats,
+// consuming text and spanning it from start.
+func (t *Tokenizer) createExceptionToken(start Position, text, reason string) *Token {
 	t.advance(len(text))
-	return NewExceptionToken(text, reason, span)
+	return t.arena.alloc(NewExceptionToken(text, reason, t.spanFrom(start)))
 }
 
 // matchCustomRules checks for any custom rules that match at the current position.
@@ -449,79 +692,155 @@ func (t *Tokenizer) matchCustomRules() *Token {
 	}
 
 	// Check for alphanumeric + underbar sequences
-	// fmt.Println("Custom rules check at position", t.position, "char:", string(t.input[t.position]))
 	is_identifier, text, ok := nextIdOrOp(t)
 	if !ok {
 		return nil
 	}
 
-	// fmt.Println("Custom rules token text:", text)
-	// fmt.Println("is_identifier?", is_identifier)
-
-	end := Position{Line: t.line, Col: t.column + len(text)}
-	span := Span{End: end}
-
 	// Efficient lookup - single map access
 	entry, exists := t.rules.TokenLookup[text]
-	if !exists {
-		if is_identifier {
+	if !exists && is_identifier && t.rules.keywordsByFold != nil {
+		if keyword, ok := t.rules.keywordsByFold[strings.ToLower(text)]; ok {
+			entry, exists = t.rules.TokenLookup[keyword], true
+			if entry.Alias == "" {
+				entry.Alias = keyword
+			}
+		}
+	}
+	if exists && entry.Type == CustomDefine && t.expanding {
+		// Defines are not expanded within replacements, so there the text
+		// stands for itself.
+		exists = false
+	}
+	if !exists && !is_identifier {
+		t.tracef("  rules: no entry for %q", text)
+		if t.position == t.operatorRunStart && t.position < t.operatorRunEnd {
+			// Only warn at the start of the run, not again for the rest of
+			// it as it is split into single characters.
+			t.warn(t.here(), text, "sign characters are not a known operator")
+		}
+		return nil // No matching custom rule
+	}
+	if exists {
+		t.tracef("  rules: entry for %q (%s)", text, entry.Type)
+	} else {
+		t.tracef("  rules: no entry for identifier %q", text)
+	}
 
-			// If it's an identifier and no special type, treat as VariableToken
+	if !exists && is_identifier {
+		if token := t.matchLabel(text); token != nil {
+			return token
+		}
+		if token := t.matchKeywordArg(text); token != nil {
+			return token
+		}
+		if parts := t.qualifiedParts(text); parts != nil {
+			start := t.here()
+			end := t.position + len(text)
+			for _, part := range parts[1:] {
+				end += len(t.qualifiedSep) + len(part)
+			}
+			text = t.input[t.position:end]
 			t.advance(len(text))
-			return NewToken(text, VariableTokenType, span)
+			token := t.arena.alloc(NewToken(text, VariableTokenType, t.spanFrom(start)))
+			token.Parts = parts
+			return token
 		}
-		return nil // No matching custom rule
 	}
 
-	// Process the single rule entry
+	// From here on the text is always consumed as a single token.
+	start := t.here()
+	t.advance(len(text))
+	span := t.spanFrom(start)
+
+	if !exists {
+		// If it's an identifier and no special type, treat as VariableToken
+		return t.arena.alloc(NewToken(text, VariableTokenType, span))
+	}
+
+	token := t.ruleToken(text, entry, start, span)
+	if entry.Alias != "" && token != nil && token.Alias == nil {
+		// A translation, or a keyword in another case, is known by the
+		// token it stands for, as a wildcard is.
+		alias := entry.Alias
+		token.Alias = &alias
+	}
+	if t.annotateSource && token != nil {
+		source := &RuleSource{Section: entry.Type.String(), Origin: OriginDefault}
+		if entry.Custom {
+			source.Origin = OriginCustom
+		}
+		token.RuleSource = source
+		// The rest of the tokens of a define's replacement are waiting to
+		// be added, and came from the same rule.
+		for _, expanded := range t.expansion {
+			expanded.RuleSource = source
+		}
+	}
+	return token
+}
+
+// ruleToken makes the token for text, which starts at start, from the rule
+// entry that matched it.
+func (t *Tokenizer) ruleToken(text string, entry CustomRuleEntry, start Position, span Span) *Token {
 	switch entry.Type {
 	case CustomWildcard:
-		// Check if we have context from the expecting stack
-		expected := t.getCurrentlyExpected()
-		if len(expected) > 0 {
-			// Use the first expected token as the basis for the wildcard
-			expectedText := expected[0]
-
-			// Check if it's a bridge token
-			if bridgeData, exists := t.rules.BridgeTokens[expectedText]; exists {
-				// Create a wildcard token that copies attributes from the expected bridge
-				t.advance(len(text))
-				return NewWildcardBridgeToken(text, expectedText, bridgeData.Expecting, bridgeData.In, bridgeData.Arity, span)
+		// The wildcard stands for one of the keywords expected at this
+		// point, so the order of an expecting list sets their priority.
+		candidates := t.wildcardCandidates()
+		if len(candidates) > 0 {
+			expectedText := candidates[0]
+			if len(candidates) > 1 && t.warnAmbiguous {
+				t.warn(start, text, fmt.Sprintf("wildcard could stand for any of %s, so stands for the first",
+					strings.Join(candidates, ", ")))
+			}
+			t.tracef("  rules: wildcard %q stands for expected %q", text, expectedText)
+			// Create a wildcard token that copies attributes from the expected keyword
+			expectedEntry := t.rules.TokenLookup[expectedText]
+			switch expectedEntry.Type {
+			case CustomStart:
+				startData := expectedEntry.Data.(StartTokenData)
+				return t.arena.alloc(NewWildcardStartToken(text, expectedText, startData.Expecting, startData.ClosedBy, startData.Arity, span))
+			case CustomEnd:
+				return t.arena.alloc(NewWildcardEndToken(text, expectedText, expectedEntry.Data.([]string), span))
 			}
+			bridgeData := expectedEntry.Data.(BridgeTokenData)
+			return t.arena.alloc(NewWildcardBridgeToken(text, expectedText, bridgeData.Expecting, bridgeData.In, bridgeData.Arity, span))
 		}
 
 		// No context available, create unclassified token
-		t.advance(len(text))
-		return NewToken(text, UnclassifiedTokenType, span)
+		t.warn(start, text, "wildcard has no expected label to stand for")
+		return t.arena.alloc(NewToken(text, UnclassifiedTokenType, span))
 
 	case CustomStart:
 		startData := entry.Data.(StartTokenData)
-		t.advance(len(text))
-		return NewStartToken(text, startData.Expecting, startData.ClosedBy, span, startData.Arity)
+		return t.arena.alloc(NewStartToken(text, startData.Expecting, startData.ClosedBy, span, startData.Arity))
 
 	case CustomEnd:
-		t.advance(len(text))
-		return NewToken(text, EndTokenType, span)
+		return t.arena.alloc(NewEndToken(text, entry.Data.([]string), span))
 
 	case CustomBridge:
 		bridgeData := entry.Data.(BridgeTokenData)
-		t.advance(len(text))
-		return NewStmntBridgeToken(text, bridgeData.Expecting, bridgeData.In, span)
+		return t.arena.alloc(NewStmntBridgeToken(text, bridgeData.Expecting, bridgeData.In, span))
 
 	case CustomPrefix:
 		prefixData := entry.Data.(PrefixTokenData)
-
-		t.advance(len(text))
-		return NewPrefixToken(text, PrefixTokenType, span, prefixData.Arity)
+		return t.arena.alloc(NewPrefixToken(text, PrefixTokenType, span, prefixData.Arity))
 
 	case CustomMark:
-		t.advance(len(text))
-		return NewToken(text, MarkTokenType, span)
+		token := t.arena.alloc(NewToken(text, MarkTokenType, span))
+		token.Role = entry.Data.(string)
+		return token
+
+	case CustomSigil:
+		return t.sigilToken(text, entry.Data.(string), start, span)
+
+	case CustomDefine:
+		return t.expandDefine(text, entry.Data.(string), span)
 
 	case CustomOperator:
 		precedence := entry.Data.([3]int)
-		t.advance(len(text))
-		return NewOperatorToken(text, precedence[0], precedence[1], precedence[2], span)
+		return t.arena.alloc(NewOperatorToken(text, precedence[0], precedence[1], precedence[2], span))
 
 	case CustomOpenDelimiter:
 		delimiterData := entry.Data.(struct {
@@ -529,12 +848,10 @@ func (t *Tokenizer) matchCustomRules() *Token {
 			InfixPrec int
 			IsPrefix  bool
 		})
-		t.advance(len(text))
-		return NewDelimiterToken(text, delimiterData.ClosedBy, delimiterData.InfixPrec, delimiterData.IsPrefix, span)
+		return t.arena.alloc(NewDelimiterToken(text, delimiterData.ClosedBy, delimiterData.InfixPrec, delimiterData.IsPrefix, span))
 
 	case CustomCloseDelimiter:
-		t.advance(len(text))
-		return NewToken(text, CloseDelimiterTokenType, span)
+		return t.arena.alloc(NewCloseDelimiterToken(text, entry.Data.([]string), span))
 	}
 
 	return nil
@@ -546,34 +863,92 @@ func (t *Tokenizer) matchCustomRules() *Token {
 // - The matched text.
 // - A boolean indicating if a match was found.
 func nextIdOrOp(t *Tokenizer) (bool, string, bool) {
-	if match := identifierRegex.FindString(t.input[t.position:]); match != "" {
-		text := match
-		return true, text, true
+	for _, symbol := range t.rules.symbols {
+		if strings.HasPrefix(t.input[t.position:], symbol) {
+			return false, symbol, true
+		}
+	}
+	if match := t.matchIdentifier(t.input[t.position:]); match != "" {
+		return true, match, true
+	}
+	if t.operatorRunStart < t.position && t.position < t.operatorRunEnd {
+		// Still inside a run of sign characters that was not a known
+		// operator, so the match is taken from the rest of the run.
+		// Rescanning it at every position would make long runs take
+		// quadratic time.
+		rest := t.input[t.position:t.operatorRunEnd]
+		if text := t.munch(rest); text != "" {
+			return false, text, true
+		}
+		return false, rest, true
 	}
 	if match := operatorRegex.FindString(t.input[t.position:]); match != "" {
 		// Check for sign character sequences
-		text := match
+		text := t.munch(match)
+		if text == "" {
+			text = t.cutAtMark(match)
+		}
+		t.operatorRunStart = t.position
+		t.operatorRunEnd = t.position + len(match)
 		return false, text, true
 	}
 	if t.position < len(t.input) {
 		// Everything else is a single character
-		r, _ := utf8.DecodeRuneInString(t.input[t.position:])
-		text := string(r)
+		_, size := utf8.DecodeRuneInString(t.input[t.position:])
+		text := t.input[t.position : t.position+size]
 		return false, text, true
 	}
 	return false, "", false
 }
 
+// munch returns the longest start of a run of sign characters that is a token
+// of the rules, so that :=- is read as := and -, whatever kind of token each
+// is. It returns "" if no start of the run is a token.
+func (t *Tokenizer) munch(run string) string {
+	for n := min(len(run), t.rules.longestSignToken); n > 0; n-- {
+		if _, ok := t.rules.TokenLookup[run[:n]]; ok {
+			return run[:n]
+		}
+	}
+	return ""
+}
+
+// cutAtMark cuts a run of sign characters that has no start that is a token
+// of the rules where a mark of sign characters starts in it, so that the mark
+// is read as a token of its own, as | is in ~| when it is a mark.
+func (t *Tokenizer) cutAtMark(run string) string {
+	// Sign characters are ASCII, so every byte offset starts a character. A
+	// mark at the start would have been found by munch.
+	for i := 1; i < len(run); i++ {
+		for _, mark := range t.rules.signMarks {
+			if strings.HasPrefix(run[i:], mark) {
+				return run[:i]
+			}
+		}
+	}
+	return run
+}
+
 // advance moves the position forward and updates line/column tracking.
+// Unless columns count bytes, it moves a whole character at a time, so n
+// should end on a character boundary, as the texts of tokens do.
 func (t *Tokenizer) advance(n int) {
-	for i := 0; i < n && t.position < len(t.input); i++ {
+	for end := t.position + n; t.position < end && t.position < len(t.input); {
 		if t.input[t.position] == '\n' {
+			if t.maxLineLength > 0 {
+				t.checkLineLength()
+			}
 			t.line++
 			t.column = 1
-		} else {
-			t.column++
+			t.position++
+			continue
+		}
+		size := 1
+		if t.columnPolicy != ColumnBytes {
+			_, size = utf8.DecodeRuneInString(t.input[t.position:])
 		}
-		t.position++
+		t.column += t.columnPolicy.width(t.input[t.position : t.position+size])
+		t.position += size
 	}
 }
 
@@ -617,11 +992,11 @@ func (t *Tokenizer) tryPeekTripleQuotes(is_opening bool) (rune, bool) {
 		return 0, false // End of input
 	}
 	if is_opening {
-		if !isOpeningQuoteChar(r1) {
+		if !t.isOpeningQuote(r1) {
 			return 0, false // Invalid opening quote character
 		}
 	} else {
-		if !isClosingQuoteChar(r1) {
+		if !t.isClosingQuote(r1) {
 			return 0, false // Invalid closing quote character
 		}
 	}
@@ -662,12 +1037,20 @@ func (t *Tokenizer) peekN(n int) (rune, bool) {
 	return r, true
 }
 
-func isOpeningQuoteChar(r rune) bool {
-	return r == '\'' || r == '"' || r == '`' || r == '«'
+// quoteData returns the attributes of the quote character r, and whether the
+// rules make it a quote at all.
+func (t *Tokenizer) quoteData(r rune) (QuoteData, bool) {
+	data, ok := t.rules.quotes()[string(r)]
+	return data, ok
+}
+
+func (t *Tokenizer) isOpeningQuote(r rune) bool {
+	_, ok := t.quoteData(r)
+	return ok
 }
 
-func isClosingQuoteChar(r rune) bool {
-	return r == '\'' || r == '"' || r == '`' || r == '»'
+func (t *Tokenizer) isClosingQuote(r rune) bool {
+	return t.isOpeningQuote(r) || t.isOpeningQuote(getMatchingOpenQuote(r))
 }
 
 // Consume the current rune and advance the position
@@ -835,12 +1218,13 @@ func (t *Tokenizer) skipSpacesUpToNewline() {
 }
 
 func (t *Tokenizer) consumeTripleClosingQuotes(quote rune) error {
+	start := t.here()
 	r, b := t.tryReadTripleClosingQuotes()
 	if !b {
-		return fmt.Errorf("missing triple quotes at line %d, column %d", t.line, t.column)
+		return t.errorFrom(start, "missing triple quotes")
 	}
 	if r != quote {
-		return fmt.Errorf("expected %c, but found %c at line %d, column %d", quote, r, t.line, t.column)
+		return t.errorFrom(start, "expected %c, but found %c", quote, r)
 	}
 	return nil
 }
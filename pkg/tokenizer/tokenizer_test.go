@@ -2,8 +2,14 @@ package tokenizer
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestBasicTokenisation(t *testing.T) {
@@ -50,6 +56,9 @@ func TestStringTokens(t *testing.T) {
 		{"`backtick`", "`backtick`", "backtick"},
 		{`"escaped\n"`, `"escaped\n"`, "escaped\n"},
 		{`"quote\"test"`, `"quote\"test"`, `quote"test`},
+		{"«a «nested» string»", "«a «nested» string»", "a «nested» string"},
+		{`«escaped \» and \«»`, `«escaped \» and \«»`, "escaped » and «"},
+		{`@«raw «nested» \n»`, `@«raw «nested» \n»`, `raw «nested» \n`},
 	}
 
 	for _, tt := range tests {
@@ -88,6 +97,158 @@ func TestStringTokens(t *testing.T) {
 	}
 }
 
+func TestGuillemetStrings(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		tokenType TokenType
+		texts     []string // The texts of the subtokens, if any
+	}{
+		{"interpolation", "«a \\(f(«b «c»»)) d»", InterpolatedStringTokenType, []string{"«a ", "(f(«b «c»»))", " d»"}},
+		{"multiline", "«««\n  a «b» c\n  »»»", MultiLineStringTokenType, []string{"a «b» c\n"}},
+		{"raw multiline", "@«««\n  a «b» \\c\n  »»»", MultiLineStringTokenType, []string{"a «b» \\c\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := NewTokenizer(tt.input).Tokenize()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(tokens) != 1 {
+				t.Fatalf("Expected 1 token, got %d", len(tokens))
+			}
+			token := tokens[0]
+			if token.Type != tt.tokenType {
+				t.Errorf("Expected type %s, got %s", tt.tokenType, token.Type)
+			}
+			if token.Quote != "guillemet" {
+				t.Errorf("Expected quote 'guillemet', got '%s'", token.Quote)
+			}
+			var texts []string
+			for _, subtoken := range token.Subtokens {
+				texts = append(texts, subtoken.Text)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("Expected subtokens %q, got %q", tt.texts, texts)
+			}
+		})
+	}
+
+	if _, err := NewTokenizer("«a «b»").Tokenize(); err == nil {
+		t.Errorf("Expected an unbalanced guillemet string to be unterminated")
+	}
+}
+
+func TestMultiLineValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"escapes", "\"\"\"\n    a\\tb\n\n      c\n    \"\"\"", "a\tb\n\n  c"},
+		{"raw", "@\"\"\"\n  a\\tb\n  \"\"\"", "a\\tb"},
+		{"interpolated", "\"\"\"\n  a\\(x)\n  \"\"\"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := New(tt.input, &Options{MultiLineValues: true}).Tokenize()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if *tokens[0].Value != tt.expected {
+				t.Errorf("Expected value %q, got %q", tt.expected, *tokens[0].Value)
+			}
+		})
+	}
+
+	// Without the option the value stays empty, as it always has been.
+	tokens, err := New(tests[0].input, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *tokens[0].Value != "" {
+		t.Errorf("Expected an empty value, got %q", *tokens[0].Value)
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"indented", "\"a long \\\n    message\"", "a long message"},
+		{"crlf", "'a\\\r\nb'", "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := New(tt.input, &Options{LineContinuation: true}).Tokenize()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(tokens) != 1 || *tokens[0].Value != tt.expected {
+				t.Fatalf("Expected one string with value %q, got %q", tt.expected, tokenTexts(tokens))
+			}
+			if tokens[0].Text != tt.input {
+				t.Errorf("Expected the text to cover the whole input, got %q", tokens[0].Text)
+			}
+		})
+	}
+
+	// A blank line cannot be continued across.
+	if _, err := New("\"a\\\n\n\"", &Options{LineContinuation: true}).Tokenize(); err == nil {
+		t.Errorf("Expected a line break error after a continued blank line")
+	}
+
+	// Without the option the backslash and line break stay in the value.
+	tokens, err := NewTokenizer("\"a\\\nb\"").Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *tokens[0].Value != "a\\\nb" {
+		t.Errorf("Expected the value to keep the line break, got %q", *tokens[0].Value)
+	}
+}
+
+func TestAfterClosingTripleQuotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		texts    []string
+		warnings []string
+	}{
+		{"punctuation", "f(\"\"\"\n  a\n  \"\"\")", []string{"f", "(", "\"\"\"\n  a\n  \"\"\"", ")"}, nil},
+		{"spaced word", "\"\"\"\n  a\n  \"\"\" x", []string{"\"\"\"\n  a\n  \"\"\"", "x"}, nil},
+		{"word", "\"\"\"\n  a\n  \"\"\"x", []string{"\"\"\"\n  a\n  \"\"\"", "x"}, []string{"3:6 text directly after the closing triple quotes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(tt.input, nil).TokenizeResult()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := tokenTexts(result.Tokens); !reflect.DeepEqual(got, tt.texts) {
+				t.Errorf("Expected tokens %q, got %q", tt.texts, got)
+			}
+			var warnings []string
+			for _, d := range result.Diagnostics {
+				warnings = append(warnings, fmt.Sprintf("%d:%d %s", d.Span.Start.Line, d.Span.Start.Col, d.Message))
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("Expected warnings %v, got %v", tt.warnings, warnings)
+			}
+		})
+	}
+
+	// Another quote cannot start a string that was meant.
+	_, err := NewTokenizer("\"\"\"\n  a\n  \"\"\"\"").Tokenize()
+	if err == nil || !strings.Contains(err.Error(), "another \" after the closing triple quotes") {
+		t.Errorf("Expected an error about the extra quote, got %v", err)
+	}
+}
+
 func TestNumericTokens(t *testing.T) {
 	// Helper function to create int pointers
 	intPtr := func(i int) *int { return &i }
@@ -618,6 +779,32 @@ func TestOperatorTokens(t *testing.T) {
 	}
 }
 
+func TestOperatorMaximalMunch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"a:=-b", []string{"a", ":=", "-", "b"}},
+		{"a**b", []string{"a", "*", "*", "b"}},
+		{"a<=>b", []string{"a", "<=", ">", "b"}},
+		{"x:=+y", []string{"x", ":=", "+", "y"}},
+	}
+	for _, test := range tests {
+		tokens, err := NewTokenizer(test.input).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		if got := tokenTexts(tokens); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("For %q expected %v, got %v", test.input, test.expected, got)
+		}
+		for _, token := range tokens[1 : len(tokens)-1] {
+			if token.Type != OperatorTokenType {
+				t.Errorf("For %q expected %q to be an operator, got %s", test.input, token.Text, token.Type)
+			}
+		}
+	}
+}
+
 func TestDelimiterTokens(t *testing.T) {
 	tests := []struct {
 		input        string
@@ -625,13 +812,14 @@ func TestDelimiterTokens(t *testing.T) {
 		closedBy     []string
 		infixPrec    int
 		isPrefix     bool
+		openedBy     []string
 	}{
-		{"(", OpenDelimiterTokenType, []string{")"}, 2020, true},
-		{"[", OpenDelimiterTokenType, []string{"]"}, 2030, true},
-		{"{", OpenDelimiterTokenType, []string{"}"}, 2040, true}, // Updated: now supports infix usage for f{x} syntax
-		{")", CloseDelimiterTokenType, nil, 0, false},
-		{"]", CloseDelimiterTokenType, nil, 0, false},
-		{"}", CloseDelimiterTokenType, nil, 0, false},
+		{"(", OpenDelimiterTokenType, []string{")"}, 2020, true, nil},
+		{"[", OpenDelimiterTokenType, []string{"]"}, 2030, true, nil},
+		{"{", OpenDelimiterTokenType, []string{"}"}, 2040, true, nil}, // Updated: now supports infix usage for f{x} syntax
+		{")", CloseDelimiterTokenType, nil, 0, false, []string{"("}},
+		{"]", CloseDelimiterTokenType, nil, 0, false, []string{"["}},
+		{"}", CloseDelimiterTokenType, nil, 0, false, []string{"{"}},
 	}
 
 	for _, tt := range tests {
@@ -673,10 +861,33 @@ func TestDelimiterTokens(t *testing.T) {
 					t.Errorf("Expected prefix %t, got %v", tt.isPrefix, token.Prefix)
 				}
 			}
+
+			if !reflect.DeepEqual(token.OpenedBy, tt.openedBy) {
+				t.Errorf("Expected opened by %v, got %v", tt.openedBy, token.OpenedBy)
+			}
 		})
 	}
 }
 
+func TestCloseDelimiterOpenedBy(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Bracket: []BracketRule{
+			{Text: "(", ClosedBy: []string{")", "]"}},
+			{Text: "[", ClosedBy: []string{"]"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tokens, err := New("(x]", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokens[2].OpenedBy; !reflect.DeepEqual(got, []string{"(", "["}) {
+		t.Errorf("Expected ] to be opened by ( and [, got %v", got)
+	}
+}
+
 func TestKeywordClassification(t *testing.T) {
 	tests := []struct {
 		input        string
@@ -832,6 +1043,91 @@ func TestCustomRulesWildcard(t *testing.T) {
 	}
 }
 
+func TestWildcardEndToken(t *testing.T) {
+	tests := []struct {
+		input    string
+		endText  string
+		endAlias string // The keyword the end token stands for, if a wildcard
+	}{
+		{"def f(x): x end", "end", ""},
+		{"def f(x): x :", ":", "end"},
+	}
+	for _, test := range tests {
+		tokens, err := NewTokenizer(test.input).Tokenize()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.input, err)
+		}
+		if len(tokens) != 8 {
+			t.Fatalf("%s: expected 8 tokens, got %d", test.input, len(tokens))
+		}
+		colon := tokens[5]
+		if colon.Type != BridgeTokenType || colon.Alias == nil || *colon.Alias != "=>>" {
+			t.Errorf("%s: expected the first wildcard to stand for =>>, got %s %v", test.input, colon.Type, colon.Alias)
+		}
+		end := tokens[7]
+		alias := ""
+		if end.Alias != nil {
+			alias = *end.Alias
+		}
+		if end.Text != test.endText || end.Type != EndTokenType || alias != test.endAlias {
+			t.Errorf("%s: expected end token %q standing for %q, got %q %s %q",
+				test.input, test.endText, test.endAlias, end.Text, end.Type, alias)
+		}
+	}
+}
+
+func TestWildcardStartToken(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Start: []StartRule{
+			{Text: "module", Expecting: []string{"body"}, ClosedBy: []string{"endmodule"}},
+			{Text: "body", Expecting: []string{"endbody"}, ClosedBy: []string{"endbody"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tokens, err := New("module m : x : endmodule", &Options{Rules: rules, AnnotateContext: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The first wildcard opens a body, and the second closes it.
+	if tokens[2].Type != StartTokenType || *tokens[2].Alias != "body" {
+		t.Errorf("Expected a wildcard start token for body, got %s %v", tokens[2].Type, tokens[2].Alias)
+	}
+	if got := tokens[3].Context; len(got) != 2 || got[1] != "body" {
+		t.Errorf("Expected x to be within module and body, got %v", got)
+	}
+	if tokens[4].Type != EndTokenType || *tokens[4].Alias != "endbody" {
+		t.Errorf("Expected a wildcard end token for endbody, got %s %v", tokens[4].Type, tokens[4].Alias)
+	}
+	if got := tokens[5].Context; len(got) != 0 {
+		t.Errorf("Expected endmodule to close the module, got context %v", got)
+	}
+}
+
+func TestLoadRulesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"rules/custom.yaml": {Data: []byte("prefix:\n  - text: \"custom_return\"\n")},
+		"rules/broken.yaml": {Data: []byte("prefix: [\n")},
+	}
+
+	rules, err := LoadRulesFS(fsys, "rules/custom.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load rules file: %v", err)
+	}
+	if len(rules.Prefix) != 1 || rules.Prefix[0].Text != "custom_return" {
+		t.Errorf("Expected prefix rule with text 'custom_return', got %+v", rules.Prefix)
+	}
+
+	if _, err := LoadRulesFS(fsys, "rules/missing.yaml"); err == nil {
+		t.Errorf("Expected an error for a missing rules file")
+	}
+	if _, err := LoadRulesFS(fsys, "rules/broken.yaml"); err == nil {
+		t.Errorf("Expected an error for invalid YAML")
+	}
+}
+
 func TestLoadRulesFile(t *testing.T) {
 	// Create a temporary rules file
 	rulesContent := `wildcard:
@@ -926,6 +1222,48 @@ func TestExceptionTokens(t *testing.T) {
 	}
 }
 
+// TestMisplacedUnderscores tests that a number with a misplaced underscore
+// becomes one exception token with a precise reason.
+func TestMisplacedUnderscores(t *testing.T) {
+	tests := []struct {
+		input  string
+		text   string
+		reason string
+	}{
+		{"1__2_", "1__2_", "invalid literal: double underscore"},
+		{"1_ + 2", "1_", "invalid literal: trailing underscore"},
+		{"1.5_", "1.5_", "invalid literal: trailing underscore"},
+		{"1_.5", "1_.5", "invalid literal: underscore next to the radix point"},
+		{"1._5", "1._5", "invalid literal: underscore next to the radix point"},
+		{"1_e5", "1_e5", "invalid literal: underscore next to the exponent"},
+		{"1e-_5", "1e-_5", "invalid literal: underscore next to the exponent"},
+		{"0x_FF", "0x_FF", "invalid literal: underscore after the radix prefix"},
+		{"0xF__F", "0xF__F", "invalid literal: double underscore"},
+		{"1_x", "1_x", "invalid literal: underscore not between digits"},
+	}
+	for _, test := range tests {
+		tokens, err := NewTokenizer(test.input).Tokenize()
+		if err == nil {
+			t.Errorf("Expected an error for %q", test.input)
+			continue
+		}
+		if len(tokens) != 1 || tokens[0].Type != ExceptionTokenType {
+			t.Errorf("Expected one exception token for %q, got %v", test.input, tokenTexts(tokens))
+			continue
+		}
+		if tokens[0].Text != test.text || tokens[0].Reason == nil || *tokens[0].Reason != test.reason {
+			t.Errorf("For %q expected %q with reason %q, got %q with %v", test.input, test.text, test.reason, tokens[0].Text, tokens[0].Reason)
+		}
+	}
+
+	// Underscores between digits are still allowed.
+	for _, input := range []string{"1_000", "1_000.000_1", "0xF_F", "1.5e10"} {
+		if tokens, err := NewTokenizer(input).Tokenize(); err != nil || len(tokens) != 1 || tokens[0].Type != NumericLiteralTokenType {
+			t.Errorf("Expected %q to be a number, got %v (%v)", input, tokenTexts(tokens), err)
+		}
+	}
+}
+
 func TestNewlineTracking(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1006,10 +1344,44 @@ func TestNewlineTracking(t *testing.T) {
 				lnBefore *bool
 				lnAfter  *bool
 			}{
-				{"a", nil, boolPtr(true)}, // comment after is treated as newline
+				{"a", nil, boolPtr(true)}, // newline after the comment
 				{"b", boolPtr(true), nil}, // newline before
 			},
 		},
+		{
+			name:  "Comment ending the input",
+			input: "a ### comment",
+			expected: []struct {
+				text     string
+				lnBefore *bool
+				lnAfter  *bool
+			}{
+				{"a", nil, nil}, // no newline, as the input ends with the comment
+			},
+		},
+		{
+			name:  "Trailing newline after a comment",
+			input: "a ### comment\r\n",
+			expected: []struct {
+				text     string
+				lnBefore *bool
+				lnAfter  *bool
+			}{
+				{"a", nil, boolPtr(true)}, // trailing newline after the comment
+			},
+		},
+		{
+			name:  "Trailing newline at end of input",
+			input: "a b\n",
+			expected: []struct {
+				text     string
+				lnBefore *bool
+				lnAfter  *bool
+			}{
+				{"a", nil, nil},           // no newlines before or after
+				{"b", nil, boolPtr(true)}, // trailing newline after
+			},
+		},
 		{
 			name:  "Complex multi-line example",
 			input: "def foo(x)\n    return x + 1\nend",
@@ -1206,3 +1578,198 @@ func writeFile(filename, content string) error {
 	_, err = file.WriteString(content)
 	return err
 }
+
+func TestContextAnnotation(t *testing.T) {
+	input := "def f() =>>\n    class C end\n    x\nend\ny"
+	tokenizer := NewTokenizer(input)
+	tokenizer.SetAnnotateContext(true)
+	tokens, err := tokenizer.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		text    string
+		context []string
+	}{
+		{"def", nil},
+		{"f", []string{"def"}},
+		{"(", []string{"def"}},
+		{")", []string{"def"}},
+		{"=>>", []string{"def"}},
+		{"class", []string{"def"}},
+		{"C", []string{"def", "class"}},
+		{"end", []string{"def"}},
+		{"x", []string{"def"}},
+		{"end", nil},
+		{"y", nil},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, tt := range expected {
+		token := tokens[i]
+		if token.Text != tt.text {
+			t.Errorf("Token %d: expected text %q, got %q", i, tt.text, token.Text)
+		}
+		if strings.Join(token.Context, ",") != strings.Join(tt.context, ",") {
+			t.Errorf("Token %d (%q): expected context %v, got %v", i, token.Text, tt.context, token.Context)
+		}
+	}
+
+	// Without the option no context is attached.
+	tokens, err = NewTokenizer(input).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, token := range tokens {
+		if token.Context != nil {
+			t.Errorf("Token %d (%q): expected no context, got %v", i, token.Text, token.Context)
+		}
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	// A nil Options selects the default rules.
+	tokens, err := New("if x then y endif", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tokens[0].Type != StartTokenType {
+		t.Errorf("Expected 'if' to be a start token with default rules, got %s", tokens[0].Type)
+	}
+
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Start: []StartRule{{Text: "loop", ClosedBy: []string{"endloop"}}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tokens, err = New("loop x endloop", &Options{Rules: rules, AnnotateContext: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tokens[0].Type != StartTokenType {
+		t.Errorf("Expected 'loop' to be a start token with custom rules, got %s", tokens[0].Type)
+	}
+	if len(tokens[1].Context) != 1 || tokens[1].Context[0] != "loop" {
+		t.Errorf("Expected 'x' to have context [loop], got %v", tokens[1].Context)
+	}
+
+	_, err = New("a b c", &Options{Limits: Limits{MaxTokens: 2}}).Tokenize()
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected a limit error, got %v", err)
+	}
+}
+
+func TestEndTokenCloses(t *testing.T) {
+	tokens, err := NewTokenizer("def f() =>> if x then y end end").Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	last := tokens[len(tokens)-1]
+	if last.Type != EndTokenType {
+		t.Fatalf("Expected an end token, got %s", last.Type)
+	}
+	// The generic end closes every start token of the default rules.
+	for _, start := range []string{"def", "if", "for", "try"} {
+		if !slices.Contains(last.Closes, start) {
+			t.Errorf("Expected end to close %s, got %v", start, last.Closes)
+		}
+	}
+	if !slices.IsSorted(last.Closes) {
+		t.Errorf("Expected closes to be sorted, got %v", last.Closes)
+	}
+
+	tokens, err = NewTokenizer("def f() =>> x enddef").Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokens[len(tokens)-1].Closes; !reflect.DeepEqual(got, []string{"def"}) {
+		t.Errorf("Expected enddef to close def, got %v", got)
+	}
+}
+
+func TestRawDigits(t *testing.T) {
+	tests := []struct {
+		input       string
+		rawMantissa string
+		rawFraction string
+	}{
+		{"1_000.000_1", "1_000", "000_1"},
+		{"0xF_F", "F_F", ""},
+		{"0t1_0.T", "1_0", ""},
+		{"1000.5", "", ""},
+	}
+	for _, test := range tests {
+		tokens, err := NewTokenizer(test.input).Tokenize()
+		if err != nil || len(tokens) != 1 {
+			t.Fatalf("Unexpected result for %q: %v (%v)", test.input, tokenTexts(tokens), err)
+		}
+		token := tokens[0]
+		if got := stringOrEmpty(token.RawMantissa); got != test.rawMantissa {
+			t.Errorf("For %q expected raw mantissa %q, got %q", test.input, test.rawMantissa, got)
+		}
+		if got := stringOrEmpty(token.RawFraction); got != test.rawFraction {
+			t.Errorf("For %q expected raw fraction %q, got %q", test.input, test.rawFraction, got)
+		}
+	}
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func TestExponentMarkers(t *testing.T) {
+	tests := []struct {
+		input    string
+		text     string
+		exponent int
+		expBase  int
+	}{
+		{"1.5e-3", "1.5e-3", -3, 10},
+		{"0x1.8p3", "0x1.8p3", 3, 2},
+		{"0x1.8P-3", "0x1.8P-3", -3, 2},
+		{"0x1Fe2", "0x1Fe2", 2, 10},
+		{"2r101e3", "2r101e3", 3, 10},
+		{"1p3", "1", 0, 0},
+	}
+	for _, test := range tests {
+		tokens, err := NewTokenizer(test.input).Tokenize()
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.input, err)
+		}
+		token := tokens[0]
+		exponent, expBase := 0, 0
+		if token.Exponent != nil {
+			exponent, expBase = *token.Exponent, *token.ExpBase
+		}
+		if token.Text != test.text || exponent != test.exponent || expBase != test.expBase {
+			t.Errorf("For %q expected %q with exponent %d base %d, got %q with %d base %d",
+				test.input, test.text, test.exponent, test.expBase, token.Text, exponent, expBase)
+		}
+	}
+
+	// With only p and P for hexadecimal numbers, e no longer starts an exponent.
+	rules, err := ApplyRulesToDefaults(&RulesFile{Number: &NumberRule{ExponentMarkers: map[int]string{16: "pP"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tokens, err := New("0x1e2 0x1P2", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, []string{"0x1", "e2", "0x1P2"}) {
+		t.Errorf("Expected [0x1 e2 0x1P2], got %v", got)
+	}
+
+	for _, markers := range []map[int]string{{16: "F"}, {10: "x"}, {10: "_"}, {40: "e"}} {
+		if _, err := ApplyRulesToDefaults(&RulesFile{Number: &NumberRule{ExponentMarkers: markers}}); err == nil {
+			t.Errorf("Expected an error for exponent markers %v", markers)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package tokenizer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tracePreviewLength is the number of bytes of upcoming input shown in the
+// trace at the start of each token.
+const tracePreviewLength = 20
+
+// SetTraceWriter makes the tokenizer log its decisions to w: for each token,
+// which matchers were tried, which rule matched, and the state of the
+// expecting stack afterwards. A nil writer turns tracing off.
+func (t *Tokenizer) SetTraceWriter(w io.Writer) {
+	t.traceWriter = w
+}
+
+// tracing reports whether tracing is on. Callers use it to avoid building
+// trace messages that would be thrown away.
+func (t *Tokenizer) tracing() bool {
+	return t.traceWriter != nil
+}
+
+// tracef writes one line to the trace, if tracing is on.
+func (t *Tokenizer) tracef(format string, args ...interface{}) {
+	if t.traceWriter != nil {
+		fmt.Fprintf(t.traceWriter, format+"\n", args...)
+	}
+}
+
+// traceStart records the position at which a new token is being sought.
+func (t *Tokenizer) traceStart() {
+	if !t.tracing() {
+		return
+	}
+	preview := t.input[t.position:]
+	if len(preview) > tracePreviewLength {
+		preview = preview[:tracePreviewLength] + "..."
+	}
+	t.tracef("%d:%d %q", t.line, t.column, preview)
+}
+
+// traceMatcher records the outcome of trying one matcher.
+func (t *Tokenizer) traceMatcher(matcher string, token *Token) {
+	if !t.tracing() {
+		return
+	}
+	if token == nil {
+		t.tracef("  %s: no match", matcher)
+	} else {
+		t.tracef("  %s: matched %s %q", matcher, token.Type, token.Text)
+	}
+}
+
+// traceStack records the state of the expecting stack.
+func (t *Tokenizer) traceStack() {
+	if !t.tracing() {
+		return
+	}
+	frames := make([]string, len(t.expectingStack))
+	for i, frame := range t.expectingStack {
+		frames[i] = fmt.Sprintf("%s[%s]", frame.start, strings.Join(frame.expecting, " "))
+	}
+	t.tracef("  stack: %s", strings.Join(frames, " "))
+}
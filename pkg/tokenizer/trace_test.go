@@ -0,0 +1,46 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var trace strings.Builder
+	tokenizer := New("if x then 1 endif", &Options{Trace: &trace})
+	if _, err := tokenizer.Tokenize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedLines := []string{
+		`1:1 "if x then 1 endif"`,
+		`  string: no match`,
+		`  rules: entry for "if" (start)`,
+		`  rules: matched S "if"`,
+		`  stack: if[then]`,
+		`  rules: no entry for identifier "x"`,
+		`  numeric: matched n "1"`,
+		`  rules: entry for "endif" (end)`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(trace.String(), line+"\n") {
+			t.Errorf("expected trace line %q in:\n%s", line, trace.String())
+		}
+	}
+	if !strings.HasSuffix(trace.String(), "  stack: \n") {
+		t.Errorf("expected the trace to end with an empty stack, got:\n%s", trace.String())
+	}
+}
+
+func TestTraceTurnedOff(t *testing.T) {
+	var trace strings.Builder
+	tokenizer := New("if x then 1 endif", nil)
+	tokenizer.SetTraceWriter(&trace)
+	tokenizer.SetTraceWriter(nil)
+	if _, err := tokenizer.Tokenize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if trace.Len() != 0 {
+		t.Errorf("expected no trace, got:\n%s", trace.String())
+	}
+}
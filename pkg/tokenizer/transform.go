@@ -0,0 +1,147 @@
+package tokenizer
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Transform rewrites the token stream after tokenization. It may modify the
+// tokens it is given, and returns the new stream.
+type Transform func(tokens []*Token) []*Token
+
+// AddTransform adds a transform to be applied to the tokens produced by
+// Tokenize and TokenizeValues. Transforms are applied in the order they were
+// added, and also to the tokens found before an error.
+func (t *Tokenizer) AddTransform(transform Transform) {
+	t.transforms = append(t.transforms, transform)
+}
+
+// applyTransforms runs the transforms over the tokens found so far.
+func (t *Tokenizer) applyTransforms() {
+	for _, transform := range t.transforms {
+		t.tokens = transform(t.tokens)
+	}
+}
+
+// builtinTransforms are the transforms that can be selected by name.
+var builtinTransforms = map[string]Transform{
+	"merge-strings":                 MergeAdjacentStrings,
+	"strip-unclassified-whitespace": StripUnclassifiedWhitespace,
+	"canonicalize-aliases":          CanonicalizeAliases,
+}
+
+// LookupTransform returns the built-in transform with the given name.
+func LookupTransform(name string) (Transform, error) {
+	if transform, ok := builtinTransforms[name]; ok {
+		return transform, nil
+	}
+	return nil, fmt.Errorf("unknown transform %q (known transforms: %s)",
+		name, strings.Join(TransformNames(), ", "))
+}
+
+// TransformNames returns the names of the built-in transforms, sorted.
+func TransformNames() []string {
+	names := make([]string, 0, len(builtinTransforms))
+	for name := range builtinTransforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MergeAdjacentStrings joins each run of consecutive plain string literals
+// with the same quote on the same line into a single string literal, whose
+// value is the concatenation of their values and whose span covers them all.
+// The strings it joins are kept as its subtokens, with their own texts and
+// spans. Its text is their texts separated by single spaces, which is not the
+// source text when they were written with other whitespace between them.
+// Strings on different lines, and so also strings with a comment between
+// them, are not joined. Tagged, interpolated and multi-line strings are left
+// alone.
+func MergeAdjacentStrings(tokens []*Token) []*Token {
+	merged := tokens[:0]
+	for _, token := range tokens {
+		if n := len(merged); n > 0 && isMergeableString(merged[n-1]) &&
+			isMergeableString(token) && merged[n-1].Quote == token.Quote &&
+			onSameLine(merged[n-1], token) {
+			merged[n-1] = mergeStrings(merged[n-1], token)
+			continue
+		}
+		merged = append(merged, token)
+	}
+	// Clear the tail so the merged tokens can be garbage collected.
+	clear(tokens[len(merged):])
+	return merged
+}
+
+// isMergeableString reports whether the token is a plain string literal.
+func isMergeableString(token *Token) bool {
+	return token.Type == StringLiteralTokenType && token.Specifier == nil && token.Value != nil
+}
+
+// onSameLine reports whether b starts on the line that a ends on. A comment
+// runs to the end of its line, so there can be none between them.
+func onSameLine(a, b *Token) bool {
+	return a.File == b.File && a.Span.End.Line == b.Span.Start.Line && (b.LnBefore == nil || !*b.LnBefore)
+}
+
+// mergeStrings returns a new string literal that joins a and b, where a may
+// itself be the result of joining strings.
+func mergeStrings(a, b *Token) *Token {
+	merged := *a
+	parts := a.Subtokens
+	if parts == nil {
+		parts = []*Token{a}
+	}
+	merged.Subtokens = append(slices.Clip(parts), b)
+	merged.Text = a.Text + " " + b.Text
+	value := *a.Value + *b.Value
+	merged.Value = &value
+	merged.Span = Span{Start: a.Span.Start, End: b.Span.End}
+	merged.LnAfter = b.LnAfter
+	return &merged
+}
+
+// StripUnclassifiedWhitespace removes the unclassified tokens that consist
+// only of invisible characters, such as byte order marks and zero-width
+// spaces, which are not skipped as whitespace.
+func StripUnclassifiedWhitespace(tokens []*Token) []*Token {
+	kept := tokens[:0]
+	for _, token := range tokens {
+		if token.Type == UnclassifiedTokenType && isInvisible(token.Text) {
+			continue
+		}
+		kept = append(kept, token)
+	}
+	// Clear the tail so the removed tokens can be garbage collected.
+	clear(tokens[len(kept):])
+	return kept
+}
+
+// isInvisible reports whether the text consists only of whitespace and
+// formatting characters.
+func isInvisible(text string) bool {
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !unicode.Is(unicode.Cf, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// CanonicalizeAliases replaces the text of each token that has an alias, such
+// as a wildcard standing for an expected label, with the alias, so that
+// consumers need not handle both spellings. The alias is then removed. The
+// original text can still be recovered from the token's span.
+func CanonicalizeAliases(tokens []*Token) []*Token {
+	for _, token := range tokens {
+		if token.Alias != nil {
+			token.Text = *token.Alias
+			token.Alias = nil
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,90 @@
+package tokenizer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{Wildcard: []WildcardRule{{Text: ":"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"merge-strings", `f("a" "b" 'c', "d")`, []string{"f", "(", `"a" "b"`, `'c'`, ",", `"d"`, ")"}},
+		{"strip-unclassified-whitespace", "\ufeffx\u200b+ y", []string{"x", "+", "y"}},
+		{"canonicalize-aliases", "if x : y endif", []string{"if", "x", "then", "y", "endif"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := LookupTransform(tt.name)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tokenizer := New(tt.input, &Options{Rules: rules})
+			tokenizer.AddTransform(transform)
+			tokens, err := tokenizer.Tokenize()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var texts []string
+			for _, token := range tokens {
+				texts = append(texts, token.Text)
+			}
+			if !slices.Equal(texts, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, texts)
+			}
+		})
+	}
+}
+
+func TestMergeAdjacentStrings(t *testing.T) {
+	tokens, err := New("\"a\"\t\"b\"  \"c\"\n", nil).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens = MergeAdjacentStrings(tokens)
+	if len(tokens) != 1 {
+		t.Fatalf("expected 1 token, got %d", len(tokens))
+	}
+	merged := tokens[0]
+	if merged.Value == nil || *merged.Value != "abc" {
+		t.Errorf("expected value \"abc\", got %v", merged.Value)
+	}
+	if want := (Span{Position{1, 1}, Position{1, 13}}); merged.Span != want {
+		t.Errorf("expected span %v, got %v", want, merged.Span)
+	}
+	if merged.LnAfter == nil || !*merged.LnAfter {
+		t.Errorf("expected ln_after from the last string")
+	}
+	// The parts keep the texts and spans they had in the source.
+	if got := tokenTexts(merged.Subtokens); !slices.Equal(got, []string{`"a"`, `"b"`, `"c"`}) {
+		t.Fatalf("expected the parts as subtokens, got %v", got)
+	}
+	if want := (Span{Position{1, 5}, Position{1, 8}}); merged.Subtokens[1].Span != want {
+		t.Errorf("expected the span %v for \"b\", got %v", want, merged.Subtokens[1].Span)
+	}
+}
+
+func TestMergeAdjacentStringsKeepsLines(t *testing.T) {
+	for _, input := range []string{"\"a\"\n  \"b\"\n", "\"a\" ### comment\n\"b\"\n"} {
+		tokens, err := New(input, nil).Tokenize()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tokens = MergeAdjacentStrings(tokens); len(tokens) != 2 {
+			t.Errorf("%q: expected strings on different lines to be left apart, got %v", input, tokenTexts(tokens))
+		}
+	}
+}
+
+func TestLookupTransformUnknown(t *testing.T) {
+	if _, err := LookupTransform("no-such-transform"); err == nil {
+		t.Errorf("expected an error for an unknown transform")
+	}
+}
@@ -0,0 +1,176 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{Translate: []TranslateRule{
+		{Text: "si", As: "if"},
+		{Text: "alors", As: "then"},
+		{Text: "fin", As: "end"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, err := New("si x alors (si y then z fin) fin", &Options{Rules: rules, AnnotateContext: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		text      string
+		tokenType TokenType
+		alias     string
+	}{
+		{"si", StartTokenType, "if"},
+		{"x", VariableTokenType, ""},
+		{"alors", BridgeTokenType, "then"},
+		{"(", OpenDelimiterTokenType, ""},
+		{"si", StartTokenType, "if"},
+		{"y", VariableTokenType, ""},
+		{"then", BridgeTokenType, ""},
+		{"z", VariableTokenType, ""},
+		{"fin", EndTokenType, "end"},
+		{")", CloseDelimiterTokenType, ""},
+		{"fin", EndTokenType, "end"},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %v", len(expected), tokenTexts(tokens))
+	}
+	for i, want := range expected {
+		got := tokens[i]
+		alias := ""
+		if got.Alias != nil {
+			alias = *got.Alias
+		}
+		if got.Text != want.text || got.Type != want.tokenType || alias != want.alias {
+			t.Errorf("token %d: expected %q %s alias %q, got %q %s alias %q",
+				i, want.text, want.tokenType, want.alias, got.Text, got.Type, alias)
+		}
+	}
+	// The context names the start token that a translation stands for.
+	if context := tokens[7].Context; len(context) != 2 || context[0] != "if" || context[1] != "if" {
+		t.Errorf("expected context [if if], got %v", context)
+	}
+}
+
+func TestTranslateDigraphs(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{Translate: []TranslateRule{
+		{Text: "(:", As: "{"},
+		{Text: ":)", As: "}"},
+		{Text: "(.", As: "("},
+		{Text: ".)", As: ")"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, err := New("x := (: f(. y: 1 .), (:2:) :)", &Options{Rules: rules, KeywordArguments: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(tokenTexts(tokens), " "); got != "x := (: f (. y: 1 .) , (: 2 :) :)" {
+		t.Fatalf("unexpected tokens %s", got)
+	}
+
+	// The digraphs are brackets known by the brackets they stand for, so
+	// they pair up as those do and y: within (. is a keyword argument.
+	MatchBrackets(tokens)
+	pairs := map[int]int{2: 12, 4: 7, 9: 11}
+	for opener, closer := range pairs {
+		if tokens[opener].Type != OpenDelimiterTokenType || tokens[opener].Pair == nil || *tokens[opener].Pair != closer {
+			t.Errorf("expected %q at %d to be paired with %d, got %v", tokens[opener].Text, opener, closer, tokens[opener].Pair)
+		}
+	}
+	if tokens[5].Type != KeywordArgTokenType {
+		t.Errorf("expected y: to be a keyword argument, got %s", tokens[5].Type)
+	}
+
+	// A translated end keyword closes its start token as the end does.
+	rules, err = ApplyRulesToDefaults(&RulesFile{Translate: []TranslateRule{{Text: "fin", As: "end"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, err = New("if x then y fin", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	MatchBrackets(tokens)
+	if tokens[0].Pair == nil || *tokens[0].Pair != 4 {
+		t.Errorf("expected if to be paired with fin, got %v", tokens[0].Pair)
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		translate []TranslateRule
+		errMsg    string
+	}{
+		{"unknown token", []TranslateRule{{Text: "si", As: "when"}}, "translation 'si' is for 'when', which is not a token"},
+		{"clash", []TranslateRule{{Text: "then", As: "if"}}, "translation 'then' is already a bridge token"},
+		{"chain", []TranslateRule{{Text: "si", As: "if"}, {Text: "so", As: "si"}}, "which is itself a translation"},
+		{"twice", []TranslateRule{{Text: "si", As: "if"}, {Text: "si", As: "then"}}, "given as both 'if' and 'then'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ApplyRulesToDefaults(&RulesFile{Translate: tt.translate})
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitiveKeywords(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		CaseInsensitiveKeywords: true,
+		Translate:               []TranslateRule{{Text: "si", As: "if"}},
+		Operator:                []OperatorRule{{Text: "mod", Precedence: &[3]int{0, 400, 0}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, err := New("IF x Then y ENDIF Si z fin MOD if", &Options{Rules: rules}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		text      string
+		tokenType TokenType
+		alias     string
+	}{
+		{"IF", StartTokenType, "if"},
+		{"x", VariableTokenType, ""},
+		{"Then", BridgeTokenType, "then"},
+		{"y", VariableTokenType, ""},
+		{"ENDIF", EndTokenType, "endif"},
+		{"Si", StartTokenType, "if"},
+		{"z", VariableTokenType, ""},
+		{"fin", VariableTokenType, ""},
+		// Only keywords are matched in any case, not operators.
+		{"MOD", VariableTokenType, ""},
+		{"if", StartTokenType, ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %v", len(expected), tokenTexts(tokens))
+	}
+	for i, want := range expected {
+		got := tokens[i]
+		alias := ""
+		if got.Alias != nil {
+			alias = *got.Alias
+		}
+		if got.Text != want.text || got.Type != want.tokenType || alias != want.alias {
+			t.Errorf("token %d: expected %q %s alias %q, got %q %s alias %q",
+				i, want.text, want.tokenType, want.alias, got.Text, got.Type, alias)
+		}
+	}
+
+	// Without the flag, keywords only match in their own case.
+	if tokens, _ := NewTokenizer("IF").Tokenize(); tokens[0].Type != VariableTokenType {
+		t.Errorf("expected IF to be a variable by default, got %s", tokens[0].Type)
+	}
+}
@@ -0,0 +1,72 @@
+package tokenizer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The numeric regular expressions only allow an underscore between digits,
+// so a misplaced one ends the match early, as in 1__2 or 1_e5, where the rest
+// would otherwise become an identifier. These recognise what follows such a
+// match, an underscore or a radix prefix or exponent followed by one, and
+// the places an underscore can be misplaced.
+var (
+	underscoreTailRegex = regexp.MustCompile(`^(?:_|[xobtr]_|[ep][+-]?_)`)
+	radixUnderscore     = regexp.MustCompile(`^\d+[xobtr]_`)
+	exponentUnderscore  = regexp.MustCompile(`_[ep]|[ep][+-]?_`)
+)
+
+// misplacedUnderscore checks the underscores of a numeric literal, given the
+// text matched as a number and the input after it. If an underscore is out
+// of place it returns the whole of the mistaken literal together with the
+// reason it is invalid; otherwise the reason is "".
+func misplacedUnderscore(match, rest string) (string, string) {
+	literal := match
+	if tail := underscoreTailRegex.FindString(rest); tail != "" {
+		// The x, o, b, t or r of a radix prefix may only follow digits.
+		if strings.IndexByte("xobtr", tail[0]) >= 0 && strings.Trim(match, "0123456789") != "" {
+			return match, ""
+		}
+		literal += numericTail(rest)
+	} else if !strings.Contains(match, "._") {
+		return match, ""
+	}
+
+	switch {
+	case strings.Contains(literal, "__"):
+		return literal, "invalid literal: double underscore"
+	case strings.Contains(literal, "._") || strings.Contains(literal, "_."):
+		return literal, "invalid literal: underscore next to the radix point"
+	case exponentUnderscore.MatchString(literal):
+		return literal, "invalid literal: underscore next to the exponent"
+	case radixUnderscore.MatchString(literal):
+		return literal, "invalid literal: underscore after the radix prefix"
+	case strings.HasSuffix(literal, "_"):
+		return literal, "invalid literal: trailing underscore"
+	}
+	return literal, "invalid literal: underscore not between digits"
+}
+
+// numericTail returns the part of rest that looks like the continuation of
+// a numeric literal: letters, digits and underscores, a point followed by
+// one of those, and a sign after an exponent.
+func numericTail(rest string) string {
+	end := 0
+	for end < len(rest) {
+		c := rest[end]
+		switch {
+		case isTailChar(c):
+		case c == '.' && end+1 < len(rest) && isTailChar(rest[end+1]):
+		case (c == '+' || c == '-') && end > 0 && rest[end-1] == 'e':
+		default:
+			return rest[:end]
+		}
+		end++
+	}
+	return rest
+}
+
+// isTailChar reports whether c is a letter, digit or underscore.
+func isTailChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
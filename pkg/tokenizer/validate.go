@@ -0,0 +1,75 @@
+package tokenizer
+
+// ValidateTokens checks the spans of tokens taken from source, and returns an
+// error for each token whose span ends before it starts, lies outside the
+// source, overlaps or comes before the token before it, or does not cover
+// exactly the token's text. Subtokens are checked in the same way, and must
+// lie within their token. It is a self-check of the tokenizer rather than of
+// the source, so the errors are of no use to the author of the source.
+//
+// The tokens of a define's expansion all share the span of the defined token,
+// which they are checked against instead. Positions renumbered by ###line
+// directives cannot be checked against the source, nor can texts changed by
+// transforms, so they report spurious errors. The columns must count
+// characters, as they do by default.
+func ValidateTokens(tokens []*Token, source string) []error {
+	return ValidateTokensWithPolicy(tokens, source, ColumnRunes)
+}
+
+// ValidateTokensWithPolicy checks the spans of tokens taken from source, whose
+// columns count what the policy says, as ValidateTokens does.
+func ValidateTokensWithPolicy(tokens []*Token, source string, policy ColumnPolicy) []error {
+	v := &spanValidator{index: NewLineIndexWithPolicy(source, policy), source: source}
+	v.check(tokens, nil)
+	return v.errors
+}
+
+// spanValidator collects the errors found by ValidateTokens.
+type spanValidator struct {
+	index  *LineIndex
+	source string
+	errors []error
+}
+
+// check checks a list of tokens, which are the subtokens of parent unless it
+// is nil.
+func (v *spanValidator) check(tokens []*Token, parent *Token) {
+	var previous *Token
+	for _, token := range tokens {
+		span := token.Span
+		start, end := v.index.Offset(span.Start), v.index.Offset(span.End)
+		switch {
+		case span.End.Before(span.Start):
+			v.fail(token, "token '%s' ends before it starts", token.Text)
+		case start < 0 || end < 0:
+			v.fail(token, "token '%s' lies outside the source", token.Text)
+		case parent != nil && (span.Start.Before(parent.Span.Start) || parent.Span.End.Before(span.End)):
+			v.fail(token, "subtoken '%s' lies outside its token '%s'", token.Text, parent.Text)
+		case previous != nil && span.Start.Before(previous.Span.Start):
+			v.fail(token, "token '%s' comes before the token '%s' before it", token.Text, previous.Text)
+		case previous != nil && span.Start.Before(previous.Span.End) && !sameExpansion(previous, token):
+			v.fail(token, "token '%s' overlaps the token '%s' before it", token.Text, previous.Text)
+		case token.ExpandedFrom != nil:
+			// The tokens of an expansion have the span of the defined token
+			// rather than their own text.
+			if span != *token.ExpandedFrom {
+				v.fail(token, "token '%s' has a span other than that of the define it was expanded from", token.Text)
+			}
+		case v.source[start:end] != token.Text:
+			v.fail(token, "token '%s' spans the source text '%s'", token.Text, v.source[start:end])
+		}
+		v.check(token.Subtokens, token)
+		previous = token
+	}
+}
+
+// sameExpansion reports whether two tokens come from the same expansion of a
+// define, and so share a span.
+func sameExpansion(a, b *Token) bool {
+	return a.ExpandedFrom != nil && b.ExpandedFrom != nil && *a.ExpandedFrom == *b.ExpandedFrom
+}
+
+// fail records an error at the token.
+func (v *spanValidator) fail(token *Token, format string, args ...interface{}) {
+	v.errors = append(v.errors, errorAt(token.Span, format, args...))
+}
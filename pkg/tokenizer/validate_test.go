@@ -0,0 +1,48 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTokens(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{Define: []DefineRule{{Text: "unless", As: "if not"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	input := "unless x then\n  f(\"a\\(b)c\", «d») + -1.5e3\nendif\n"
+	tokens, err := New(input, &Options{Rules: rules, Lossless: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if errs := ValidateTokens(tokens, input); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestValidateTokensErrors(t *testing.T) {
+	input := "ab cd\nef"
+	token := func(text string, startLine, startCol, endLine, endCol int) *Token {
+		return NewToken(text, VariableTokenType, Span{Position{startLine, startCol}, Position{endLine, endCol}})
+	}
+	withSubtoken := token("cd", 1, 4, 1, 6)
+	withSubtoken.Subtokens = []*Token{token("ef", 2, 1, 2, 3)}
+
+	tests := []struct {
+		tokens []*Token
+		reason string
+	}{
+		{[]*Token{token("ab", 1, 3, 1, 1)}, "token 'ab' ends before it starts"},
+		{[]*Token{token("gh", 3, 1, 3, 3)}, "token 'gh' lies outside the source"},
+		{[]*Token{token("cd", 1, 4, 1, 6), token("ab", 1, 1, 1, 3)}, "token 'ab' comes before the token 'cd' before it"},
+		{[]*Token{token("ab", 1, 1, 1, 3), token("b", 1, 2, 1, 3)}, "token 'b' overlaps the token 'ab' before it"},
+		{[]*Token{token("ab", 1, 1, 1, 2)}, "token 'ab' spans the source text 'a'"},
+		{[]*Token{withSubtoken}, "subtoken 'ef' lies outside its token 'cd'"},
+	}
+	for _, test := range tests {
+		errs := ValidateTokens(test.tokens, input)
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), test.reason) {
+			t.Errorf("Expected the error %q, got %v", test.reason, errs)
+		}
+	}
+}
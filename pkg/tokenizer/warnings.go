@@ -0,0 +1,38 @@
+package tokenizer
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SetLogger sets the logger that receives warnings about input that was
+// tokenized, but perhaps not as the author intended: for example a wildcard
+// with no expected label to stand for, or a run of sign characters that is
+// not a known operator. Each warning carries the line, col and text of the
+// token concerned. A nil logger discards the warnings.
+func (t *Tokenizer) SetLogger(logger *slog.Logger) {
+	t.logger = logger
+}
+
+// warn records a warning about the text at start, which is on one line, and
+// logs it if there is a logger. In strict mode it is instead kept as the
+// error that stops tokenizing, unless there is one already.
+func (t *Tokenizer) warn(start Position, text string, msg string) {
+	t.tracef("  warning: %s", msg)
+	span := Span{start, Position{start.Line, start.Col + t.columnPolicy.width(text)}}
+	if t.strict {
+		if t.strictErr == nil {
+			t.strictErr = errorAt(span, "%s", msg)
+		}
+		return
+	}
+	t.diagnostics = append(t.diagnostics, Diagnostic{span, SeverityWarning, msg})
+	if t.logger == nil {
+		return
+	}
+	t.logger.LogAttrs(context.Background(), slog.LevelWarn, msg,
+		slog.Int("line", start.Line),
+		slog.Int("col", start.Col),
+		slog.String("text", text),
+	)
+}
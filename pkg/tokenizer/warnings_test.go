@@ -0,0 +1,85 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestWarnings(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{Wildcard: []WildcardRule{{Text: ":"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var log bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&log, nil))
+	tokenizer := New("x ~~~ y\nif a : b endif :", &Options{Rules: rules, Logger: logger})
+	if _, err := tokenizer.Tokenize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type warning struct {
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+		Line  int    `json:"line"`
+		Col   int    `json:"col"`
+		Text  string `json:"text"`
+	}
+	expected := []warning{
+		{"WARN", "sign characters are not a known operator", 1, 3, "~~~"},
+		{"WARN", "wildcard has no expected label to stand for", 2, 16, ":"},
+	}
+	decoder := json.NewDecoder(&log)
+	for i, want := range expected {
+		var got warning
+		if err := decoder.Decode(&got); err != nil {
+			t.Fatalf("warning %d: %v", i, err)
+		}
+		if got != want {
+			t.Errorf("warning %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if decoder.More() {
+		t.Errorf("unexpected extra warnings: %s", log.String())
+	}
+}
+
+func TestWildcardStandsForFirstExpectedLabel(t *testing.T) {
+	rules, err := ApplyRulesToDefaults(&RulesFile{
+		Start: []StartRule{{Text: "repeat", Expecting: []string{"while", "until", "unless"}, ClosedBy: []string{"endrepeat"}}},
+		Bridge: []BridgeRule{
+			{Text: "until", In: []string{"repeat"}, Expecting: []string{"endrepeat"}},
+			{Text: "unless", In: []string{"repeat"}, Expecting: []string{"endrepeat"}},
+		},
+		Wildcard: []WildcardRule{{Text: ":"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var log bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&log, nil))
+	tokens, err := New("repeat x : y endrepeat", &Options{Rules: rules, Logger: logger, WarnAmbiguousWildcards: true}).Tokenize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The undefined while is skipped, and until is chosen over unless
+	// because it comes first in the expecting list.
+	wildcard := tokens[2]
+	if wildcard.Type != BridgeTokenType || wildcard.Alias == nil || *wildcard.Alias != "until" {
+		t.Errorf("expected the wildcard to stand for until, got %s %v", wildcard.Type, wildcard.Alias)
+	}
+	if !bytes.Contains(log.Bytes(), []byte(`msg="wildcard could stand for any of until, unless, so stands for the first"`)) {
+		t.Errorf("expected an ambiguity warning, got %q", log.String())
+	}
+
+	// Without the option there is no warning.
+	log.Reset()
+	if _, err := New("repeat x : y endrepeat", &Options{Rules: rules, Logger: logger}).Tokenize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if log.Len() != 0 {
+		t.Errorf("expected no warnings, got %q", log.String())
+	}
+}
@@ -0,0 +1,126 @@
+// Package tokentest provides concise assertions about token sequences, for
+// tests of the tokenizer and of parsers that consume its tokens.
+//
+// A sequence is written as items separated by spaces, each of the form
+// TYPE:TEXT, where TYPE is the code of a token type and TEXT is the token's
+// text, such as
+//
+//	S:def V:foo [:( V:x ]:) E:enddef
+//
+// The type is everything before the first colon, so O::= is the operator :=.
+// An item with no colon, such as s, matches any token of its type, which is
+// how a token whose text has spaces in it, such as a string, is matched.
+package tokentest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/spicery/nutmeg-tokenizer/pkg/tokenizer"
+)
+
+// Item is one token of a sequence.
+type Item struct {
+	Type    tokenizer.TokenType
+	Text    string
+	AnyText bool // Whether the item matches a token of its type with any text
+}
+
+// String returns the item as it is written in a sequence.
+func (item Item) String() string {
+	if item.AnyText {
+		return string(item.Type)
+	}
+	return string(item.Type) + ":" + item.Text
+}
+
+// matches reports whether the token is one that the item describes.
+func (item Item) matches(token *tokenizer.Token) bool {
+	return token.Type == item.Type && (item.AnyText || token.Text == item.Text)
+}
+
+// Parse parses a sequence into its items.
+func Parse(sequence string) ([]Item, error) {
+	var items []Item
+	for _, field := range strings.Fields(sequence) {
+		code, text, found := strings.Cut(field, ":")
+		item := Item{Type: tokenizer.TokenType(code), Text: text, AnyText: !found}
+		if !item.Type.IsKnown() {
+			return nil, fmt.Errorf("unknown token type '%s' in '%s'", code, field)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Format writes the tokens as a sequence. A token whose text has spaces in it
+// is written as its type alone, so that the sequence can be parsed again.
+func Format(tokens []*tokenizer.Token) string {
+	items := make([]string, len(tokens))
+	for i, token := range tokens {
+		item := Item{Type: token.Type, Text: token.Text}
+		if strings.ContainsFunc(token.Text, unicode.IsSpace) {
+			item.AnyText = true
+		}
+		items[i] = item.String()
+	}
+	return strings.Join(items, " ")
+}
+
+// Match returns an error describing the first difference between the tokens
+// and the sequence, or nil if they match.
+func Match(tokens []*tokenizer.Token, sequence string) error {
+	items, err := Parse(sequence)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		if i == len(tokens) {
+			return fmt.Errorf("expected %s at token %d, got the end of the tokens", item, i)
+		}
+		if !item.matches(tokens[i]) {
+			return fmt.Errorf("expected %s at token %d, got %s", item, i, Format(tokens[i:i+1]))
+		}
+	}
+	if len(tokens) > len(items) {
+		return fmt.Errorf("expected the end of the tokens at token %d, got %s", len(items), Format(tokens[len(items):len(items)+1]))
+	}
+	return nil
+}
+
+// ExpectTokens tokenizes the input with the default options and fails the
+// test if that fails or the tokens do not match the sequence.
+func ExpectTokens(t testing.TB, input, sequence string) {
+	t.Helper()
+	ExpectTokensWith(t, input, nil, sequence)
+}
+
+// ExpectTokensWith tokenizes the input configured by opts and fails the test
+// if that fails or the tokens do not match the sequence.
+func ExpectTokensWith(t testing.TB, input string, opts *tokenizer.Options, sequence string) {
+	t.Helper()
+	tokens, err := tokenizer.New(input, opts).Tokenize()
+	if err != nil {
+		t.Errorf("%q: unexpected error: %v", input, err)
+		return
+	}
+	if err := Match(tokens, sequence); err != nil {
+		t.Errorf("%q: %v\n  got:  %s\n  want: %s", input, err, Format(tokens), sequence)
+	}
+}
+
+// ExpectError tokenizes the input configured by opts and fails the test
+// unless it fails with an error whose reason contains the given text.
+func ExpectError(t testing.TB, input string, opts *tokenizer.Options, reason string) {
+	t.Helper()
+	tokens, err := tokenizer.New(input, opts).Tokenize()
+	if err == nil {
+		t.Errorf("%q: expected an error %q, got the tokens %s", input, reason, Format(tokens))
+		return
+	}
+	if !strings.Contains(err.Error(), reason) {
+		t.Errorf("%q: expected an error %q, got %v", input, reason, err)
+	}
+}
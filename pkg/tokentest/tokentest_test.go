@@ -0,0 +1,69 @@
+package tokentest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spicery/nutmeg-tokenizer/pkg/tokenizer"
+)
+
+func TestParse(t *testing.T) {
+	items, err := Parse("S:def O::= s $:")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Item{
+		{Type: tokenizer.StartTokenType, Text: "def"},
+		{Type: tokenizer.OperatorTokenType, Text: ":="},
+		{Type: tokenizer.StringLiteralTokenType, AnyText: true},
+		{Type: tokenizer.EndOfFileTokenType, Text: ""},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Expected %d items, got %v", len(expected), items)
+	}
+	for i, item := range items {
+		if item != expected[i] {
+			t.Errorf("Item %d: expected %v, got %v", i, expected[i], item)
+		}
+	}
+
+	if _, err := Parse("Q:x"); err == nil || !strings.Contains(err.Error(), "unknown token type 'Q'") {
+		t.Errorf("Expected an error for an unknown type, got %v", err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tokens, err := tokenizer.New(`f(x, "a b")`, nil).Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := Format(tokens); got != "V:f [:( V:x M:, s ]:)" {
+		t.Errorf("Unexpected format %s", got)
+	}
+
+	tests := []struct {
+		sequence string
+		problem  string // "" if the tokens match
+	}{
+		{"V:f [:( V:x M:, s ]:)", ""},
+		{`V:f [:( V:x M:, s:"a ]:)`, `expected s:"a at token 4, got s`},
+		{"V:f [:( V:y", "expected V:y at token 2, got V:x"},
+		{"V:f [:( V:x M:, s ]:) V:g", "expected V:g at token 6, got the end of the tokens"},
+		{"V:f [:(", "expected the end of the tokens at token 2, got V:x"},
+	}
+	for _, test := range tests {
+		err := Match(tokens, test.sequence)
+		switch {
+		case test.problem == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.sequence, err)
+		case test.problem != "" && (err == nil || err.Error() != test.problem):
+			t.Errorf("%s: expected %q, got %v", test.sequence, test.problem, err)
+		}
+	}
+}
+
+func TestExpectTokens(t *testing.T) {
+	ExpectTokens(t, "def foo(x) enddef", "S:def V:foo [:( V:x ]:) E:enddef")
+	ExpectTokensWith(t, "x", &tokenizer.Options{EndOfFileToken: true}, "V:x $:")
+	ExpectError(t, `"abc`, nil, "unterminated string")
+}